@@ -27,12 +27,27 @@ func buildCommand() *cli.Command {
 	cmd := cli.NewCommand("build", "build [options]", "Build the site to the output directory")
 
 	drafts := cmd.Flags.Bool("drafts", "d", false, "Include draft content")
-	output := cmd.Flags.String("output", "o", "", "Output directory (overrides site.json)")
+	draftsOnly := cmd.Flags.Bool("drafts-only", "", false, "Build only draft content, for review")
+	output := cmd.Flags.String("output", "o", "", "Output directory (overrides site config)")
+	env := cmd.Flags.String("env", "e", "", "Config environment, e.g. production (default $CANOPY_ENV)")
+	manifest := cmd.Flags.Bool("manifest", "", false, "Write build-manifest.json to the output directory")
+	clean := cmd.Flags.Bool("clean", "", false, "Empty the output directory first instead of removing only stale files")
+	checkLinks := cmd.Flags.Bool("check-links", "", false, "Fail on broken internal links and anchors")
+	dryRun := cmd.Flags.Bool("dry-run", "n", false, "Build without writing files and list what would be written")
 
 	cmd.Action = func(ctx *cli.Context) error {
 		opts := build.Options{
-			BuildDrafts: *drafts,
-			OutputDir:   *output,
+			BuildDrafts:   *drafts,
+			DraftsOnly:    *draftsOnly,
+			OutputDir:     *output,
+			Environment:   *env,
+			WriteManifest: *manifest,
+			Clean:         *clean,
+			DryRun:        *dryRun,
+			CheckLinks:    *checkLinks,
+			Warn: func(message string) {
+				fmt.Fprintf(os.Stderr, "warning: %s\n", message)
+			},
 		}
 
 		stats, err := build.Build(opts)
@@ -40,6 +55,16 @@ func buildCommand() *cli.Command {
 			return err
 		}
 
+		if *dryRun {
+			var total int64
+			for _, file := range stats.Files {
+				fmt.Printf("  %8d  %s\n", file.Size, file.Path)
+				total += file.Size
+			}
+			fmt.Printf("Dry run: would write %d files (%d bytes)\n", len(stats.Files), total)
+			return nil
+		}
+
 		fmt.Printf("Built site:\n")
 		fmt.Printf("  Pages:    %d\n", stats.Pages)
 		fmt.Printf("  Sections: %d\n", stats.Sections)
@@ -5,15 +5,23 @@ import (
 	"bytes"
 	"encoding/json"
 	"encoding/xml"
+	"errors"
 	"fmt"
+	"maps"
+	"net/url"
+	"os"
+	"path"
 	"path/filepath"
+	"runtime"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/shanepadgett/canopy/internal/config"
 	"github.com/shanepadgett/canopy/internal/content"
 	"github.com/shanepadgett/canopy/internal/core"
+	"github.com/shanepadgett/canopy/internal/images"
 	"github.com/shanepadgett/canopy/internal/markdown"
 	"github.com/shanepadgett/canopy/internal/template"
 )
@@ -22,7 +30,38 @@ import (
 type Options struct {
 	ConfigPath  string
 	OutputDir   string // overrides config if set
+	CacheDir    string // overrides config if set
 	BuildDrafts bool
+
+	// Environment selects a config override such as site.production.json.
+	// When empty, the CANOPY_ENV environment variable is used.
+	Environment string
+
+	// WriteManifest writes build-manifest.json describing every output file
+	WriteManifest bool
+
+	// DryRun runs the whole pipeline without writing anything. Stats.Files
+	// lists what would have been written. No manifest is produced.
+	DryRun bool
+
+	// DraftsOnly builds just the draft pages, with the usual templates and
+	// config, so unpublished work can be reviewed alone. It implies
+	// BuildDrafts.
+	DraftsOnly bool
+
+	// CheckLinks fails the build when a rendered page links to an
+	// internal path that wasn't written, or to a missing #anchor on
+	// another page.
+	CheckLinks bool
+
+	// Clean empties the output directory before writing. Otherwise only
+	// files an earlier build wrote and this one didn't are removed, and
+	// anything else in the output directory is left in place.
+	Clean bool
+
+	// Warn receives warnings as they occur. All warnings are also
+	// collected in Stats.Warnings.
+	Warn func(message string)
 }
 
 // Stats contains build statistics.
@@ -32,18 +71,36 @@ type Stats struct {
 	Tags     int
 	Output   string
 	Duration time.Duration
+	Warnings []string
+
+	// Files lists every output file in write order, or in a dry run the
+	// files that would have been written.
+	Files []WrittenFile
 }
 
 // Build runs the complete build pipeline.
 func Build(opts Options) (*Stats, error) {
 	start := time.Now()
+	warnings := &warningLog{sink: opts.Warn}
 
 	// Phase 1: Load config
-	cfg, err := config.Load(opts.ConfigPath)
+	env := opts.Environment
+	if env == "" {
+		env = os.Getenv("CANOPY_ENV")
+	}
+	cfg, err := config.LoadEnvironment(opts.ConfigPath, env)
 	if err != nil {
 		return nil, fmt.Errorf("loading config: %w", err)
 	}
 
+	configWarnings, err := cfg.Validate()
+	if err != nil {
+		return nil, err
+	}
+	for _, warning := range configWarnings {
+		warnings.add(warning)
+	}
+
 	rootDir := "."
 	if opts.ConfigPath != "" {
 		rootDir = config.RootDir(opts.ConfigPath)
@@ -60,10 +117,16 @@ func Build(opts Options) (*Stats, error) {
 	if opts.OutputDir != "" {
 		cfg.OutputDir = opts.OutputDir
 	}
+	if opts.CacheDir != "" {
+		cfg.CacheDir = opts.CacheDir
+	}
 	buildDrafts := cfg.BuildDrafts || opts.BuildDrafts
 
 	// Phase 2: Collect content
 	loader := content.NewLoader(rootDir, cfg, buildDrafts)
+	if opts.DraftsOnly {
+		loader.DraftsOnly()
+	}
 	result, err := loader.Load()
 	if err != nil {
 		return nil, fmt.Errorf("loading content: %w", err)
@@ -71,49 +134,126 @@ func Build(opts Options) (*Stats, error) {
 
 	// Check for content errors
 	if len(result.Errors) > 0 {
-		for _, e := range result.Errors {
-			fmt.Printf("error: %s\n", e.Error())
+		errs := make([]error, len(result.Errors))
+		for i, e := range result.Errors {
+			errs[i] = e
+		}
+		return nil, fmt.Errorf("%d content errors:\n%w", len(result.Errors), errors.Join(errs...))
+	}
+
+	if opts.DraftsOnly {
+		if len(result.Pages) == 0 {
+			return nil, fmt.Errorf("no draft pages found in %s", filepath.Join(rootDir, cfg.ContentDir))
 		}
-		return nil, fmt.Errorf("%d content errors", len(result.Errors))
+	} else if cfg.Build.FailOnEmptySite && !hasPublished(result.Pages) {
+		return nil, fmt.Errorf("no pages found in %s; check contentDir in the site config or set build.failOnEmptySite to false", filepath.Join(rootDir, cfg.ContentDir))
 	}
 
 	// Build site model
 	site := core.NewSite(cfg)
 	site.Pages = result.Pages
 
-	// Index pages by section and tags
+	// Index pages by section and taxonomy
+	taxonomies := cfg.TaxonomyList()
+	for _, taxonomy := range taxonomies {
+		if site.Taxonomies[taxonomy.Plural] == nil {
+			site.Taxonomies[taxonomy.Plural] = make(map[string][]*core.Page)
+		}
+	}
+
 	for _, page := range site.Pages {
+		site.PagesBySource[filepath.ToSlash(page.SourcePath)] = page
+
 		// Add to section
 		section, ok := site.Sections[page.Section]
 		if !ok {
-			section = &core.Section{Name: page.Section}
+			section = core.NewSection(page.Section)
 			site.Sections[page.Section] = section
 		}
 		section.Pages = append(section.Pages, page)
 
-		// Add to tags
-		for _, tag := range page.Tags {
-			site.Tags[tag] = append(site.Tags[tag], page)
+		// Add to taxonomies (tags included)
+		for _, taxonomy := range taxonomies {
+			terms := site.Taxonomies[taxonomy.Plural]
+			for _, term := range page.Terms(taxonomy.Plural) {
+				terms[term] = append(terms[term], page)
+			}
+		}
+
+		// Add to authors
+		for _, author := range page.Authors {
+			site.Authors[author] = append(site.Authors[author], page)
+		}
+
+		// Add to series
+		if page.Series != "" {
+			site.Series[page.Series] = append(site.Series[page.Series], page)
+		}
+	}
+
+	// Apply per-section ordering, then link neighbours in that order
+	for name, section := range site.Sections {
+		if sectionCfg, ok := cfg.Sections[name]; ok {
+			content.SortPages(section.Pages, sectionCfg.SortBy, sectionCfg.SortOrder)
+		}
+		if name != "" {
+			for _, lang := range siteLangs(cfg) {
+				linkSectionPages(pagesInLang(section.Pages, lang))
+			}
 		}
 	}
 
+	linkTranslations(site)
+	linkSeries(site.Series)
+	linkRelated(site.Pages, cfg.Related)
+	site.Menus = buildMenus(cfg, site.Pages)
+	listPages := indexListPages(site, taxonomies)
+
+	// Resource images are resized on demand while templates execute
+	contentDir := filepath.Join(rootDir, cfg.ContentDir)
+	processor := images.NewProcessor(contentDir, resolveDir(rootDir, cfg.CacheDir, "images"))
+	processor.Attach(site.Pages)
+	if opts.DryRun {
+		processor.DryRun()
+	}
+
 	// Phase 3: Render Markdown
-	templateDir := filepath.Join(rootDir, cfg.TemplateDir)
-	engine, err := template.NewEngine(templateDir)
+	templateDirs, staticDirs, err := siteDirs(rootDir, cfg)
+	if err != nil {
+		return nil, err
+	}
+	engine, err := template.NewEngine(templateDirs...)
 	if err != nil {
 		return nil, fmt.Errorf("loading templates: %w", err)
 	}
 
-	for _, page := range site.Pages {
-		result := markdown.RenderWithOptions(page.RawContent, markdown.RenderOptions{
-			Page:              page,
-			ShortcodeRenderer: engine,
-		})
-		page.Body = result.HTML
-		page.TOC = result.TOC
-		if page.Summary == "" {
-			page.Summary = result.Summary
-		}
+	// Fingerprinted names are needed before templates link to them
+	assets, err := fingerprintAssets(staticDirs, cfg.Fingerprint)
+	if err != nil {
+		return nil, fmt.Errorf("fingerprinting assets: %w", err)
+	}
+	engine.SetAssets(cfg.BaseURL, assets)
+	engine.Warn = warnings.add
+
+	if site.I18n, err = loadTranslations(rootDir); err != nil {
+		return nil, fmt.Errorf("loading translations: %w", err)
+	}
+	engine.SetTranslations(site)
+
+	parallel := cfg.Build.Parallel
+	baseOpts := markdownOptions(cfg.Markdown, cfg.BaseURL)
+	baseOpts.Site = site
+	baseOpts.ShortcodeRenderer = engine
+	baseOpts.Warn = warnings.add
+	baseOpts.RootDir = rootDir
+
+	err = forEachPage(site.Pages, parallel, func(page *core.Page) error {
+		err := renderMarkdown(page, baseOpts)
+		page.Assets = engine.ShortcodeAssets(page.UsedShortcodes)
+		return err
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	// Phase 4: Template execute
@@ -121,70 +261,184 @@ func Build(opts Options) (*Stats, error) {
 	// Collect rendered pages: URL -> HTML
 	outputs := make(map[string]string)
 
+	// Pages in sections with outputs also render to those formats: output
+	// path -> contents
+	formatOutputs := make(map[string]string)
+
 	// Render individual pages
-	for _, page := range site.Pages {
+	var outputsMu sync.Mutex
+	err = forEachPage(site.Pages, parallel, func(page *core.Page) error {
 		html, err := engine.RenderPage(page, site)
 		if err != nil {
-			return nil, fmt.Errorf("rendering %s: %w", page.SourcePath, err)
+			return fmt.Errorf("rendering %s: %w", page.SourcePath, err)
+		}
+		formats := make(map[string]string)
+		for _, format := range cfg.Sections[page.Section].Outputs {
+			out, err := engine.RenderFormat(format, page, site)
+			if err != nil {
+				return fmt.Errorf("rendering %s as %s: %w", page.SourcePath, format, err)
+			}
+			formats[path.Join(strings.Trim(page.URL, "/"), cfg.OutputFormats[format].FileName)] = out
 		}
+		outputsMu.Lock()
 		outputs[page.URL] = html
+		maps.Copy(formatOutputs, formats)
+		outputsMu.Unlock()
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	// Render section index pages
+	// Render section index pages, one per language on multilingual sites
 	for _, section := range site.Sections {
-		url := "/" + section.Name + "/"
-		html, err := engine.RenderList(section, site)
+		// Top-level pages have no section list; the home page lists them
+		if section.Name == "" {
+			continue
+		}
+		section.URL = "/" + section.Name + "/"
+		for _, lang := range siteLangs(cfg) {
+			list := section
+			if lang != "" {
+				pages := pagesInLang(section.Pages, lang)
+				if len(pages) == 0 {
+					continue
+				}
+				url := cfg.LangPrefix(lang) + section.URL
+				list = &core.Section{Name: section.Name, Title: section.Title, URL: url, Pages: pages, Lang: lang}
+			}
+			html, err := engine.RenderList(list, site)
+			if err != nil {
+				return nil, fmt.Errorf("rendering section %s: %w", section.Name, err)
+			}
+			outputs[list.URL] = html
+		}
+	}
+
+	// Render taxonomy term and index pages
+	for _, taxonomy := range taxonomies {
+		terms := site.Taxonomies[taxonomy.Plural]
+		if len(terms) == 0 {
+			continue
+		}
+
+		var names []string
+		for term := range terms {
+			names = append(names, term)
+		}
+		sort.Strings(names)
+
+		termPages := make([]*core.Page, 0, len(names))
+
+		for _, term := range names {
+			url := "/" + taxonomy.Plural + "/" + term + "/"
+			section := &core.Section{Name: term, Title: taxonomy.TermTitle(term), URL: url, Pages: terms[term]}
+			html, err := engine.RenderList(section, site)
+			if err != nil {
+				return nil, fmt.Errorf("rendering %s %s: %w", taxonomy.Plural, term, err)
+			}
+			outputs[url] = html
+
+			termPages = append(termPages, listPages[url])
+		}
+
+		index := &core.Section{Name: taxonomy.Plural, Title: taxonomy.Title, URL: "/" + taxonomy.Plural + "/", Pages: termPages}
+		indexHTML, err := engine.RenderList(index, site)
 		if err != nil {
-			return nil, fmt.Errorf("rendering section %s: %w", section.Name, err)
+			return nil, fmt.Errorf("rendering %s index: %w", taxonomy.Plural, err)
 		}
-		outputs[url] = html
+		outputs["/"+taxonomy.Plural+"/"] = indexHTML
 	}
 
-	// Render tag index pages
-	if len(site.Tags) > 0 {
-		var tags []string
-		for tag := range site.Tags {
-			tags = append(tags, tag)
+	// Render author pages
+	if len(site.Authors) > 0 {
+		var ids []string
+		for id := range site.Authors {
+			ids = append(ids, id)
 		}
-		sort.Strings(tags)
+		sort.Strings(ids)
+
+		authorPages := make([]*core.Page, 0, len(ids))
 
-		tagPages := make([]*core.Page, 0, len(tags))
+		for _, id := range ids {
+			author := site.Author(id)
+			url := "/authors/" + id + "/"
+			html, err := engine.RenderAuthor(author, site.Authors[id], site)
+			if err != nil {
+				return nil, fmt.Errorf("rendering author %s: %w", id, err)
+			}
+			outputs[url] = html
 
-		for _, tag := range tags {
-			pages := site.Tags[tag]
-			section := &core.Section{Name: tag, Pages: pages}
-			url := "/tags/" + tag + "/"
+			authorPages = append(authorPages, listPages[url])
+		}
+
+		authorIndex := core.NewSection("authors")
+		authorIndex.Pages = authorPages
+		authorIndexHTML, err := engine.RenderList(authorIndex, site)
+		if err != nil {
+			return nil, fmt.Errorf("rendering authors index: %w", err)
+		}
+		outputs["/authors/"] = authorIndexHTML
+	}
+
+	// Render series pages
+	if len(site.Series) > 0 {
+		var names []string
+		for name := range site.Series {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		seriesPages := make([]*core.Page, 0, len(names))
+
+		for _, name := range names {
+			pages := site.Series[name]
+			url := pages[0].SeriesURL()
+			section := &core.Section{Name: core.Slugify(name), Title: name, URL: url, Pages: pages}
 			html, err := engine.RenderList(section, site)
 			if err != nil {
-				return nil, fmt.Errorf("rendering tag %s: %w", tag, err)
+				return nil, fmt.Errorf("rendering series %s: %w", name, err)
 			}
 			outputs[url] = html
 
-			tagPages = append(tagPages, &core.Page{Title: tag, URL: url})
+			seriesPages = append(seriesPages, listPages[url])
 		}
 
-		tagIndex := &core.Section{Name: "tags", Pages: tagPages}
-		tagIndexHTML, err := engine.RenderList(tagIndex, site)
+		seriesIndex := core.NewSection("series")
+		seriesIndex.Pages = seriesPages
+		seriesIndexHTML, err := engine.RenderList(seriesIndex, site)
 		if err != nil {
-			return nil, fmt.Errorf("rendering tags index: %w", err)
+			return nil, fmt.Errorf("rendering series index: %w", err)
 		}
-		outputs["/tags/"] = tagIndexHTML
+		outputs["/series/"] = seriesIndexHTML
 	}
 
-	// Render home page
-	homeHTML, err := engine.RenderHome(site)
-	if err != nil {
-		return nil, fmt.Errorf("rendering home: %w", err)
+	// Render home page, one per language on multilingual sites
+	for _, lang := range siteLangs(cfg) {
+		homeHTML, err := engine.RenderHome(site, lang)
+		if err != nil {
+			return nil, fmt.Errorf("rendering home: %w", err)
+		}
+		outputs[cfg.LangPrefix(lang)+"/"] = homeHTML
 	}
-	outputs["/"] = homeHTML
 
 	// Phase 5: Write output
 	outputDir := filepath.Join(rootDir, cfg.OutputDir)
-	staticDir := filepath.Join(rootDir, cfg.StaticDir)
 
 	writer := NewWriter(outputDir)
-	if err := writer.Clean(); err != nil {
-		return nil, fmt.Errorf("cleaning output: %w", err)
+	writer.TrackFiles(resolveDir(rootDir, cfg.CacheDir, "output-files.json"))
+	writer.WarnAssetSize(cfg.WarnAssetSize)
+	writer.Fingerprint(assets)
+	if opts.DryRun {
+		writer.DryRun()
+	}
+	if opts.Clean {
+		err = writer.Clean()
+	} else {
+		err = writer.Prepare()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("preparing output: %w", err)
 	}
 
 	for url, html := range outputs {
@@ -193,49 +447,391 @@ func Build(opts Options) (*Stats, error) {
 		}
 	}
 
-	if err := writer.WriteFile("robots.txt", renderRobots(cfg)); err != nil {
-		return nil, fmt.Errorf("writing robots.txt: %w", err)
+	for _, name := range sortedKeys(formatOutputs) {
+		if err := writer.WriteFile(name, formatOutputs[name]); err != nil {
+			return nil, fmt.Errorf("writing %s: %w", name, err)
+		}
+	}
+
+	for _, page := range site.Pages {
+		for _, res := range page.Resources {
+			src := filepath.Join(contentDir, filepath.FromSlash(res.SourcePath))
+			if err := writer.CopyFile(src, res.URL); err != nil {
+				return nil, fmt.Errorf("copying resource %s: %w", res.SourcePath, err)
+			}
+		}
+	}
+
+	processed := processor.Outputs()
+	for _, url := range sortedKeys(processed) {
+		if err := writer.CopyFile(processed[url], url); err != nil {
+			return nil, fmt.Errorf("writing image %s: %w", url, err)
+		}
+	}
+	unsaved := processor.Unsaved()
+	for _, url := range sortedKeys(unsaved) {
+		if err := writer.WriteFile(url, string(unsaved[url])); err != nil {
+			return nil, fmt.Errorf("writing image %s: %w", url, err)
+		}
 	}
 
-	if err := writer.WriteFile("sitemap.xml", renderSitemap(cfg, outputs, site.Pages)); err != nil {
-		return nil, fmt.Errorf("writing sitemap.xml: %w", err)
+	// A robots.txt in static is copied as-is and wins over the generated one
+	if cfg.Robots.Enabled && !staticFileExists(staticDirs, "robots.txt") {
+		if err := writer.WriteFile("robots.txt", renderRobots(cfg)); err != nil {
+			return nil, fmt.Errorf("writing robots.txt: %w", err)
+		}
 	}
 
-	if rss, err := renderRSS(cfg, site.Pages); err != nil {
-		return nil, fmt.Errorf("writing rss.xml: %w", err)
-	} else if err := writer.WriteFile("rss.xml", rss); err != nil {
-		return nil, fmt.Errorf("writing rss.xml: %w", err)
+	if cfg.Sitemap.Enabled {
+		sitemaps := renderSitemaps(cfg, outputs, site.Pages)
+		sitemapNames := make([]string, 0, len(sitemaps))
+		for name := range sitemaps {
+			sitemapNames = append(sitemapNames, name)
+		}
+		sort.Strings(sitemapNames)
+		for _, name := range sitemapNames {
+			if err := writer.WriteFile(name, sitemaps[name]); err != nil {
+				return nil, fmt.Errorf("writing %s: %w", name, err)
+			}
+		}
+	}
+
+	if cfg.Feeds.Enabled {
+		// Multilingual sites get a feed per language under its prefix
+		for _, lang := range siteLangs(cfg) {
+			name := strings.TrimPrefix(cfg.LangPrefix(lang)+"/"+cfg.Feeds.Path, "/")
+			if rss, err := renderRSS(site, lang); err != nil {
+				return nil, fmt.Errorf("writing %s: %w", name, err)
+			} else if err := writer.WriteFile(name, rss); err != nil {
+				return nil, fmt.Errorf("writing %s: %w", name, err)
+			}
+		}
 	}
 
 	if cfg.Search.Enabled {
-		if err := writer.WriteFile("search.json", renderSearchIndex(site.Pages)); err != nil {
-			return nil, fmt.Errorf("writing search.json: %w", err)
+		if err := writer.WriteFile(cfg.Search.Path, renderSearchIndex(site.Pages, cfg.Search.Versioned)); err != nil {
+			return nil, fmt.Errorf("writing %s: %w", cfg.Search.Path, err)
 		}
 	}
 
-	if err := writer.CopyStatic(staticDir); err != nil {
+	if err := writer.CopyStatic(staticDirs...); err != nil {
 		// Static dir may not exist, that's ok
 		if !isNotExist(err) {
 			return nil, fmt.Errorf("copying static: %w", err)
 		}
 	}
+	for _, warning := range writer.Warnings() {
+		warnings.add(warning)
+	}
 
-	return &Stats{
+	if len(assets) > 0 {
+		data, err := renderAssetManifest(assets)
+		if err != nil {
+			return nil, fmt.Errorf("rendering %s: %w", assetManifestFile, err)
+		}
+		if err := writer.WriteFile(assetManifestFile, data); err != nil {
+			return nil, fmt.Errorf("writing %s: %w", assetManifestFile, err)
+		}
+	}
+
+	if opts.CheckLinks {
+		if problems := checkLinks(cfg.BaseURL, outputs, writer.Written()); len(problems) > 0 {
+			return nil, fmt.Errorf("%d broken links:\n%s", len(problems), strings.Join(problems, "\n"))
+		}
+	}
+
+	stats := &Stats{
 		Pages:    len(site.Pages),
 		Sections: len(site.Sections),
 		Tags:     len(site.Tags),
 		Output:   outputDir,
 		Duration: time.Since(start),
-	}, nil
+		Warnings: warnings.list,
+	}
+
+	if opts.WriteManifest && !opts.DryRun {
+		sources := make(map[string]string, len(site.Pages))
+		for _, page := range site.Pages {
+			sources[page.URL] = filepath.ToSlash(filepath.Join(cfg.ContentDir, page.SourcePath))
+		}
+
+		data, err := renderManifest(rootDir, outputDir, writer.Written(), sources, stats)
+		if err != nil {
+			return nil, fmt.Errorf("rendering %s: %w", manifestFile, err)
+		}
+		if err := writer.WriteFile(manifestFile, data); err != nil {
+			return nil, fmt.Errorf("writing %s: %w", manifestFile, err)
+		}
+	}
+
+	if err := writer.Prune(); err != nil {
+		return nil, fmt.Errorf("pruning output: %w", err)
+	}
+	stats.Files = writer.Written()
+
+	return stats, nil
+}
+
+// warningLog collects warnings from concurrent build phases and forwards
+// them to an optional sink.
+type warningLog struct {
+	mu   sync.Mutex
+	list []string
+	sink func(string)
+}
+
+func (w *warningLog) add(message string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.list = append(w.list, message)
+	if w.sink != nil {
+		w.sink(message)
+	}
+}
+
+// linkTranslations connects pages that share a translation key and
+// records each group of two or more in Site.Translations.
+func linkTranslations(site *core.Site) {
+	groups := make(map[string][]*core.Page)
+	for _, page := range site.Pages {
+		if page.TranslationKey != "" {
+			groups[page.TranslationKey] = append(groups[page.TranslationKey], page)
+		}
+	}
+
+	for key, group := range groups {
+		if len(group) < 2 {
+			continue
+		}
+		sort.Slice(group, func(i, j int) bool {
+			return group[i].Lang < group[j].Lang
+		})
+		site.Translations[key] = group
+		for _, page := range group {
+			for _, other := range group {
+				if other != page {
+					page.Translations = append(page.Translations, other)
+				}
+			}
+		}
+	}
+}
+
+// siteLangs returns the languages lists and feeds are split by: each
+// configured language, or just "" for all pages on a single-language site.
+func siteLangs(cfg core.Config) []string {
+	if len(cfg.Languages) == 0 {
+		return []string{""}
+	}
+	return cfg.LanguageCodes()
+}
+
+// pagesInLang returns the pages in lang, keeping their order. An empty
+// lang keeps them all.
+func pagesInLang(pages []*core.Page, lang string) []*core.Page {
+	if lang == "" {
+		return pages
+	}
+	var matched []*core.Page
+	for _, page := range pages {
+		if page.Lang == lang {
+			matched = append(matched, page)
+		}
+	}
+	return matched
+}
+
+// linkSectionPages fills PrevPage and NextPage from a section's ordered
+// pages.
+func linkSectionPages(pages []*core.Page) {
+	for i, page := range pages {
+		page.PrevPage, page.NextPage = nil, nil
+		if i > 0 {
+			page.PrevPage = pages[i-1]
+		}
+		if i < len(pages)-1 {
+			page.NextPage = pages[i+1]
+		}
+	}
+}
+
+// markdownOptions builds render options from the markdown config. Links
+// to baseURL's host count as internal.
+func markdownOptions(cfg core.MarkdownConfig, baseURL string) markdown.RenderOptions {
+	internalHosts := cfg.InternalHosts
+	if u, err := url.Parse(baseURL); err == nil && u.Hostname() != "" {
+		internalHosts = append([]string{u.Hostname()}, internalHosts...)
+	}
+
+	return markdown.RenderOptions{
+		DefaultCodeLang:  cfg.DefaultCodeLang,
+		DiagramLanguages: cfg.DiagramLanguages,
+		EnableMath:       cfg.Math,
+		MarkdownInHTML:   cfg.MarkdownInHTML,
+		EnableEmoji:      cfg.Emoji,
+		Typographer:      cfg.Typographer,
+		HeadingAnchors:   cfg.HeadingAnchors,
+		HeadingOffset:    cfg.HeadingOffset,
+		AutoTOC:          cfg.InsertTOC,
+		TOCMinLevel:      cfg.TOCMinLevel,
+		TOCMaxLevel:      cfg.TOCMaxLevel,
+		LazyImages:       cfg.LazyImages,
+
+		ExternalLinkTarget: cfg.ExternalLinkTarget,
+		ExternalLinkRel:    cfg.ExternalLinkRel,
+		ExternalLinkClass:  cfg.ExternalLinkClass,
+		InternalHosts:      internalHosts,
+	}
+}
+
+// renderMarkdown renders a page's Markdown into Body, TOC, and Summary
+// using the shared options plus the page's own. Rendering problems that
+// must fail the build, like broken refs, are returned joined.
+func renderMarkdown(page *core.Page, opts markdown.RenderOptions) error {
+	opts.Page = page
+	if len(page.Resources) > 0 {
+		opts.ImageBase = page.URL
+	}
+	if toc, ok := page.Params["toc"].(bool); ok {
+		opts.AutoTOC = toc
+	}
+
+	result := markdown.RenderWithOptions(page.RawContent, opts)
+	page.Body = result.HTML
+	page.TOC = result.TOC
+	page.WordCount = result.WordCount
+	page.UsedShortcodes = result.Shortcodes
+	speed := 0
+	if opts.Site != nil {
+		speed = opts.Site.Config.ReadingSpeed
+	}
+	page.ReadingTime = core.ReadingTime(page.WordCount, speed)
+	if page.Summary == "" {
+		page.Summary = result.Summary
+	}
+	return errors.Join(result.Errors...)
+}
+
+// forEachPage calls fn for every page, concurrently when parallel is set.
+// The error returned is the one from the earliest page in slice order, so
+// failures are reported the same way in both modes.
+func forEachPage(pages []*core.Page, parallel bool, fn func(*core.Page) error) error {
+	return forEachIndex(len(pages), parallel, func(i int) error {
+		return fn(pages[i])
+	})
+}
+
+// forEachIndex calls fn for 0..n-1, on a worker pool bounded by
+// GOMAXPROCS when parallel is set, and returns the lowest index's error.
+func forEachIndex(n int, parallel bool, fn func(i int) error) error {
+	if !parallel {
+		for i := 0; i < n; i++ {
+			if err := fn(i); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	errs := make([]error, n)
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+
+	for w := 0; w < runtime.GOMAXPROCS(0); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				errs[i] = fn(i)
+			}
+		}()
+	}
+
+	for i := 0; i < n; i++ {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resolveDir joins a configured directory, and any subdirectories, to the
+// site root unless it is absolute.
+func resolveDir(rootDir, dir string, elem ...string) string {
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(rootDir, dir)
+	}
+	return filepath.Join(append([]string{dir}, elem...)...)
 }
 
 func isNotExist(err error) bool {
 	return err != nil && err.Error() == "static directory does not exist"
 }
 
+// siteDirs returns the template and static directories to load, the
+// site's own first and then the theme's, if one is configured.
+func siteDirs(rootDir string, cfg core.Config) (templateDirs, staticDirs []string, err error) {
+	templateDirs = []string{filepath.Join(rootDir, cfg.TemplateDir)}
+	staticDirs = []string{filepath.Join(rootDir, cfg.StaticDir)}
+	if cfg.Theme == "" {
+		return templateDirs, staticDirs, nil
+	}
+
+	themeDir := filepath.Join(rootDir, "themes", cfg.Theme)
+	if info, err := os.Stat(themeDir); err != nil || !info.IsDir() {
+		return nil, nil, fmt.Errorf("theme %q not found: no directory %s", cfg.Theme, filepath.Join("themes", cfg.Theme))
+	}
+	templateDirs = append(templateDirs, filepath.Join(themeDir, "templates"))
+	staticDirs = append(staticDirs, filepath.Join(themeDir, "static"))
+	return templateDirs, staticDirs, nil
+}
+
+// staticFileExists reports whether any static dir has a file at name.
+func staticFileExists(staticDirs []string, name string) bool {
+	for _, dir := range staticDirs {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
 func renderRobots(cfg core.Config) string {
-	baseURL := strings.TrimRight(cfg.BaseURL, "/")
-	return fmt.Sprintf("User-agent: *\nAllow: /\nSitemap: %s/sitemap.xml\n", baseURL)
+	rules := cfg.Robots.Rules
+	if len(rules) == 0 {
+		rules = []core.RobotsRule{{Allow: []string{"/"}}}
+	}
+
+	var b strings.Builder
+	for i, rule := range rules {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		agent := rule.UserAgent
+		if agent == "" {
+			agent = "*"
+		}
+		fmt.Fprintf(&b, "User-agent: %s\n", agent)
+		for _, path := range rule.Allow {
+			fmt.Fprintf(&b, "Allow: %s\n", path)
+		}
+		for _, path := range rule.Disallow {
+			fmt.Fprintf(&b, "Disallow: %s\n", path)
+		}
+		if rule.CrawlDelay > 0 {
+			fmt.Fprintf(&b, "Crawl-delay: %d\n", rule.CrawlDelay)
+		}
+	}
+	if cfg.Sitemap.Enabled {
+		fmt.Fprintf(&b, "\nSitemap: %s/sitemap.xml\n", strings.TrimRight(cfg.BaseURL, "/"))
+	}
+	return b.String()
 }
 
 type sitemapURL struct {
@@ -249,11 +845,25 @@ type sitemapURLSet struct {
 	URLs    []sitemapURL `xml:"url"`
 }
 
-func renderSitemap(cfg core.Config, outputs map[string]string, pages []*core.Page) string {
+type sitemapIndex struct {
+	XMLName  xml.Name     `xml:"sitemapindex"`
+	Xmlns    string       `xml:"xmlns,attr"`
+	Sitemaps []sitemapURL `xml:"sitemap"`
+}
+
+const sitemapXmlns = "http://www.sitemaps.org/schemas/sitemap/0.9"
+
+// renderSitemaps returns sitemap files keyed by output name. Up to
+// cfg.Sitemap.MaxURLs URLs go in a single sitemap.xml; beyond that the
+// URLs are split into sitemap-N.xml chunks and sitemap.xml becomes an
+// index referencing them.
+func renderSitemaps(cfg core.Config, outputs map[string]string, pages []*core.Page) map[string]string {
 	baseURL := strings.TrimRight(cfg.BaseURL, "/")
 	lastMods := make(map[string]string)
 	for _, page := range pages {
-		if !page.Date.IsZero() {
+		if !page.LastMod.IsZero() {
+			lastMods[page.URL] = page.LastMod.Format("2006-01-02")
+		} else if !page.Date.IsZero() {
 			lastMods[page.URL] = page.Date.Format("2006-01-02")
 		}
 	}
@@ -273,8 +883,35 @@ func renderSitemap(cfg core.Config, outputs map[string]string, pages []*core.Pag
 		return urls[i].Loc < urls[j].Loc
 	})
 
+	limit := cfg.Sitemap.MaxURLs
+	if limit <= 0 || len(urls) <= limit {
+		return map[string]string{"sitemap.xml": renderURLSet(urls)}
+	}
+
+	files := make(map[string]string)
+	index := sitemapIndex{Xmlns: sitemapXmlns}
+	for start := 0; start < len(urls); start += limit {
+		chunk := urls[start:min(start+limit, len(urls))]
+		name := fmt.Sprintf("sitemap-%d.xml", start/limit+1)
+		files[name] = renderURLSet(chunk)
+
+		// The chunk's lastmod is its newest URL's
+		entry := sitemapURL{Loc: baseURL + "/" + name}
+		for _, url := range chunk {
+			if url.LastMod > entry.LastMod {
+				entry.LastMod = url.LastMod
+			}
+		}
+		index.Sitemaps = append(index.Sitemaps, entry)
+	}
+	files["sitemap.xml"] = xmlHeader() + marshalXML(index)
+
+	return files
+}
+
+func renderURLSet(urls []sitemapURL) string {
 	set := sitemapURLSet{
-		Xmlns: "http://www.sitemaps.org/schemas/sitemap/0.9",
+		Xmlns: sitemapXmlns,
 		URLs:  urls,
 	}
 
@@ -284,9 +921,14 @@ func renderSitemap(cfg core.Config, outputs map[string]string, pages []*core.Pag
 type rssFeed struct {
 	XMLName xml.Name `xml:"rss"`
 	Version string   `xml:"version,attr"`
+	XmlnsDC string   `xml:"xmlns:dc,attr"`
 	Channel rssChannel
 }
 
+// dublinCoreNS is the namespace of the dc:creator element, which names
+// authors that <author> can't, as it requires an email.
+const dublinCoreNS = "http://purl.org/dc/elements/1.1/"
+
 type rssChannel struct {
 	Title       string    `xml:"title"`
 	Link        string    `xml:"link"`
@@ -297,18 +939,22 @@ type rssChannel struct {
 }
 
 type rssItem struct {
-	Title       string `xml:"title"`
-	Link        string `xml:"link"`
-	Guid        string `xml:"guid"`
-	Description string `xml:"description"`
-	PubDate     string `xml:"pubDate,omitempty"`
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	Guid        string   `xml:"guid"`
+	Description string   `xml:"description"`
+	Author      string   `xml:"author,omitempty"`
+	Creators    []string `xml:"dc:creator"`
+	PubDate     string   `xml:"pubDate,omitempty"`
 }
 
-func renderRSS(cfg core.Config, pages []*core.Page) (string, error) {
-	baseURL := strings.TrimRight(cfg.BaseURL, "/")
+// renderRSS renders the feed of recent blog pages in lang, or in every
+// language when lang is empty.
+func renderRSS(site *core.Site, lang string) (string, error) {
+	baseURL := strings.TrimRight(site.Config.BaseURL, "/")
 	var blogPages []*core.Page
-	for _, page := range pages {
-		if page.Section == "blog" {
+	for _, page := range site.Pages {
+		if page.Section == "blog" && (lang == "" || page.Lang == lang) {
 			blogPages = append(blogPages, page)
 		}
 	}
@@ -332,6 +978,10 @@ func renderRSS(cfg core.Config, pages []*core.Page) (string, error) {
 		if item.Description == "" {
 			item.Description = page.Summary
 		}
+		item.Author = rssAuthor(site, page.Authors)
+		for _, id := range page.Authors {
+			item.Creators = append(item.Creators, site.Author(id).Name)
+		}
 		if !page.Date.IsZero() {
 			item.PubDate = page.Date.Format(time.RFC1123Z)
 		}
@@ -343,13 +993,18 @@ func renderRSS(cfg core.Config, pages []*core.Page) (string, error) {
 		pubDate = blogPages[0].Date.Format(time.RFC1123Z)
 	}
 
+	language := site.Config.Language
+	if lang != "" {
+		language = lang
+	}
 	feed := rssFeed{
 		Version: "2.0",
+		XmlnsDC: dublinCoreNS,
 		Channel: rssChannel{
-			Title:       cfg.Title,
-			Link:        baseURL,
-			Description: cfg.Description,
-			Language:    cfg.Language,
+			Title:       site.Config.Title,
+			Link:        baseURL + site.Config.LangPrefix(lang),
+			Description: site.Config.Description,
+			Language:    language,
 			PubDate:     pubDate,
 			Items:       items,
 		},
@@ -358,15 +1013,39 @@ func renderRSS(cfg core.Config, pages []*core.Page) (string, error) {
 	return xmlHeader() + marshalXML(feed), nil
 }
 
+// rssAuthor formats the first page author with an email for an RSS
+// <author> element, which holds one "email (Name)". It is empty when no
+// author has an email; every author is also listed in dc:creator.
+func rssAuthor(site *core.Site, ids []string) string {
+	for _, id := range ids {
+		if author := site.Author(id); author.Email != "" {
+			return author.Email + " (" + author.Name + ")"
+		}
+	}
+	return ""
+}
+
 type searchEntry struct {
 	URL     string   `json:"url"`
 	Title   string   `json:"title"`
 	Section string   `json:"section"`
 	Tags    []string `json:"tags"`
 	Summary string   `json:"summary"`
+
+	// TOC is the page's headings as nested in its rendered TOC
+	TOC []*core.TOCNode `json:"toc,omitempty"`
+}
+
+// searchIndexVersion is the schema version of versioned search indexes.
+const searchIndexVersion = 1
+
+// versionedSearchIndex is the search.json shape when search.versioned is set.
+type versionedSearchIndex struct {
+	Version int           `json:"version"`
+	Entries []searchEntry `json:"entries"`
 }
 
-func renderSearchIndex(pages []*core.Page) string {
+func renderSearchIndex(pages []*core.Page, versioned bool) string {
 	entries := make([]searchEntry, 0, len(pages))
 	for _, page := range pages {
 		summary := strings.TrimSpace(page.Summary)
@@ -379,10 +1058,16 @@ func renderSearchIndex(pages []*core.Page) string {
 			Section: page.Section,
 			Tags:    page.Tags,
 			Summary: summary,
+			TOC:     markdown.NestTOC(page.TOC),
 		})
 	}
 
-	data, err := json.MarshalIndent(entries, "", "  ")
+	var index any = entries
+	if versioned {
+		index = versionedSearchIndex{Version: searchIndexVersion, Entries: entries}
+	}
+
+	data, err := json.MarshalIndent(index, "", "  ")
 	if err != nil {
 		return "[]\n"
 	}
@@ -402,3 +1087,13 @@ func marshalXML(v any) string {
 	}
 	return buf.String() + "\n"
 }
+
+// hasPublished reports whether any page is not a draft.
+func hasPublished(pages []*core.Page) bool {
+	for _, page := range pages {
+		if !page.Draft {
+			return true
+		}
+	}
+	return false
+}
@@ -1,11 +1,22 @@
 package build
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/png"
 	"os"
 	"path/filepath"
+	"regexp"
 	"runtime"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/shanepadgett/canopy/internal/core"
 )
 
 func TestBuildShortcodes(t *testing.T) {
@@ -15,6 +26,7 @@ func TestBuildShortcodes(t *testing.T) {
 	stats, err := Build(Options{
 		ConfigPath: configPath,
 		OutputDir:  outputDir,
+		CacheDir:   t.TempDir(),
 	})
 	if err != nil {
 		t.Fatalf("build failed: %v", err)
@@ -31,6 +43,8 @@ func TestBuildShortcodes(t *testing.T) {
 	assertContains(t, html, `class="shortcode-callout-title"`) // callout title
 	assertContains(t, html, `class="shortcode-figure"`)        // figure
 	assertContains(t, html, `youtube.com/embed/dQw4w9WgXcQ`)   // youtube
+	assertContains(t, html, `gist.github.com/octocat/abc.js`)  // gist
+	assertContains(t, html, `codepen.io/team/embed/xyz`)       // codepen
 	assertContains(t, html, `class="shortcode-toc"`)           // toc
 	assertContains(t, html, `toc-level-2`)                     // toc entries
 	assertContains(t, html, `class="shortcode-key-takeaways"`) // key takeaways
@@ -42,6 +56,1947 @@ func TestBuildShortcodes(t *testing.T) {
 	}
 }
 
+func TestBuildCalloutShortcode(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+	}{
+		{"default templates", map[string]string{}},
+		{"custom layouts without shortcodes", map[string]string{
+			"templates/layouts/base.html": `<html><body>{{.Content}}</body></html>`,
+			"templates/layouts/page.html": `<article>{{safeHTML .Page.Body}}</article>`,
+			"templates/layouts/list.html": `<ul>{{range .Pages}}<li>{{.Title}}</li>{{end}}</ul>`,
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.files["content/pages/a.md"] = "---\n{\"title\": \"A\"}\n---\n\n{{< callout type=\"note\" title=\"Heads up\" >}}\nSome **bold** text.\n{{< /callout >}}\n"
+			configPath := writeSite(t, tt.files)
+
+			stats, err := Build(Options{ConfigPath: configPath})
+			if err != nil {
+				t.Fatalf("build failed: %v", err)
+			}
+
+			html := readOutput(t, stats, "pages", "a", "index.html")
+			assertContains(t, html, `<div class="shortcode-callout shortcode-callout-note">`)
+			assertContains(t, html, `<strong class="shortcode-callout-title">Heads up</strong>`)
+			assertContains(t, html, `<strong>bold</strong>`)
+		})
+	}
+}
+
+func TestBuildDetailsShortcode(t *testing.T) {
+	configPath := writeSite(t, map[string]string{
+		"content/pages/faq.md": "---\n{\"title\": \"FAQ\"}\n---\n\n{{< details summary=\"Is it free?\" >}}\nYes, **always**.\n{{< /details >}}\n\n{{< details open=\"true\" >}}\nMore.\n{{< /details >}}\n",
+	})
+
+	stats, err := Build(Options{ConfigPath: configPath})
+	if err != nil {
+		t.Fatalf("build failed: %v", err)
+	}
+
+	html := readOutput(t, stats, "pages", "faq", "index.html")
+	assertContains(t, html, `<details class="shortcode-details">`)
+	assertContains(t, html, `<summary>Is it free?</summary>`)
+	assertContains(t, html, `<strong>always</strong>`)
+	assertContains(t, html, `<details class="shortcode-details" open>`)
+	assertContains(t, html, `<summary>Details</summary>`)
+}
+
+func TestBuildShortcodePositionalParams(t *testing.T) {
+	configPath := writeSite(t, map[string]string{
+		"templates/shortcodes/pic.html": `<img src="{{index .Positional 0}}" alt="{{index .Positional 1}}" class="{{.Params.class}}">`,
+		"content/pages/a.md":            "---\n{\"title\": \"A\"}\n---\n\n{{< pic \"cat.jpg\" class=\"wide\" \"A cat\" >}}\n",
+	})
+
+	stats, err := Build(Options{ConfigPath: configPath})
+	if err != nil {
+		t.Fatalf("build failed: %v", err)
+	}
+
+	assertContains(t, readOutput(t, stats, "pages", "a", "index.html"), `<img src="cat.jpg" alt="A cat" class="wide">`)
+}
+
+func TestBuildShortcodeSite(t *testing.T) {
+	configPath := writeSite(t, map[string]string{
+		"templates/shortcodes/recent.html": `<ul class="recent">{{range (index .Site.Sections (index .Params "section")).Pages}}<li>{{.Title}}</li>{{end}}</ul>`,
+		"content/blog/one.md":              "---\n{\"title\": \"One\", \"date\": \"2026-01-01T00:00:00Z\"}\n---\n\nOne.\n",
+		"content/blog/two.md":              "---\n{\"title\": \"Two\", \"date\": \"2026-01-02T00:00:00Z\"}\n---\n\nTwo.\n",
+		"content/pages/a.md":               "---\n{\"title\": \"A\"}\n---\n\n{{< recent section=\"blog\" >}}\n",
+	})
+
+	stats, err := Build(Options{ConfigPath: configPath})
+	if err != nil {
+		t.Fatalf("build failed: %v", err)
+	}
+
+	assertContains(t, readOutput(t, stats, "pages", "a", "index.html"), `<ul class="recent"><li>Two</li><li>One</li></ul>`)
+}
+
+func TestBuildIncludeShortcodeOverride(t *testing.T) {
+	configPath := writeSite(t, map[string]string{
+		"templates/shortcodes/include.html": `<figure class="sample">{{.Params.file}}</figure>`,
+		"examples/main.go":                  "package main\n",
+		"content/pages/a.md":                "---\n{\"title\": \"A\"}\n---\n\n{{< include file=\"examples/main.go\" >}}\n",
+	})
+
+	stats, err := Build(Options{ConfigPath: configPath})
+	if err != nil {
+		t.Fatalf("build failed: %v", err)
+	}
+	page := readOutput(t, stats, "pages", "a", "index.html")
+	assertContains(t, page, `<figure class="sample">examples/main.go</figure>`)
+	if strings.Contains(page, "package main") {
+		t.Errorf("expected the site's include shortcode to replace the built-in, got %q", page)
+	}
+}
+
+func TestBuildRefShortcode(t *testing.T) {
+	configPath := writeSite(t, map[string]string{
+		"content/blog/hello.md": "---\n{\"title\": \"Hello\", \"date\": \"2026-01-01T00:00:00Z\"}\n---\n\nHi.\n",
+		"content/pages/a.md":    "---\n{\"title\": \"A\"}\n---\n\nRead [hello]({{< relref \"blog/hello.md\" >}}).\n",
+	})
+
+	stats, err := Build(Options{ConfigPath: configPath})
+	if err != nil {
+		t.Fatalf("build failed: %v", err)
+	}
+	assertContains(t, readOutput(t, stats, "pages", "a", "index.html"), `<a href="/blog/hello/">hello</a>`)
+
+	configPath = writeSite(t, map[string]string{
+		"content/pages/a.md": "---\n{\"title\": \"A\"}\n---\n\nRead [hello]({{< ref \"blog/gone.md\" >}}).\n",
+	})
+	_, err = Build(Options{ConfigPath: configPath})
+	if err == nil || !strings.Contains(err.Error(), `pages/a.md: ref "blog/gone.md": page not found`) {
+		t.Errorf("expected a broken ref error naming the page, got %v", err)
+	}
+}
+
+func TestBuildShortcodeParseWarning(t *testing.T) {
+	configPath := writeSite(t, map[string]string{
+		"content/pages/a.md": "---\n{\"title\": \"A\"}\n---\n\nIntro.\n\n{{< figure src=\"a.jpg >}}\n",
+	})
+
+	stats, err := Build(Options{ConfigPath: configPath})
+	if err != nil {
+		t.Fatalf("build failed: %v", err)
+	}
+
+	want := `pages/a.md: malformed shortcode {{< figure src="a.jpg >}} at line 7, column 1`
+	if len(stats.Warnings) != 1 || !strings.Contains(stats.Warnings[0], want) {
+		t.Errorf("expected warning %q, got %q", want, stats.Warnings)
+	}
+}
+
+func TestBuildTweetShortcode(t *testing.T) {
+	configPath := writeSite(t, map[string]string{
+		"content/pages/a.md": "---\n{\"title\": \"A\"}\n---\n\n{{< tweet user=\"jack\" id=\"20\" >}}\n\n{{< tweet url=\"https://x.com/jack/status/21\" text=\"First post\" >}}\n",
+		"content/pages/b.md": "---\n{\"title\": \"B\"}\n---\n\nNo embeds.\n",
+	})
+
+	stats, err := Build(Options{ConfigPath: configPath})
+	if err != nil {
+		t.Fatalf("build failed: %v", err)
+	}
+
+	html := readOutput(t, stats, "pages", "a", "index.html")
+	assertContains(t, html, `<blockquote class="twitter-tweet">`)
+	assertContains(t, html, `<a href="https://twitter.com/jack/status/20">View post on X</a>`)
+	assertContains(t, html, `<a href="https://x.com/jack/status/21">First post</a>`)
+	assertContains(t, html, `platform.twitter.com/widgets.js`)
+
+	if html := readOutput(t, stats, "pages", "b", "index.html"); strings.Contains(html, "widgets.js") {
+		t.Errorf("expected no widget script on a page without tweets")
+	}
+}
+
+func TestBuildUsedShortcodes(t *testing.T) {
+	configPath := writeSite(t, map[string]string{
+		"templates/layouts/base.html": `{{.Content}}`,
+		"templates/layouts/page.html": `{{range .Page.UsedShortcodes}}[{{.}}]{{end}}{{if .Page.HasShortcode "youtube"}} video{{end}}`,
+		"templates/layouts/list.html": `<ul>{{range .Pages}}<li>{{.Title}}</li>{{end}}</ul>`,
+		"content/pages/a.md":          "---\n{\"title\": \"A\"}\n---\n\n{{< callout >}}\nSee {{< youtube id=\"x\" >}}.\n{{< /callout >}}\n",
+		"content/pages/b.md":          "---\n{\"title\": \"B\"}\n---\n\nPlain.\n",
+	})
+
+	stats, err := Build(Options{ConfigPath: configPath})
+	if err != nil {
+		t.Fatalf("build failed: %v", err)
+	}
+
+	assertContains(t, readOutput(t, stats, "pages", "a", "index.html"), "[callout][youtube] video")
+	if html := readOutput(t, stats, "pages", "b", "index.html"); strings.Contains(html, "[") || strings.Contains(html, "video") {
+		t.Errorf("expected no shortcodes on a plain page, got %q", html)
+	}
+}
+
+func TestBuildShortcodeAssets(t *testing.T) {
+	configPath := writeSite(t, map[string]string{
+		"site.json":                        `{"name": "Test", "baseURL": "https://example.com", "fingerprint": ["js/*.js"]}`,
+		"templates/shortcodes/tabs.html":   "{{/* assets: css/tabs.css, js/tabs.js */}}\n<div class=\"tabs\">{{.Inner}}</div>",
+		"templates/shortcodes/scroll.html": "{{- /* assets: js/tabs.js js/scroll.js */ -}}\n<div class=\"scroll\"></div>",
+		"content/pages/a.md":               "---\n{\"title\": \"A\"}\n---\n\n{{< tabs >}}\nOne\n{{< /tabs >}}\n\nSee {{< scroll >}}.\n",
+		"content/pages/b.md":               "---\n{\"title\": \"B\"}\n---\n\nPlain.\n",
+		"static/css/tabs.css":              ".tabs {}",
+		"static/js/tabs.js":                "tabs()",
+		"static/js/scroll.js":              "scroll()",
+	})
+
+	stats, err := Build(Options{ConfigPath: configPath})
+	if err != nil {
+		t.Fatalf("build failed: %v", err)
+	}
+
+	sum := sha256.Sum256([]byte("tabs()"))
+	tabsJS := "/js/tabs." + hex.EncodeToString(sum[:4]) + ".js"
+
+	html := readOutput(t, stats, "pages", "a", "index.html")
+	assertContains(t, html, `<link rel="stylesheet" href="/css/tabs.css">`)
+	assertContains(t, html, `<script src="`+tabsJS+`" defer></script>`)
+	assertContains(t, html, `<script src="/js/scroll.`)
+	if n := strings.Count(html, tabsJS); n != 1 {
+		t.Errorf("expected tabs.js once, found %d", n)
+	}
+	if strings.Contains(html, "assets:") {
+		t.Errorf("expected the asset comment to be dropped")
+	}
+
+	if html := readOutput(t, stats, "pages", "b", "index.html"); strings.Contains(html, "tabs") {
+		t.Errorf("expected no shortcode assets on a page without shortcodes")
+	}
+}
+
+func TestBuildEmbedShortcodeMissingParam(t *testing.T) {
+	configPath := writeSite(t, map[string]string{
+		"content/pages/a.md": "---\n{\"title\": \"A\"}\n---\n\n{{< gist user=\"octocat\" >}}\n\n{{< codepen >}}\n",
+	})
+
+	stats, err := Build(Options{ConfigPath: configPath})
+	if err != nil {
+		t.Fatalf("build failed: %v", err)
+	}
+
+	if len(stats.Warnings) != 2 {
+		t.Fatalf("expected 2 warnings, got %q", stats.Warnings)
+	}
+	assertContains(t, stats.Warnings[0], `missing required param "id"`)
+	assertContains(t, stats.Warnings[1], `missing required param "id"`)
+	if html := readOutput(t, stats, "pages", "a", "index.html"); strings.Contains(html, "gist.github.com") {
+		t.Errorf("expected no gist embed without an id")
+	}
+}
+
+func TestBuildBundleImages(t *testing.T) {
+	configPath := writeSite(t, map[string]string{
+		"content/blog/trip/index.md":  "---\n{\"title\": \"Trip\", \"date\": \"2026-01-02\"}\n---\n\n![Cover](cover.png)\n\n![Remote](https://example.com/x.png)\n",
+		"content/blog/trip/cover.png": "png",
+	})
+
+	stats, err := Build(Options{ConfigPath: configPath})
+	if err != nil {
+		t.Fatalf("build failed: %v", err)
+	}
+
+	html := readOutput(t, stats, "blog", "trip", "index.html")
+	assertContains(t, html, `<img src="/blog/trip/cover.png" alt="Cover">`)
+	assertContains(t, html, `<img src="https://example.com/x.png" alt="Remote">`)
+
+	if _, err := os.Stat(filepath.Join(stats.Output, "blog", "trip", "cover.png")); err != nil {
+		t.Fatalf("expected bundle resource to be copied: %v", err)
+	}
+}
+
+func TestBuildSectionIndexIsNotBundle(t *testing.T) {
+	configPath := writeSite(t, map[string]string{
+		"templates/layouts/base.html": `{{.Content}}`,
+		"templates/layouts/page.html": `{{.Page.Title}}:{{len .Page.Resources}}`,
+		"templates/layouts/list.html": ``,
+		"content/blog/index.md":       "---\n{\"title\": \"Blog\"}\n---\n\nAll posts.\n",
+		"content/blog/post.md":        "---\n{\"title\": \"Post\"}\n---\n\nHi.\n",
+		"content/blog/photo.png":      "png",
+		"content/docs/guide/index.md": "---\n{\"title\": \"Guide\"}\n---\n\nRead.\n",
+		"content/docs/guide/intro.md": "---\n{\"title\": \"Intro\"}\n---\n\nStart.\n",
+	})
+
+	stats, err := Build(Options{ConfigPath: configPath})
+	if err != nil {
+		t.Fatalf("build failed: %v", err)
+	}
+	for _, file := range stats.Files {
+		if strings.HasSuffix(file.Path, "photo.png") {
+			t.Errorf("a section's index.md shouldn't collect its siblings as resources, wrote %s", file.Path)
+		}
+	}
+	if got := readOutput(t, stats, "blog", "index", "index.html"); got != "Blog:0" {
+		t.Errorf("blog/index.md = %q, want a plain page without resources", got)
+	}
+	if got := readOutput(t, stats, "docs", "index", "index.html"); got != "Guide:0" {
+		t.Errorf("docs/guide/index.md = %q, want a plain page beside intro.md", got)
+	}
+}
+
+func TestBuildSearchIndexVersioned(t *testing.T) {
+	page := "---\n{\"title\": \"Hello\"}\n---\n\nHello there.\n"
+
+	tests := []struct {
+		name      string
+		versioned bool
+		prefix    string
+	}{
+		{"plain array", false, "["},
+		{"versioned wrapper", true, "{"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configPath := writeSite(t, map[string]string{
+				"site.json":           fmt.Sprintf(`{"name": "Test", "baseURL": "https://example.com", "search": {"enabled": true, "versioned": %t}}`, tt.versioned),
+				"content/blog/one.md": page,
+			})
+
+			stats, err := Build(Options{ConfigPath: configPath})
+			if err != nil {
+				t.Fatalf("build failed: %v", err)
+			}
+
+			data := readOutput(t, stats, "search.json")
+			if !strings.HasPrefix(data, tt.prefix) {
+				t.Fatalf("search.json = %q, want prefix %q", data, tt.prefix)
+			}
+
+			if !tt.versioned {
+				return
+			}
+			var index struct {
+				Version int              `json:"version"`
+				Entries []map[string]any `json:"entries"`
+			}
+			if err := json.Unmarshal([]byte(data), &index); err != nil {
+				t.Fatalf("parsing search.json: %v", err)
+			}
+			if index.Version != 1 || len(index.Entries) != 1 {
+				t.Fatalf("unexpected versioned index: %+v", index)
+			}
+		})
+	}
+}
+
+func TestBuildSearchIndexTOC(t *testing.T) {
+	configPath := writeSite(t, map[string]string{
+		"site.json":           `{"name": "Test", "baseURL": "https://example.com", "search": {"enabled": true}}`,
+		"content/blog/one.md": "---\n{\"title\": \"One\"}\n---\n\n## Setup\n\n### Install\n\n## Usage\n",
+		"content/blog/two.md": "---\n{\"title\": \"Two\"}\n---\n\nNo headings.\n",
+	})
+
+	stats, err := Build(Options{ConfigPath: configPath})
+	if err != nil {
+		t.Fatalf("build failed: %v", err)
+	}
+
+	var entries []struct {
+		URL string          `json:"url"`
+		TOC []*core.TOCNode `json:"toc"`
+	}
+	if err := json.Unmarshal([]byte(readOutput(t, stats, "search.json")), &entries); err != nil {
+		t.Fatalf("parsing search.json: %v", err)
+	}
+	for _, entry := range entries {
+		switch entry.URL {
+		case "/blog/one/":
+			if len(entry.TOC) != 2 || entry.TOC[0].ID != "setup" || len(entry.TOC[0].Children) != 1 ||
+				entry.TOC[0].Children[0].Title != "Install" || entry.TOC[1].Level != 2 {
+				data, _ := json.Marshal(entry.TOC)
+				t.Errorf("TOC = %s, want setup(install) and usage", data)
+			}
+		case "/blog/two/":
+			if entry.TOC != nil {
+				t.Errorf("TOC = %v, want none for a page without headings", entry.TOC)
+			}
+		}
+	}
+	assertContains(t, readOutput(t, stats, "blog", "one", "index.html"), `id="install"`)
+}
+
+func TestBuildOutputFormats(t *testing.T) {
+	configPath := writeSite(t, map[string]string{
+		"site.json": `{"name": "Test", "baseURL": "https://example.com",
+			"outputFormats": {"text": {"fileName": "index.txt"}},
+			"sections": {"blog": {"outputs": ["json", "text"]}}}`,
+		"content/blog/one.md":             "---\n{\"title\": \"One\", \"tags\": [\"go\"]}\n---\n\nHello <world>.\n",
+		"content/pages/about.md":          "---\n{\"title\": \"About\"}\n---\n\nAbout.\n",
+		"templates/formats/text/page.txt": "{{.Page.Title}} <{{.Page.URL}}>\n",
+	})
+
+	stats, err := Build(Options{ConfigPath: configPath})
+	if err != nil {
+		t.Fatalf("build failed: %v", err)
+	}
+
+	var page struct {
+		Title   string   `json:"title"`
+		URL     string   `json:"url"`
+		Tags    []string `json:"tags"`
+		Content string   `json:"content"`
+	}
+	if err := json.Unmarshal([]byte(readOutput(t, stats, "blog", "one", "index.json")), &page); err != nil {
+		t.Fatalf("parsing index.json: %v", err)
+	}
+	if page.Title != "One" || page.URL != "/blog/one/" || len(page.Tags) != 1 || !strings.Contains(page.Content, "Hello &lt;world&gt;.") {
+		t.Errorf("index.json = %+v, want the page's title, URL, tags, and content", page)
+	}
+
+	// Text formats aren't HTML-escaped or wrapped in the base layout
+	if got := readOutput(t, stats, "blog", "one", "index.txt"); got != "One </blog/one/>\n" {
+		t.Errorf("index.txt = %q, want the text template's output", got)
+	}
+	assertContains(t, readOutput(t, stats, "blog", "one", "index.html"), "Hello &lt;world&gt;.")
+
+	if _, err := os.Stat(filepath.Join(stats.Output, "pages", "about", "index.json")); !os.IsNotExist(err) {
+		t.Errorf("sections without outputs should only render HTML, stat err = %v", err)
+	}
+}
+
+func TestBuildInvalidOutputFileName(t *testing.T) {
+	for _, fileName := range []string{"", ".", "..", "index.html", "a/b.json", "./x.json"} {
+		t.Run(fileName, func(t *testing.T) {
+			configPath := writeSite(t, map[string]string{
+				"site.json":           `{"name": "Test", "baseURL": "https://example.com", "outputFormats": {"x": {"fileName": "` + fileName + `"}}}`,
+				"content/blog/one.md": "---\n{\"title\": \"One\"}\n---\n\nHello.\n",
+			})
+			if _, err := Build(Options{ConfigPath: configPath}); err == nil || !strings.Contains(err.Error(), `output format "x"`) {
+				t.Fatalf("err = %v, want an invalid fileName error", err)
+			}
+		})
+	}
+}
+
+func TestBuildUnknownOutputFormat(t *testing.T) {
+	configPath := writeSite(t, map[string]string{
+		"site.json":           `{"name": "Test", "baseURL": "https://example.com", "sections": {"blog": {"outputs": ["amp"]}}}`,
+		"content/blog/one.md": "---\n{\"title\": \"One\"}\n---\n\nHello.\n",
+	})
+
+	if _, err := Build(Options{ConfigPath: configPath}); err == nil || !strings.Contains(err.Error(), `sections.blog.outputs names unknown output format "amp"`) {
+		t.Fatalf("err = %v, want an unknown output format error", err)
+	}
+}
+
+func TestBuildI18nFunc(t *testing.T) {
+	configPath := writeSite(t, map[string]string{
+		"site.json":                      `{"name": "Test", "baseURL": "https://example.com", "language": "en", "languages": {"en": {"name": "English"}, "fr": {"name": "Français"}}}`,
+		"i18n/en.json":                   `{"read_more": "Read more"}`,
+		"i18n/fr.json":                   `{"read_more": "Lire la suite"}`,
+		"templates/layouts/base.html":    `{{.Content}}`,
+		"templates/layouts/page.html":    `{{.Page.Body}}|{{template "partials/more.html" .Page}}|{{i18n "read_more" "en"}}`,
+		"templates/layouts/list.html":    ``,
+		"templates/partials/more.html":   `{{i18n "read_more" .}}`,
+		"templates/shortcodes/more.html": `{{i18n "read_more" .Page}}`,
+		"content/fr/about.md":            "---\n{\"title\": \"À propos\"}\n---\n\n{{< more >}}\n",
+	})
+
+	stats, err := Build(Options{ConfigPath: configPath})
+	if err != nil {
+		t.Fatalf("build failed: %v", err)
+	}
+	assertContains(t, readOutput(t, stats, "fr", "about", "index.html"), "Lire la suite\n|Lire la suite|Read more")
+}
+
+func TestBuildI18nBundlesAndLists(t *testing.T) {
+	configPath := writeSite(t, map[string]string{
+		"site.json":                      `{"name": "Test", "baseURL": "https://example.com", "language": "en", "languages": {"en": {"name": "English"}, "fr": {"name": "Français"}}}`,
+		"templates/layouts/base.html":    `{{.Content}}`,
+		"templates/layouts/page.html":    `{{with .Page.PrevPage}}prev:{{.URL}}{{end}}|{{with .Page.NextPage}}next:{{.URL}}{{end}}`,
+		"templates/layouts/list.html":    `{{range .Pages}}{{.URL}} {{end}}`,
+		"content/blog/a.md":              "---\n{\"title\": \"A\", \"date\": \"2026-01-01\"}\n---\n\nA.\n",
+		"content/blog/b.md":              "---\n{\"title\": \"B\", \"date\": \"2026-01-02\"}\n---\n\nB.\n",
+		"content/fr/blog/c.md":           "---\n{\"title\": \"C\", \"date\": \"2026-01-03\"}\n---\n\nC.\n",
+		"content/fr/blog/trip/index.md":  "---\n{\"title\": \"Voyage\", \"date\": \"2026-01-04\"}\n---\n\n![Photo](photo.png)\n",
+		"content/fr/blog/trip/photo.png": "png",
+	})
+
+	stats, err := Build(Options{ConfigPath: configPath})
+	if err != nil {
+		t.Fatalf("build failed: %v", err)
+	}
+	if got := readOutput(t, stats, "fr", "blog", "trip", "photo.png"); got != "png" {
+		t.Errorf("bundle resource = %q, want the file under content/fr", got)
+	}
+
+	// Lists and neighbours stay within a language
+	if got := readOutput(t, stats, "blog", "index.html"); got != "/blog/a/ /blog/b/ " {
+		t.Errorf("/blog/ = %q, want only English pages", got)
+	}
+	if got := readOutput(t, stats, "fr", "blog", "index.html"); got != "/fr/blog/c/ /fr/blog/trip/ " {
+		t.Errorf("/fr/blog/ = %q, want only French pages", got)
+	}
+	if got := readOutput(t, stats, "fr", "index.html"); got != "/fr/blog/c/ /fr/blog/trip/ " {
+		t.Errorf("/fr/ = %q, want only French pages", got)
+	}
+	if got := readOutput(t, stats, "blog", "a", "index.html"); got != "|next:/blog/b/" {
+		t.Errorf("/blog/a/ neighbours = %q, want only English ones", got)
+	}
+	if got := readOutput(t, stats, "fr", "blog", "c", "index.html"); got != "|next:/fr/blog/trip/" {
+		t.Errorf("/fr/blog/c/ neighbours = %q, want only French ones", got)
+	}
+}
+
+func TestBuildParallelMatchesSequential(t *testing.T) {
+	build := func(parallel bool) map[string]string {
+		files := map[string]string{
+			"site.json": fmt.Sprintf(`{"name": "Test", "baseURL": "https://example.com", "build": {"parallel": %t}}`, parallel),
+		}
+		for i := 0; i < 20; i++ {
+			files[fmt.Sprintf("content/blog/post-%02d.md", i)] = fmt.Sprintf(
+				"---\n{\"title\": \"Post %d\", \"date\": \"2026-01-%02dT00:00:00Z\", \"tags\": [\"t%d\"]}\n---\n\n## Heading %d\n\nBody %d.\n",
+				i, i+1, i%3, i, i)
+		}
+
+		stats, err := Build(Options{ConfigPath: writeSite(t, files)})
+		if err != nil {
+			t.Fatalf("build failed: %v", err)
+		}
+		return readTree(t, stats.Output)
+	}
+
+	sequential := build(false)
+	parallel := build(true)
+
+	if len(sequential) != len(parallel) {
+		t.Fatalf("file count differs: sequential %d, parallel %d", len(sequential), len(parallel))
+	}
+	for path, want := range sequential {
+		if got, ok := parallel[path]; !ok || got != want {
+			t.Errorf("output %s differs between sequential and parallel builds", path)
+		}
+	}
+}
+
+func TestBuildTranslations(t *testing.T) {
+	configPath := writeSite(t, map[string]string{
+		"content/about.md":    "---\n{\"title\": \"About\", \"translationKey\": \"about\"}\n---\n\nHello.\n",
+		"content/a-propos.md": "---\n{\"title\": \"À propos\", \"lang\": \"fr\", \"translationKey\": \"about\"}\n---\n\nBonjour.\n",
+	})
+
+	stats, err := Build(Options{ConfigPath: configPath})
+	if err != nil {
+		t.Fatalf("build failed: %v", err)
+	}
+
+	assertContains(t, readOutput(t, stats, "about", "index.html"), `<a href="/a-propos/" hreflang="fr" lang="fr">fr</a>`)
+	assertContains(t, readOutput(t, stats, "a-propos", "index.html"), `<a href="/about/" hreflang="en" lang="en">en</a>`)
+}
+
+func TestBuildWarnAssetSize(t *testing.T) {
+	configPath := writeSite(t, map[string]string{
+		"site.json":        `{"name": "Test", "baseURL": "https://example.com", "warnAssetSize": 1024}`,
+		"content/index.md": "Home.\n",
+		"static/big.bin":   strings.Repeat("x", 2048),
+		"static/small.txt": "tiny",
+	})
+
+	stats, err := Build(Options{ConfigPath: configPath})
+	if err != nil {
+		t.Fatalf("build failed: %v", err)
+	}
+
+	if len(stats.Warnings) != 1 {
+		t.Fatalf("expected 1 warning, got %v", stats.Warnings)
+	}
+	assertContains(t, stats.Warnings[0], "big.bin")
+	if strings.Contains(stats.Warnings[0], "small.txt") {
+		t.Fatalf("unexpected warning for small file: %v", stats.Warnings)
+	}
+}
+
+func TestBuildFingerprint(t *testing.T) {
+	configPath := writeSite(t, map[string]string{
+		"site.json":                   `{"name": "Test", "baseURL": "https://example.com", "fingerprint": ["css/*.css"]}`,
+		"templates/layouts/base.html": `<link rel="stylesheet" href="{{assetURL "css/site.css"}}"><script src="{{assetURL "/js/app.js"}}"></script>{{.Content}}`,
+		"templates/layouts/page.html": `<article>{{safeHTML .Page.Body}}</article>`,
+		"templates/layouts/list.html": `<ul>{{range .Pages}}<li>{{.Title}}</li>{{end}}</ul>`,
+		"content/pages/a.md":          "---\n{\"title\": \"A\"}\n---\n\nBody.\n",
+		"static/css/site.css":         "body {}",
+		"static/js/app.js":            "app()",
+	})
+
+	stats, err := Build(Options{ConfigPath: configPath})
+	if err != nil {
+		t.Fatalf("build failed: %v", err)
+	}
+
+	sum := sha256.Sum256([]byte("body {}"))
+	hashed := "css/site." + hex.EncodeToString(sum[:4]) + ".css"
+
+	html := readOutput(t, stats, "pages", "a", "index.html")
+	assertContains(t, html, `href="/`+hashed+`"`)
+	assertContains(t, html, `src="/js/app.js"`)
+	assertContains(t, readOutput(t, stats, hashed), "body {}")
+	assertContains(t, readOutput(t, stats, "js", "app.js"), "app()")
+	assertContains(t, readOutput(t, stats, "asset-manifest.json"), `"css/site.css": "`+hashed+`"`)
+
+	if _, err := os.Stat(filepath.Join(stats.Output, "css", "site.css")); !os.IsNotExist(err) {
+		t.Errorf("expected only the fingerprinted stylesheet in the output, stat err = %v", err)
+	}
+}
+
+func TestBuildAssetURLBasePath(t *testing.T) {
+	configPath := writeSite(t, map[string]string{
+		"site.json":                   `{"name": "Test", "baseURL": "https://example.com/docs/", "fingerprint": ["css/*.css"]}`,
+		"templates/layouts/base.html": `<link rel="stylesheet" href="{{assetURL "css/site.css"}}"><script src="{{assetURL "/js/app.js"}}"></script>{{.Content}}`,
+		"templates/layouts/page.html": `<article>{{safeHTML .Page.Body}}</article>`,
+		"templates/layouts/list.html": `<ul>{{range .Pages}}<li>{{.Title}}</li>{{end}}</ul>`,
+		"content/pages/a.md":          "---\n{\"title\": \"A\"}\n---\n\nBody.\n",
+		"static/css/site.css":         "body {}",
+		"static/js/app.js":            "app()",
+	})
+
+	stats, err := Build(Options{ConfigPath: configPath})
+	if err != nil {
+		t.Fatalf("build failed: %v", err)
+	}
+
+	sum := sha256.Sum256([]byte("body {}"))
+	html := readOutput(t, stats, "pages", "a", "index.html")
+	assertContains(t, html, `href="/docs/css/site.`+hex.EncodeToString(sum[:4])+`.css"`)
+	assertContains(t, html, `src="/docs/js/app.js"`)
+}
+
+func TestBuildPrunesStaleOutput(t *testing.T) {
+	configPath := writeSite(t, map[string]string{
+		"content/pages/a.md": "---\n{\"title\": \"A\"}\n---\n\nA.\n",
+		"content/pages/b.md": "---\n{\"title\": \"B\"}\n---\n\nB.\n",
+	})
+	root := filepath.Dir(configPath)
+
+	stats, err := Build(Options{ConfigPath: configPath})
+	if err != nil {
+		t.Fatalf("build failed: %v", err)
+	}
+	writeSiteFile(t, stats.Output, "CNAME", "example.com")
+	writeSiteFile(t, stats.Output, ".canopy-files.json", "[]")
+	if _, err := os.Stat(filepath.Join(root, ".canopy-cache", "output-files.json")); err != nil {
+		t.Errorf("expected the written files to be tracked in the cache dir: %v", err)
+	}
+
+	if err := os.Remove(filepath.Join(root, "content", "pages", "b.md")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Build(Options{ConfigPath: configPath}); err != nil {
+		t.Fatalf("rebuild failed: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(stats.Output, "pages", "b")); !os.IsNotExist(err) {
+		t.Errorf("expected the removed page's directory to be pruned, stat err = %v", err)
+	}
+	readOutput(t, stats, "pages", "a", "index.html")
+	assertContains(t, readOutput(t, stats, "CNAME"), "example.com")
+	if _, err := os.Stat(filepath.Join(stats.Output, ".canopy-files.json")); !os.IsNotExist(err) {
+		t.Errorf("expected no file list in the output dir, stat err = %v", err)
+	}
+
+	if _, err := Build(Options{ConfigPath: configPath, Clean: true}); err != nil {
+		t.Fatalf("clean build failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(stats.Output, "CNAME")); !os.IsNotExist(err) {
+		t.Errorf("expected a clean build to remove unknown files, stat err = %v", err)
+	}
+}
+
+func TestBuildDryRun(t *testing.T) {
+	configPath := writeSite(t, map[string]string{
+		"content/pages/a.md": "---\n{\"title\": \"A\"}\n---\n\nA.\n",
+		"static/site.css":    "body {}",
+	})
+	outputDir := filepath.Join(filepath.Dir(configPath), "public")
+	if err := os.MkdirAll(outputDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	writeSiteFile(t, outputDir, "old.html", "old")
+
+	stats, err := Build(Options{ConfigPath: configPath, DryRun: true, Clean: true, WriteManifest: true})
+	if err != nil {
+		t.Fatalf("dry run failed: %v", err)
+	}
+
+	files := make(map[string]int64)
+	for _, file := range stats.Files {
+		files[file.Path] = file.Size
+	}
+	if files["site.css"] != int64(len("body {}")) || files["pages/a/index.html"] == 0 || files["sitemap.xml"] == 0 {
+		t.Errorf("expected planned files with sizes, got %v", files)
+	}
+
+	entries, err := os.ReadDir(outputDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "old.html" {
+		t.Errorf("expected a dry run to leave the output untouched, found %v", entries)
+	}
+}
+
+func TestBuildCheckLinks(t *testing.T) {
+	files := map[string]string{
+		"content/blog/hello.md": "---\n{\"title\": \"Hello\", \"date\": \"2026-01-01T00:00:00Z\"}\n---\n\n## Setup\n\nHi.\n",
+		"content/pages/a.md": "---\n{\"title\": \"A\"}\n---\n\n" +
+			"[ok](/blog/hello/#setup) [bare](/blog/hello) [rel](../../blog/hello/) [css](/site.css) " +
+			"[abs](https://example.com/blog/hello/) [ext](https://other.example/x) [mail](mailto:a@b.c) [top](#top)\n",
+		"static/site.css": "body {}",
+	}
+	configPath := writeSite(t, files)
+	if _, err := Build(Options{ConfigPath: configPath, CheckLinks: true}); err != nil {
+		t.Fatalf("expected valid links to pass, got %v", err)
+	}
+
+	files["content/pages/a.md"] = "---\n{\"title\": \"A\"}\n---\n\n[gone](/blog/gone/) [anchor](/blog/hello/#teardown) ![img](missing.png)\n"
+	configPath = writeSite(t, files)
+	_, err := Build(Options{ConfigPath: configPath, CheckLinks: true})
+	if err == nil {
+		t.Fatal("expected broken links to fail the build")
+	}
+	for _, want := range []string{
+		`3 broken links`,
+		`/pages/a/: broken link "/blog/gone/"`,
+		`/pages/a/: link "/blog/hello/#teardown" points to a missing anchor`,
+		`/pages/a/: broken link "missing.png"`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected %q in error:\n%v", want, err)
+		}
+	}
+}
+
+func TestBuildDraftsOnly(t *testing.T) {
+	configPath := writeSite(t, map[string]string{
+		"content/blog/live.md":  "---\n{\"title\": \"Live\"}\n---\n\nLive.\n",
+		"content/blog/draft.md": "---\n{\"title\": \"Draft\", \"draft\": true}\n---\n\nDraft.\n",
+	})
+
+	stats, err := Build(Options{ConfigPath: configPath, DraftsOnly: true})
+	if err != nil {
+		t.Fatalf("build failed: %v", err)
+	}
+	if stats.Pages != 1 {
+		t.Errorf("Pages = %d, want only the draft", stats.Pages)
+	}
+	readOutput(t, stats, "blog", "draft", "index.html")
+	if _, err := os.Stat(filepath.Join(stats.Output, "blog", "live")); !os.IsNotExist(err) {
+		t.Errorf("expected published pages to be left out, stat err = %v", err)
+	}
+
+	configPath = writeSite(t, map[string]string{
+		"content/blog/live.md": "---\n{\"title\": \"Live\"}\n---\n\nLive.\n",
+	})
+	if _, err := Build(Options{ConfigPath: configPath, DraftsOnly: true}); err == nil || !strings.Contains(err.Error(), "no draft pages") {
+		t.Errorf("expected an error when there are no drafts, got %v", err)
+	}
+}
+
+func TestBuildOutputConfig(t *testing.T) {
+	configPath := writeSite(t, map[string]string{
+		"site.json":        `{"name": "Test", "baseURL": "https://example.com", "feeds": {"path": "feed.xml"}, "search": {"enabled": true, "path": "index.json"}, "sitemap": {"enabled": false}}`,
+		"content/about.md": "---\n{\"title\": \"About\"}\n---\n\nAbout.\n",
+	})
+
+	stats, err := Build(Options{ConfigPath: configPath})
+	if err != nil {
+		t.Fatalf("build failed: %v", err)
+	}
+	readOutput(t, stats, "feed.xml")
+	readOutput(t, stats, "index.json")
+	robots := readOutput(t, stats, "robots.txt")
+	if strings.Contains(robots, "Sitemap:") {
+		t.Errorf("robots.txt should not point at a disabled sitemap:\n%s", robots)
+	}
+	for _, name := range []string{"rss.xml", "search.json", "sitemap.xml"} {
+		if _, err := os.Stat(filepath.Join(stats.Output, name)); !os.IsNotExist(err) {
+			t.Errorf("expected no %s, stat err = %v", name, err)
+		}
+	}
+	assertContains(t, readOutput(t, stats, "about", "index.html"), "fetch('/index.json')")
+
+	configPath = writeSite(t, map[string]string{
+		"site.json":        `{"name": "Test", "baseURL": "https://example.com", "robots": {"enabled": false}, "feeds": {"enabled": false}}`,
+		"content/about.md": "---\n{\"title\": \"About\"}\n---\n\nAbout.\n",
+	})
+	stats, err = Build(Options{ConfigPath: configPath})
+	if err != nil {
+		t.Fatalf("build failed: %v", err)
+	}
+	for _, name := range []string{"robots.txt", "rss.xml"} {
+		if _, err := os.Stat(filepath.Join(stats.Output, name)); !os.IsNotExist(err) {
+			t.Errorf("expected no %s, stat err = %v", name, err)
+		}
+	}
+}
+
+func TestBuildRobotsRules(t *testing.T) {
+	configPath := writeSite(t, map[string]string{
+		"site.json":        `{"name": "Test", "baseURL": "https://staging.example.com/", "robots": {"rules": [{"disallow": ["/"]}, {"userAgent": "Googlebot", "allow": ["/blog/"], "disallow": ["/drafts/"], "crawlDelay": 10}]}}`,
+		"content/about.md": "---\n{\"title\": \"About\"}\n---\n\nAbout.\n",
+	})
+
+	stats, err := Build(Options{ConfigPath: configPath})
+	if err != nil {
+		t.Fatalf("build failed: %v", err)
+	}
+	want := "User-agent: *\nDisallow: /\n\nUser-agent: Googlebot\nAllow: /blog/\nDisallow: /drafts/\nCrawl-delay: 10\n\nSitemap: https://staging.example.com/sitemap.xml\n"
+	if got := readOutput(t, stats, "robots.txt"); got != want {
+		t.Errorf("robots.txt = %q, want %q", got, want)
+	}
+
+	configPath = writeSite(t, map[string]string{
+		"content/about.md":  "---\n{\"title\": \"About\"}\n---\n\nAbout.\n",
+		"static/robots.txt": "User-agent: *\nDisallow: /private/\n",
+	})
+	stats, err = Build(Options{ConfigPath: configPath})
+	if err != nil {
+		t.Fatalf("build failed: %v", err)
+	}
+	if got := readOutput(t, stats, "robots.txt"); got != "User-agent: *\nDisallow: /private/\n" {
+		t.Errorf("expected the static robots.txt verbatim, got %q", got)
+	}
+}
+
+func TestBuildSEOMeta(t *testing.T) {
+	configPath := writeSite(t, map[string]string{
+		"site.json":           `{"name": "Test", "baseURL": "https://example.com/", "description": "A test site"}`,
+		"content/blog/one.md": "---\n{\"title\": \"One\", \"description\": \"First post\", \"image\": \"/img/one.png\"}\n---\n\nOne.\n",
+		"content/blog/two.md": "---\n{\"title\": \"Two\"}\n---\n\nSecond post body.\n",
+	})
+
+	stats, err := Build(Options{ConfigPath: configPath})
+	if err != nil {
+		t.Fatalf("build failed: %v", err)
+	}
+
+	one := readOutput(t, stats, "blog", "one", "index.html")
+	assertContains(t, one, `<link rel="canonical" href="https://example.com/blog/one/">`)
+	assertContains(t, one, `<meta property="og:title" content="One">`)
+	assertContains(t, one, `<meta property="og:description" content="First post">`)
+	assertContains(t, one, `<meta property="og:type" content="article">`)
+	assertContains(t, one, `<meta property="og:image" content="https://example.com/img/one.png">`)
+	assertContains(t, one, `<meta name="twitter:card" content="summary_large_image">`)
+
+	two := readOutput(t, stats, "blog", "two", "index.html")
+	assertContains(t, two, `<meta name="description" content="Second post body.">`)
+	assertContains(t, two, `<meta name="twitter:card" content="summary">`)
+
+	list := readOutput(t, stats, "blog", "index.html")
+	assertContains(t, list, `<link rel="canonical" href="https://example.com/blog/">`)
+	assertContains(t, list, `<meta property="og:type" content="website">`)
+	assertContains(t, list, `<meta name="description" content="A test site">`)
+}
+
+func TestBuildBaseLayoutPage(t *testing.T) {
+	configPath := writeSite(t, map[string]string{
+		"templates/layouts/base.html": `<body class="{{with .Page}}page-{{.Section}}{{else with .Section}}list-{{.Name}}{{end}}">{{with .Page}}{{param . "kicker"}}{{end}}{{.Content}}</body>`,
+		"templates/layouts/page.html": `<article>{{safeHTML .Page.Body}}</article>`,
+		"templates/layouts/list.html": `<ul>{{range .Pages}}<li>{{.Title}}</li>{{end}}</ul>`,
+		"content/blog/one.md":         "---\n{\"title\": \"One\", \"kicker\": \"News\"}\n---\n\nOne.\n",
+	})
+
+	stats, err := Build(Options{ConfigPath: configPath})
+	if err != nil {
+		t.Fatalf("build failed: %v", err)
+	}
+	assertContains(t, readOutput(t, stats, "blog", "one", "index.html"), `<body class="page-blog">News<article>`)
+	assertContains(t, readOutput(t, stats, "blog", "index.html"), `<body class="list-blog"><ul>`)
+}
+
+func TestBuildParamFuncWarning(t *testing.T) {
+	configPath := writeSite(t, map[string]string{
+		"templates/layouts/base.html": `{{.Content}}`,
+		"templates/layouts/page.html": `[{{param .Page "meta.reviewer"}}]`,
+		"templates/layouts/list.html": ``,
+		"content/blog/one.md":         "---\n{\"title\": \"One\"}\n---\n\nOne.\n",
+	})
+
+	stats, err := Build(Options{ConfigPath: configPath})
+	if err != nil {
+		t.Fatalf("build failed: %v", err)
+	}
+	assertContains(t, readOutput(t, stats, "blog", "one", "index.html"), "[]")
+	if len(stats.Warnings) != 1 {
+		t.Fatalf("expected 1 warning, got %q", stats.Warnings)
+	}
+	assertContains(t, stats.Warnings[0], `param "meta.reviewer" is not set`)
+}
+
+func TestBuildBlockLayouts(t *testing.T) {
+	configPath := writeSite(t, map[string]string{
+		"templates/layouts/base.html": `<head>{{block "head" .}}<title>{{.Title}}</title>{{end}}</head><main>{{block "main" .}}{{.Content}}{{end}}</main>`,
+		"templates/layouts/blog.html": `{{define "head"}}<title>Blog: {{.Page.Title}}</title>{{end}}{{define "main"}}<article>{{safeHTML .Page.Body}}</article>{{end}}`,
+		"templates/layouts/page.html": `<div>{{safeHTML .Page.Body}}</div>`,
+		"templates/layouts/list.html": `{{define "main"}}<ul>{{range .Pages}}<li>{{.Title}}</li>{{end}}</ul>{{end}}`,
+		"content/blog/one.md":         "---\n{\"title\": \"One\"}\n---\n\nOne.\n",
+		"content/about.md":            "---\n{\"title\": \"About\"}\n---\n\nAbout.\n",
+	})
+
+	stats, err := Build(Options{ConfigPath: configPath})
+	if err != nil {
+		t.Fatalf("build failed: %v", err)
+	}
+	assertContains(t, readOutput(t, stats, "blog", "one", "index.html"), "<head><title>Blog: One</title></head><main><article><p>One.</p>")
+	assertContains(t, readOutput(t, stats, "about", "index.html"), "<head><title>About</title></head><main><div><p>About.</p>")
+	assertContains(t, readOutput(t, stats, "blog", "index.html"), "<main><ul><li>One</li></ul></main>")
+}
+
+func TestBuildPageLayout(t *testing.T) {
+	configPath := writeSite(t, map[string]string{
+		"templates/layouts/landing.html": `<section class="landing">{{safeHTML .Page.Body}}</section>`,
+		"content/blog/promo.md":          "---\n{\"title\": \"Promo\", \"layout\": \"landing\"}\n---\n\nBuy now.\n",
+		"content/blog/post.md":           "---\n{\"title\": \"Post\"}\n---\n\nRegular.\n",
+	})
+
+	stats, err := Build(Options{ConfigPath: configPath})
+	if err != nil {
+		t.Fatalf("build failed: %v", err)
+	}
+	assertContains(t, readOutput(t, stats, "blog", "promo", "index.html"), `<section class="landing"><p>Buy now.</p>`)
+	if strings.Contains(readOutput(t, stats, "blog", "post", "index.html"), "landing") {
+		t.Error("expected pages without a layout field to keep the default layout")
+	}
+
+	configPath = writeSite(t, map[string]string{
+		"content/about.md": "---\n{\"title\": \"About\", \"layout\": \"missing\"}\n---\n\nAbout.\n",
+	})
+	if _, err := Build(Options{ConfigPath: configPath}); err == nil || !strings.Contains(err.Error(), `layout "missing" not found`) {
+		t.Errorf("expected a missing layout error, got %v", err)
+	}
+}
+
+func TestBuildLazyImages(t *testing.T) {
+	var photo bytes.Buffer
+	if err := png.Encode(&photo, image.NewRGBA(image.Rect(0, 0, 20, 10))); err != nil {
+		t.Fatal(err)
+	}
+	configPath := writeSite(t, map[string]string{
+		"site.json":                   `{"name": "Test", "baseURL": "https://example.com", "markdown": {"lazyImages": true}}`,
+		"content/blog/trip/index.md":  "---\ntitle: Trip\n---\n\n![Photo](photo.png)\n\n![Remote](https://example.com/x.png)\n",
+		"content/blog/trip/photo.png": photo.String(),
+	})
+
+	stats, err := Build(Options{ConfigPath: configPath})
+	if err != nil {
+		t.Fatalf("build failed: %v", err)
+	}
+
+	html := readOutput(t, stats, "blog", "trip", "index.html")
+	assertContains(t, html, `<img src="/blog/trip/photo.png" alt="Photo" width="20" height="10" loading="lazy">`)
+	assertContains(t, html, `<img src="https://example.com/x.png" alt="Remote" loading="lazy">`)
+}
+
+func TestBuildResizeImage(t *testing.T) {
+	var photo bytes.Buffer
+	if err := png.Encode(&photo, image.NewRGBA(image.Rect(0, 0, 20, 10))); err != nil {
+		t.Fatal(err)
+	}
+	configPath := writeSite(t, map[string]string{
+		"templates/layouts/base.html": `{{.Content}}`,
+		"templates/layouts/page.html": `{{with .Page.Resource "photo.png"}}<img src="{{.Resize "10x"}}">{{end}}`,
+		"templates/layouts/list.html": ``,
+		"content/blog/trip/index.md":  "---\ntitle: Trip\n---\n",
+		"content/blog/trip/photo.png": photo.String(),
+	})
+	cacheDir := filepath.Join(filepath.Dir(configPath), ".canopy-cache")
+
+	// A dry run plans the resized image without writing it or the cache
+	stats, err := Build(Options{ConfigPath: configPath, DryRun: true})
+	if err != nil {
+		t.Fatalf("dry run failed: %v", err)
+	}
+	planned := false
+	for _, file := range stats.Files {
+		planned = planned || (strings.HasPrefix(file.Path, "blog/trip/photo_10x.") && file.Size > 0)
+	}
+	if !planned {
+		t.Errorf("dry run files = %v, want the resized image", stats.Files)
+	}
+	if _, err := os.Stat(cacheDir); !os.IsNotExist(err) {
+		t.Errorf("dry run should not create the image cache, stat err = %v", err)
+	}
+
+	stats, err = Build(Options{ConfigPath: configPath})
+	if err != nil {
+		t.Fatalf("build failed: %v", err)
+	}
+
+	html := readOutput(t, stats, "blog", "trip", "index.html")
+	start := strings.Index(html, `src="`)
+	end := strings.Index(html, `">`)
+	if start == -1 || end == -1 {
+		t.Fatalf("expected an img tag, got %q", html)
+	}
+	url := html[start+len(`src="`) : end]
+	if !strings.HasPrefix(url, "/blog/trip/photo_10x.") {
+		t.Fatalf("url = %q, want /blog/trip/photo_10x.<hash>.png", url)
+	}
+
+	resized, err := os.Open(filepath.Join(stats.Output, filepath.FromSlash(url)))
+	if err != nil {
+		t.Fatalf("expected the resized image in the output: %v", err)
+	}
+	defer resized.Close()
+	config, err := png.DecodeConfig(resized)
+	if err != nil || config.Width != 10 || config.Height != 5 {
+		t.Errorf("resized image = %dx%d (%v), want 10x5", config.Width, config.Height, err)
+	}
+	if _, err := os.Stat(filepath.Join(cacheDir, "images")); err != nil {
+		t.Errorf("expected the image cache under .canopy-cache: %v", err)
+	}
+}
+
+func TestBuildFigureSrcset(t *testing.T) {
+	var photo bytes.Buffer
+	if err := png.Encode(&photo, image.NewRGBA(image.Rect(0, 0, 1000, 500))); err != nil {
+		t.Fatal(err)
+	}
+	configPath := writeSite(t, map[string]string{
+		"content/blog/trip/index.md":  "---\ntitle: Trip\n---\n\n{{< figure src=\"photo.png\" alt=\"Coast\" width=\"600\" >}}\n\n{{< figure src=\"photo.png\" widths=\"300\" sizes=\"50vw\" >}}\n\n{{< figure src=\"https://example.com/remote.png\" >}}\n",
+		"content/blog/trip/photo.png": photo.String(),
+	})
+
+	stats, err := Build(Options{ConfigPath: configPath})
+	if err != nil {
+		t.Fatalf("build failed: %v", err)
+	}
+
+	html := readOutput(t, stats, "blog", "trip", "index.html")
+	srcset := regexp.MustCompile(`srcset="/blog/trip/photo_480x\.[0-9a-f]{8}\.png 480w, /blog/trip/photo_800x\.[0-9a-f]{8}\.png 800w, /blog/trip/photo\.png 1000w" sizes="\(max-width: 600px\) 100vw, 600px" width="600" alt="Coast"`)
+	if !srcset.MatchString(html) {
+		t.Errorf("expected default widths below the original, got:\n%s", html)
+	}
+	assertContains(t, html, `.png 300w, /blog/trip/photo.png 1000w" sizes="50vw" alt=""`)
+	assertContains(t, html, `<img src="https://example.com/remote.png" alt="">`)
+}
+
+func TestBuildInsertTOC(t *testing.T) {
+	configPath := writeSite(t, map[string]string{
+		"site.json":                   `{"name": "Test", "baseURL": "https://example.com", "markdown": {"tocMinLevel": 2, "tocMaxLevel": 2}}`,
+		"templates/layouts/base.html": `{{.Content}}`,
+		"templates/layouts/page.html": `{{safeHTML .Page.Body}}`,
+		"templates/layouts/list.html": ``,
+		"content/docs/on.md":          "---\ntitle: On\ntoc: true\n---\n\n## First\n\n### Nested\n",
+		"content/docs/off.md":         "---\ntitle: Off\n---\n\n## First\n",
+	})
+
+	stats, err := Build(Options{ConfigPath: configPath})
+	if err != nil {
+		t.Fatalf("build failed: %v", err)
+	}
+	on := readOutput(t, stats, "docs", "on", "index.html")
+	assertContains(t, on, "<nav class=\"toc\">\n<ul>\n<li><a href=\"#first\">First</a></li>\n</ul>\n</nav>")
+	if strings.Contains(readOutput(t, stats, "docs", "off", "index.html"), `class="toc"`) {
+		t.Error("expected no TOC on pages without toc: true")
+	}
+}
+
+func TestBuildExternalLinks(t *testing.T) {
+	configPath := writeSite(t, map[string]string{
+		"site.json":          `{"name": "Test", "baseURL": "https://example.com/", "markdown": {"externalLinkTarget": "_blank", "externalLinkRel": "noopener noreferrer"}}`,
+		"content/pages/a.md": "---\n{\"title\": \"A\"}\n---\n\n[Go](https://go.dev/) and [us](https://example.com/pages/b/).\n",
+	})
+
+	stats, err := Build(Options{ConfigPath: configPath})
+	if err != nil {
+		t.Fatalf("build failed: %v", err)
+	}
+
+	html := readOutput(t, stats, "pages", "a", "index.html")
+	assertContains(t, html, `<a href="https://go.dev/" target="_blank" rel="noopener noreferrer">Go</a>`)
+	assertContains(t, html, `<a href="https://example.com/pages/b/">us</a>`)
+}
+
+func TestBuildDiagramLanguages(t *testing.T) {
+	files := map[string]string{
+		"templates/layouts/base.html": `{{.Content}}`,
+		"templates/layouts/page.html": `{{safeHTML .Page.Body}}`,
+		"templates/layouts/list.html": ``,
+		"content/docs/flow.md":        "---\ntitle: Flow\n---\n\n```mermaid\ngraph LR\n```\n\n```dot\ndigraph {}\n```\n",
+	}
+	stats, err := Build(Options{ConfigPath: writeSite(t, files)})
+	if err != nil {
+		t.Fatalf("build failed: %v", err)
+	}
+	html := readOutput(t, stats, "docs", "flow", "index.html")
+	assertContains(t, html, `<pre class="mermaid">graph LR</pre>`)
+	assertContains(t, html, `<pre><code class="language-dot">`)
+
+	files["site.json"] = `{"name": "Test", "baseURL": "https://example.com", "markdown": {"diagramLanguages": ["dot"]}}`
+	stats, err = Build(Options{ConfigPath: writeSite(t, files)})
+	if err != nil {
+		t.Fatalf("build failed: %v", err)
+	}
+	html = readOutput(t, stats, "docs", "flow", "index.html")
+	assertContains(t, html, `<pre class="dot">digraph {}</pre>`)
+	assertContains(t, html, `<pre><code class="language-mermaid">`)
+}
+
+func TestBuildPageType(t *testing.T) {
+	configPath := writeSite(t, map[string]string{
+		"templates/layouts/base.html":   `{{.Content}}`,
+		"templates/layouts/blog.html":   `blog {{.Page.Type}}`,
+		"templates/layouts/review.html": `review {{.Page.Type}} in {{.Page.Section}}`,
+		"templates/layouts/list.html":   ``,
+		"content/blog/post.md":          "---\ntitle: Post\n---\n",
+		"content/blog/film.md":          "---\ntitle: Film\ntype: review\n---\n",
+		"content/blog/other.md":         "---\ntitle: Other\ntype: unknown\n---\n",
+	})
+
+	stats, err := Build(Options{ConfigPath: configPath})
+	if err != nil {
+		t.Fatalf("build failed: %v", err)
+	}
+	assertContains(t, readOutput(t, stats, "blog", "post", "index.html"), "blog blog")
+	assertContains(t, readOutput(t, stats, "blog", "film", "index.html"), "review review in blog")
+	assertContains(t, readOutput(t, stats, "blog", "other", "index.html"), "blog unknown")
+}
+
+func TestBuildTheme(t *testing.T) {
+	configPath := writeSite(t, map[string]string{
+		"site.json": `{"name": "Test", "baseURL": "https://example.com", "theme": "plain"}`,
+		"themes/plain/templates/layouts/base.html": `<div class="theme">{{.Content}}</div>`,
+		"themes/plain/templates/layouts/page.html": `<p>theme page</p>`,
+		"themes/plain/templates/layouts/list.html": `<p>theme list</p>`,
+		"themes/plain/static/css/theme.css":        "theme {}",
+		"themes/plain/static/logo.txt":             "theme logo",
+		"templates/layouts/page.html":              `<p>site page</p>`,
+		"static/logo.txt":                          "site logo",
+		"content/about.md":                         "---\n{\"title\": \"About\"}\n---\n\nAbout.\n",
+	})
+
+	stats, err := Build(Options{ConfigPath: configPath})
+	if err != nil {
+		t.Fatalf("build failed: %v", err)
+	}
+	assertContains(t, readOutput(t, stats, "about", "index.html"), `<div class="theme"><p>site page</p></div>`)
+	assertContains(t, readOutput(t, stats, "index.html"), `<div class="theme"><p>theme list</p></div>`)
+	assertContains(t, readOutput(t, stats, "css", "theme.css"), "theme {}")
+	assertContains(t, readOutput(t, stats, "logo.txt"), "site logo")
+
+	configPath = writeSite(t, map[string]string{
+		"site.json":        `{"name": "Test", "baseURL": "https://example.com", "theme": "missing"}`,
+		"content/about.md": "---\n{\"title\": \"About\"}\n---\n\nAbout.\n",
+	})
+	if _, err := Build(Options{ConfigPath: configPath}); err == nil || !strings.Contains(err.Error(), `theme "missing" not found`) {
+		t.Errorf("expected a missing theme error, got %v", err)
+	}
+
+	for _, theme := range []string{"../..", "..", "plain/../../x"} {
+		configPath = writeSite(t, map[string]string{
+			"site.json":        `{"name": "Test", "baseURL": "https://example.com", "theme": "` + theme + `"}`,
+			"content/about.md": "---\n{\"title\": \"About\"}\n---\n\nAbout.\n",
+		})
+		if _, err := Build(Options{ConfigPath: configPath}); err == nil || !strings.Contains(err.Error(), `invalid theme "`+theme+`"`) {
+			t.Errorf("expected an invalid theme error for %q, got %v", theme, err)
+		}
+	}
+}
+
+func TestBuildI18n(t *testing.T) {
+	configPath := writeSite(t, map[string]string{
+		"site.json":                   `{"name": "Test", "baseURL": "https://example.com", "language": "en", "languages": {"en": {"name": "English"}, "fr": {"name": "Français"}}}`,
+		"i18n/en.json":                `{"read_more": "Read more", "home": "Home"}`,
+		"i18n/fr.json":                `{"read_more": "Lire la suite"}`,
+		"templates/layouts/base.html": `<html lang="{{with .Page}}{{.Lang}}{{end}}">{{.Content}}</html>`,
+		"templates/layouts/page.html": `{{.T "read_more"}}|{{.T "home"}}|{{.T "missing"}}{{range .Page.Translations}}|{{.Lang}}:{{.URL}}{{end}}`,
+		"templates/layouts/list.html": `{{.T "read_more"}}`,
+		"content/blog/post.md":        "---\n{\"title\": \"Post\"}\n---\n\nHi.\n",
+		"content/blog/post.fr.md":     "---\n{\"title\": \"Billet\"}\n---\n\nSalut.\n",
+		"content/fr/about.md":         "---\n{\"title\": \"À propos\"}\n---\n\nSalut.\n",
+	})
+
+	var warned []string
+	stats, err := Build(Options{ConfigPath: configPath, Warn: func(message string) { warned = append(warned, message) }})
+	if err != nil {
+		t.Fatalf("build failed: %v", err)
+	}
+	assertContains(t, readOutput(t, stats, "blog", "post", "index.html"), `<html lang="en">Read more|Home|missing|fr:/fr/blog/post/</html>`)
+	assertContains(t, readOutput(t, stats, "fr", "blog", "post", "index.html"), `<html lang="fr">Lire la suite|Home|missing|en:/blog/post/</html>`)
+	assertContains(t, readOutput(t, stats, "fr", "about", "index.html"), `<html lang="fr">`)
+	assertContains(t, readOutput(t, stats, "blog", "index.html"), "Read more")
+
+	en := readOutput(t, stats, "rss.xml")
+	assertContains(t, en, "<link>https://example.com/blog/post/</link>")
+	if strings.Contains(en, "/fr/") {
+		t.Errorf("default feed should leave out French pages:\n%s", en)
+	}
+	fr := readOutput(t, stats, "fr", "rss.xml")
+	assertContains(t, fr, "<language>fr</language>")
+	assertContains(t, fr, "<link>https://example.com/fr/blog/post/</link>")
+
+	var missing int
+	for _, message := range warned {
+		if strings.Contains(message, `"missing"`) {
+			missing++
+		}
+	}
+	if missing != 2 {
+		t.Errorf("expected one missing-key warning per language, got %q", warned)
+	}
+}
+
+func TestLinkTranslations(t *testing.T) {
+	site := core.NewSite(core.DefaultConfig())
+	fr := &core.Page{Lang: "fr", TranslationKey: "about.md"}
+	en := &core.Page{Lang: "en", TranslationKey: "about.md"}
+	solo := &core.Page{Lang: "en", TranslationKey: "solo.md"}
+	site.Pages = []*core.Page{fr, en, solo}
+
+	linkTranslations(site)
+
+	if got := site.Translations["about.md"]; len(got) != 2 || got[0] != en || got[1] != fr {
+		t.Errorf("Translations[about.md] = %v, want [en fr]", got)
+	}
+	if _, ok := site.Translations["solo.md"]; ok {
+		t.Error("expected untranslated pages to be left out of Translations")
+	}
+	if len(en.Translations) != 1 || en.Translations[0] != fr {
+		t.Errorf("en.Translations = %v, want [fr]", en.Translations)
+	}
+}
+
+func TestBuildStructuredData(t *testing.T) {
+	configPath := writeSite(t, map[string]string{
+		"site.json":           `{"name": "Test", "baseURL": "https://example.com", "structuredData": true, "build": {"fileModTime": false}, "authors": {"jane": {"name": "Jane Doe", "url": "https://jane.example"}}}`,
+		"content/blog/one.md": "---\n{\"title\": \"One\", \"description\": \"First\", \"author\": \"jane\", \"date\": \"2026-01-01T00:00:00Z\"}\n---\n\nOne.\n",
+	})
+
+	stats, err := Build(Options{ConfigPath: configPath})
+	if err != nil {
+		t.Fatalf("build failed: %v", err)
+	}
+	want := `<script type="application/ld+json">{"@context":"https://schema.org","@type":"Article","headline":"One","description":"First","datePublished":"2026-01-01T00:00:00Z","dateModified":"2026-01-01T00:00:00Z","author":[{"@type":"Person","name":"Jane Doe","url":"https://jane.example"}],"mainEntityOfPage":{"@type":"WebPage","@id":"https://example.com/blog/one/"}}</script>`
+	assertContains(t, readOutput(t, stats, "blog", "one", "index.html"), want)
+	if strings.Contains(readOutput(t, stats, "blog", "index.html"), "application/ld+json") {
+		t.Error("expected no article JSON-LD on list pages")
+	}
+
+	configPath = writeSite(t, map[string]string{
+		"content/blog/one.md": "---\n{\"title\": \"One\"}\n---\n\nOne.\n",
+	})
+	stats, err = Build(Options{ConfigPath: configPath})
+	if err != nil {
+		t.Fatalf("build failed: %v", err)
+	}
+	if strings.Contains(readOutput(t, stats, "blog", "one", "index.html"), "application/ld+json") {
+		t.Error("expected no JSON-LD unless structuredData is set")
+	}
+}
+
+func TestBuildPageKinds(t *testing.T) {
+	configPath := writeSite(t, map[string]string{
+		"templates/layouts/base.html": `{{.Content}}`,
+		"templates/layouts/page.html": `{{.Page.Kind}}`,
+		"templates/layouts/list.html": `{{range .Pages}}{{.Kind}}:{{.Title}} {{end}}`,
+		"templates/layouts/home.html": `{{range .Site.RegularPages}}{{.Title}} {{end}}|{{range .Site.PagesOfKind "term"}}{{.URL}}={{.Title}} {{end}}|{{.Site.Home.URL}}|{{len .Site.AllPages}}`,
+		"content/blog/one.md":         "---\n{\"title\": \"One\", \"tags\": [\"go\"]}\n---\n\nOne.\n",
+		"content/blog/two.md":         "---\n{\"title\": \"Two\", \"tags\": [\"go\", \"web\"]}\n---\n\nTwo.\n",
+	})
+
+	stats, err := Build(Options{ConfigPath: configPath})
+	if err != nil {
+		t.Fatalf("build failed: %v", err)
+	}
+	// 2 pages + home + blog section + tags taxonomy + 2 terms
+	assertContains(t, readOutput(t, stats, "index.html"), "One Two |/tags/go/=Go /tags/web/=Web |/|7")
+	assertContains(t, readOutput(t, stats, "blog", "one", "index.html"), "page")
+	assertContains(t, readOutput(t, stats, "tags", "index.html"), "term:Go term:Web")
+}
+
+func TestBuildReadingTime(t *testing.T) {
+	configPath := writeSite(t, map[string]string{
+		"site.json":                   `{"name": "Test", "baseURL": "https://example.com", "readingSpeed": 4}`,
+		"templates/layouts/base.html": `{{.Content}}`,
+		"templates/layouts/page.html": `{{.Page.WordCount}} words, {{.Page.ReadingTime}} min`,
+		"templates/layouts/list.html": ``,
+		"content/blog/one.md":         "---\n{\"title\": \"One\"}\n---\n\nOne two three four five.\n\n静的サイト\n",
+	})
+
+	stats, err := Build(Options{ConfigPath: configPath})
+	if err != nil {
+		t.Fatalf("build failed: %v", err)
+	}
+	assertContains(t, readOutput(t, stats, "blog", "one", "index.html"), "10 words, 3 min")
+}
+
+func TestBuildSimpleFrontMatterScalars(t *testing.T) {
+	configPath := writeSite(t, map[string]string{
+		"templates/layouts/base.html": `{{.Content}}`,
+		"templates/layouts/page.html": `{{with .Page.Params}}{{if .featured}}featured{{else}}plain{{end}} {{if .comments}}comments{{end}} {{printf "%T:%v %T:%v %T:%v %T:%v %T:%v %T:%v" .count .count .ratio .ratio .label .label .quoted .quoted .zip .zip .offset .offset}}{{end}}`,
+		"templates/layouts/list.html": ``,
+		"content/blog/one.md":         "---\ntitle: One\nfeatured: false\ncomments: On\ncount: 3\nratio: 1.5\nlabel: 1.2.3\nquoted: \"false\"\nzip: 01234\noffset: +3\n---\n\nOne.\n",
+		"content/blog/two.md":         "---\ntitle: Two\ndraft: no\n---\n\nTwo.\n",
+	})
+
+	stats, err := Build(Options{ConfigPath: configPath})
+	if err != nil {
+		t.Fatalf("build failed: %v", err)
+	}
+	assertContains(t, readOutput(t, stats, "blog", "one", "index.html"), "plain comments int:3 float64:1.5 string:1.2.3 string:false string:01234 string:&#43;3")
+	readOutput(t, stats, "blog", "two", "index.html")
+}
+
+func TestBuildSimpleFrontMatterLists(t *testing.T) {
+	configPath := writeSite(t, map[string]string{
+		"templates/layouts/base.html": `{{.Content}}`,
+		"templates/layouts/page.html": `tags={{range .Page.Tags}}[{{.}}]{{end}} related={{range .Page.Params.related}}[{{.}}]{{end}} tools={{range .Page.Params.tools}}[{{.}}]{{end}} after={{.Page.Params.after}}`,
+		"templates/layouts/list.html": ``,
+		"content/blog/one.md":         "---\ntitle: One\ntags: [go, web]\nrelated:\n  - two\n  - \"three\"\ntools: [hugo, \"zola\"]\nafter: yes\n---\n\nOne.\n",
+	})
+
+	stats, err := Build(Options{ConfigPath: configPath})
+	if err != nil {
+		t.Fatalf("build failed: %v", err)
+	}
+	assertContains(t, readOutput(t, stats, "blog", "one", "index.html"), "tags=[go][web] related=[two][three] tools=[hugo][zola] after=true")
+}
+
+func TestBuildSitemapLastMod(t *testing.T) {
+	configPath := writeSite(t, map[string]string{
+		"site.json":           `{"name": "Test", "baseURL": "https://example.com", "build": {"fileModTime": false}}`,
+		"content/blog/one.md": "---\n{\"title\": \"One\", \"date\": \"2026-01-01T00:00:00Z\", \"lastmod\": \"2026-02-01T00:00:00Z\"}\n---\n\nOne.\n",
+		"content/blog/two.md": "---\n{\"title\": \"Two\", \"date\": \"2026-01-05T00:00:00Z\"}\n---\n\nTwo.\n",
+		"content/about.md":    "---\n{\"title\": \"About\"}\n---\n\nAbout.\n",
+	})
+
+	stats, err := Build(Options{ConfigPath: configPath})
+	if err != nil {
+		t.Fatalf("build failed: %v", err)
+	}
+
+	sitemap := readOutput(t, stats, "sitemap.xml")
+	assertContains(t, sitemap, "<loc>https://example.com/blog/one/</loc>\n    <lastmod>2026-02-01</lastmod>")
+	assertContains(t, sitemap, "<loc>https://example.com/blog/two/</loc>\n    <lastmod>2026-01-05</lastmod>")
+	assertContains(t, sitemap, "<loc>https://example.com/about/</loc>\n  </url>")
+}
+
+func TestBuildSitemapIndex(t *testing.T) {
+	files := map[string]string{
+		"site.json": `{"name": "Test", "baseURL": "https://example.com", "sitemap": {"maxURLs": 4}, "build": {"fileModTime": false}}`,
+	}
+	for i := 1; i <= 7; i++ {
+		files[fmt.Sprintf("content/pages/p%d.md", i)] = fmt.Sprintf("---\n{\"title\": \"P%d\", \"date\": \"2026-01-%02dT00:00:00Z\"}\n---\n\nBody.\n", i, i)
+	}
+	configPath := writeSite(t, files)
+
+	stats, err := Build(Options{ConfigPath: configPath})
+	if err != nil {
+		t.Fatalf("build failed: %v", err)
+	}
+
+	// 7 pages + home + section = 9 URLs -> 3 chunks of at most 4
+	index := readOutput(t, stats, "sitemap.xml")
+	assertContains(t, index, "<sitemapindex")
+	for i := 1; i <= 3; i++ {
+		assertContains(t, index, fmt.Sprintf("<loc>https://example.com/sitemap-%d.xml</loc>", i))
+
+		chunk := readOutput(t, stats, fmt.Sprintf("sitemap-%d.xml", i))
+		assertContains(t, chunk, "<urlset")
+		if n := strings.Count(chunk, "<url>"); n == 0 || n > 4 {
+			t.Errorf("sitemap-%d.xml has %d urls, want 1-4", i, n)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(stats.Output, "sitemap-4.xml")); !os.IsNotExist(err) {
+		t.Error("expected exactly three sitemap chunks")
+	}
+	assertContains(t, index, "<lastmod>2026-01-07</lastmod>")
+
+	// Below the threshold a single urlset is written
+	configPath = writeSite(t, map[string]string{
+		"content/pages/a.md": "---\n{\"title\": \"A\"}\n---\n\nBody.\n",
+	})
+	stats, err = Build(Options{ConfigPath: configPath})
+	if err != nil {
+		t.Fatalf("build failed: %v", err)
+	}
+	assertContains(t, readOutput(t, stats, "sitemap.xml"), "<urlset")
+	if _, err := os.Stat(filepath.Join(stats.Output, "sitemap-1.xml")); !os.IsNotExist(err) {
+		t.Error("expected no sitemap chunks below the threshold")
+	}
+}
+
+func TestLinkRelated(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2026, 1, d, 0, 0, 0, 0, time.UTC) }
+	page := &core.Page{Title: "Page", Tags: []string{"go", "web", "cli"}}
+	two := &core.Page{Title: "Two shared", Tags: []string{"go", "web"}, Date: day(1)}
+	oneOld := &core.Page{Title: "B one shared", Tags: []string{"cli"}, Date: day(1)}
+	oneNew := &core.Page{Title: "C one shared", Tags: []string{"go"}, Date: day(5)}
+	oneSameDay := &core.Page{Title: "A one shared", Tags: []string{"web"}, Date: day(1)}
+	none := &core.Page{Title: "Unrelated", Tags: []string{"rust"}}
+	draft := &core.Page{Title: "Draft", Tags: []string{"go", "web", "cli"}, Draft: true}
+
+	pages := []*core.Page{page, two, oneOld, oneNew, oneSameDay, none, draft}
+	linkRelated(pages, core.RelatedConfig{Limit: 4})
+
+	want := []*core.Page{two, oneNew, oneSameDay, oneOld}
+	if len(page.Related) != len(want) {
+		t.Fatalf("got %d related pages, want %d", len(page.Related), len(want))
+	}
+	for i := range want {
+		if page.Related[i] != want[i] {
+			t.Errorf("related[%d] = %q, want %q", i, page.Related[i].Title, want[i].Title)
+		}
+	}
+	if draft.Related != nil {
+		t.Errorf("expected drafts to have no related pages")
+	}
+}
+
+func TestBuildManifest(t *testing.T) {
+	files := map[string]string{
+		"content/blog/one.md": "---\n{\"title\": \"One\", \"date\": \"2026-01-01T00:00:00Z\"}\n---\n\nOne.\n",
+		"static/app.css":      "body {}",
+	}
+
+	stats, err := Build(Options{ConfigPath: writeSite(t, files), WriteManifest: true})
+	if err != nil {
+		t.Fatalf("build failed: %v", err)
+	}
+
+	var doc struct {
+		Stats struct {
+			Pages int `json:"pages"`
+		} `json:"stats"`
+		Files []struct {
+			URL    string `json:"url"`
+			Source string `json:"source"`
+			Output string `json:"output"`
+			Size   int64  `json:"size"`
+			Hash   string `json:"hash"`
+		} `json:"files"`
+	}
+	if err := json.Unmarshal([]byte(readOutput(t, stats, "build-manifest.json")), &doc); err != nil {
+		t.Fatalf("parsing manifest: %v", err)
+	}
+	if doc.Stats.Pages != 1 {
+		t.Errorf("stats.pages = %d, want 1", doc.Stats.Pages)
+	}
+
+	found := map[string]bool{}
+	for _, f := range doc.Files {
+		found[f.Output] = true
+		if f.Output == "blog/one/index.html" {
+			html := readOutput(t, stats, "blog", "one", "index.html")
+			sum := sha256.Sum256([]byte(html))
+			if f.URL != "/blog/one/" || f.Source != "content/blog/one.md" || f.Size != int64(len(html)) || f.Hash != hex.EncodeToString(sum[:]) {
+				t.Errorf("unexpected page entry: %+v", f)
+			}
+		}
+		if f.Output == "app.css" && f.Source != "static/app.css" {
+			t.Errorf("unexpected static entry: %+v", f)
+		}
+	}
+	for _, want := range []string{"blog/one/index.html", "sitemap.xml", "app.css"} {
+		if !found[want] {
+			t.Errorf("manifest missing %s", want)
+		}
+	}
+
+	if strings.Contains(readOutput(t, stats, "sitemap.xml"), "build-manifest") {
+		t.Error("manifest should not appear in the sitemap")
+	}
+
+	stats, err = Build(Options{ConfigPath: writeSite(t, files)})
+	if err != nil {
+		t.Fatalf("build failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(stats.Output, "build-manifest.json")); !os.IsNotExist(err) {
+		t.Error("manifest written without being requested")
+	}
+}
+
+func TestBuildSectionSortByTitle(t *testing.T) {
+	configPath := writeSite(t, map[string]string{
+		"site.json":                 `{"name": "Test", "baseURL": "https://example.com", "sections": {"glossary": {"sortBy": "title"}}}`,
+		"content/glossary/zeta.md":  "---\n{\"title\": \"Zeta\", \"date\": \"2026-01-03T00:00:00Z\"}\n---\n\nZ.\n",
+		"content/glossary/alpha.md": "---\n{\"title\": \"alpha\", \"date\": \"2026-01-01T00:00:00Z\"}\n---\n\nA.\n",
+		"content/glossary/mu.md":    "---\n{\"title\": \"Mu\", \"date\": \"2026-01-02T00:00:00Z\"}\n---\n\nM.\n",
+	})
+
+	stats, err := Build(Options{ConfigPath: configPath})
+	if err != nil {
+		t.Fatalf("build failed: %v", err)
+	}
+
+	html := readOutput(t, stats, "glossary", "index.html")
+	alpha, mu, zeta := strings.Index(html, ">alpha<"), strings.Index(html, ">Mu<"), strings.Index(html, ">Zeta<")
+	if alpha == -1 || mu == -1 || zeta == -1 || !(alpha < mu && mu < zeta) {
+		t.Fatalf("expected alphabetical order, got positions alpha=%d mu=%d zeta=%d", alpha, mu, zeta)
+	}
+}
+
+func TestBuildSectionSortByParamMissing(t *testing.T) {
+	for _, order := range []string{"asc", "desc"} {
+		t.Run(order, func(t *testing.T) {
+			configPath := writeSite(t, map[string]string{
+				"site.json":                   `{"name": "Test", "baseURL": "https://example.com", "sections": {"docs": {"sortBy": "rank", "sortOrder": "` + order + `"}}}`,
+				"templates/layouts/base.html": `{{.Content}}`,
+				"templates/layouts/page.html": ``,
+				"templates/layouts/list.html": `{{range .Pages}}[{{.Title}}]{{end}}`,
+				"content/docs/a.md":           "---\ntitle: A\nrank: 1\n---\n",
+				"content/docs/b.md":           "---\ntitle: B\n---\n",
+				"content/docs/c.md":           "---\ntitle: C\nrank: 2\n---\n",
+			})
+
+			stats, err := Build(Options{ConfigPath: configPath})
+			if err != nil {
+				t.Fatalf("build failed: %v", err)
+			}
+			want := "[A][C][B]"
+			if order == "desc" {
+				want = "[C][A][B]"
+			}
+			if got := readOutput(t, stats, "docs", "index.html"); got != want {
+				t.Errorf("order = %q, want %q with the unranked page last", got, want)
+			}
+		})
+	}
+}
+
+func TestBuildSectionPrevNext(t *testing.T) {
+	configPath := writeSite(t, map[string]string{
+		"site.json":                   `{"name": "Test", "baseURL": "https://example.com", "sections": {"docs": {"sortBy": "filename"}}}`,
+		"templates/layouts/base.html": `{{.Content}}`,
+		"templates/layouts/page.html": `prev={{with .Page.PrevPage}}{{.Title}}{{end}} next={{with .Page.NextPage}}{{.Title}}{{end}}`,
+		"templates/layouts/list.html": `{{range .Pages}}[{{.Title}}]{{end}}`,
+		"content/docs/02-setup.md":    "---\ntitle: Setup\ndate: 2026-01-01\n---\n",
+		"content/docs/01-intro.md":    "---\ntitle: Intro\ndate: 2026-01-02\n---\n",
+		"content/docs/03-deploy.md":   "---\ntitle: Deploy\ndate: 2026-01-03\n---\n",
+	})
+
+	stats, err := Build(Options{ConfigPath: configPath})
+	if err != nil {
+		t.Fatalf("build failed: %v", err)
+	}
+	assertContains(t, readOutput(t, stats, "docs", "index.html"), "[Intro][Setup][Deploy]")
+	assertContains(t, readOutput(t, stats, "docs", "01-intro", "index.html"), "prev= next=Setup")
+	assertContains(t, readOutput(t, stats, "docs", "02-setup", "index.html"), "prev=Intro next=Deploy")
+	assertContains(t, readOutput(t, stats, "docs", "03-deploy", "index.html"), "prev=Setup next=")
+}
+
+func TestBuildCollectsWarnings(t *testing.T) {
+	configPath := writeSite(t, map[string]string{
+		"content/about.md": "---\n{\"title\": \"About\"}\n---\n\n{{< missing >}}\n",
+	})
+
+	var sunk []string
+	stats, err := Build(Options{
+		ConfigPath: configPath,
+		Warn:       func(message string) { sunk = append(sunk, message) },
+	})
+	if err != nil {
+		t.Fatalf("build failed: %v", err)
+	}
+
+	if len(stats.Warnings) != 1 || len(sunk) != 1 {
+		t.Fatalf("expected one warning in stats and sink, got %v and %v", stats.Warnings, sunk)
+	}
+	assertContains(t, stats.Warnings[0], `about.md: rendering shortcode "missing" failed`)
+}
+
+func TestBuildAutoTOC(t *testing.T) {
+	configPath := writeSite(t, map[string]string{
+		"site.json":            `{"name": "Test", "baseURL": "https://example.com", "autoTOC": true}`,
+		"content/docs/one.md":  "---\n{\"title\": \"One\"}\n---\n\n## Install\n\nText.\n",
+		"content/docs/skip.md": "---\n{\"title\": \"Skip\", \"toc\": false}\n---\n\n## Install\n\nText.\n",
+	})
+
+	stats, err := Build(Options{ConfigPath: configPath})
+	if err != nil {
+		t.Fatalf("build failed: %v", err)
+	}
+
+	assertContains(t, readOutput(t, stats, "docs", "one", "index.html"), `<li class="toc-level-2"><a href="#install">Install</a></li>`)
+	if strings.Contains(readOutput(t, stats, "docs", "skip", "index.html"), `class="page-toc"`) {
+		t.Error("expected toc: false to opt out of the automatic TOC")
+	}
+}
+
+func TestBuildMarkdownConfig(t *testing.T) {
+	configPath := writeSite(t, map[string]string{
+		"site.json":          `{"name": "Test", "baseURL": "https://example.com", "markdown": {"defaultCodeLang": "bash", "headingAnchors": true}}`,
+		"content/pages/a.md": "---\n{\"title\": \"A\"}\n---\n\n## Usage\n\n```\nplain\n```\n",
+	})
+
+	stats, err := Build(Options{ConfigPath: configPath})
+	if err != nil {
+		t.Fatalf("build failed: %v", err)
+	}
+
+	page := readOutput(t, stats, "pages", "a", "index.html")
+	assertContains(t, page, `<pre><code class="language-bash">plain</code></pre>`)
+	assertContains(t, page, `<h2 id="usage">Usage <a class="heading-anchor" href="#usage">#</a></h2>`)
+}
+
+func TestBuildFailOnEmptySite(t *testing.T) {
+	configPath := writeSite(t, map[string]string{
+		"content/.keep": "",
+	})
+
+	_, err := Build(Options{ConfigPath: configPath})
+	if err == nil || !strings.Contains(err.Error(), "contentDir") {
+		t.Fatalf("expected an empty site error mentioning contentDir, got %v", err)
+	}
+
+	configPath = writeSite(t, map[string]string{
+		"site.json":     `{"name": "Test", "baseURL": "https://example.com", "build": {"failOnEmptySite": false}}`,
+		"content/.keep": "",
+	})
+	if _, err := Build(Options{ConfigPath: configPath}); err != nil {
+		t.Fatalf("build with failOnEmptySite disabled failed: %v", err)
+	}
+}
+
+func TestBuildCascade(t *testing.T) {
+	configPath := writeSite(t, map[string]string{
+		"site.json": `{"name": "Test", "baseURL": "https://example.com", "sections": {
+			"docs": {"defaults": {"product": "default", "audience": "all"}, "cascade": {"layout": "doc", "product": "docs"}},
+			"docs/guides": {"cascade": {"product": "guides"}}
+		}}`,
+		"templates/layouts/base.html":  `{{.Content}}`,
+		"templates/layouts/page.html":  `page {{.Page.Params.product}}`,
+		"templates/layouts/doc.html":   `doc {{.Page.Params.product}} {{.Page.Params.audience}}`,
+		"templates/layouts/list.html":  ``,
+		"content/docs/intro.md":        "---\ntitle: Intro\n---\n",
+		"content/docs/guides/setup.md": "---\ntitle: Setup\n---\n",
+		"content/docs/guides/own.md":   "---\ntitle: Own\nproduct: own\nlayout: page\n---\n",
+	})
+
+	stats, err := Build(Options{ConfigPath: configPath})
+	if err != nil {
+		t.Fatalf("build failed: %v", err)
+	}
+	assertContains(t, readOutput(t, stats, "docs", "intro", "index.html"), "doc docs all")
+	assertContains(t, readOutput(t, stats, "docs", "setup", "index.html"), "doc guides all")
+	assertContains(t, readOutput(t, stats, "docs", "own", "index.html"), "page own")
+}
+
+func TestBuildCascadeTypedFields(t *testing.T) {
+	configPath := writeSite(t, map[string]string{
+		"site.json": `{"name": "Test", "baseURL": "https://example.com", "sections": {
+			"docs": {"defaults": {"weight": 9, "tags": ["docs"]}},
+			"docs/guides": {"cascade": {"draft": true, "weight": 5, "tags": ["guide"]}}
+		}}`,
+		"templates/layouts/base.html":   `{{.Content}}`,
+		"templates/layouts/page.html":   `{{.Page.Weight}} {{.Page.Tags}} {{len .Page.Params}}`,
+		"templates/layouts/list.html":   ``,
+		"content/docs/intro.md":         "---\ntitle: Intro\n---\n",
+		"content/docs/guides/hidden.md": "---\ntitle: Hidden\n---\n",
+		"content/docs/guides/shown.md":  "---\ntitle: Shown\ndraft: false\nweight: 0\n---\n",
+	})
+
+	stats, err := Build(Options{ConfigPath: configPath})
+	if err != nil {
+		t.Fatalf("build failed: %v", err)
+	}
+	assertContains(t, readOutput(t, stats, "docs", "intro", "index.html"), "9 [docs] 0")
+	// The page's own draft: false and weight: 0 beat the cascade
+	assertContains(t, readOutput(t, stats, "docs", "shown", "index.html"), "0 [guide] 0")
+	if _, err := os.Stat(filepath.Join(stats.Output, "docs", "hidden")); !os.IsNotExist(err) {
+		t.Errorf("cascaded draft: true should hide the page, stat err = %v", err)
+	}
+}
+
+func TestBuildSlugify(t *testing.T) {
+	files := map[string]string{
+		"templates/layouts/base.html": `{{.Content}}`,
+		"templates/layouts/page.html": `{{.Page.Title}}`,
+		"templates/layouts/list.html": ``,
+		"content/blog/My Post.md":     "---\ntitle: Spaces\n---\n",
+		"content/blog/custom.md":      "---\ntitle: Custom\nslug: \"Hello World\"\n---\n",
+		"content/blog/café.md":        "---\ntitle: Accent\n---\n",
+		"content/blog/my_notes.md":    "---\ntitle: Underscores\n---\n",
+	}
+	stats, err := Build(Options{ConfigPath: writeSite(t, files)})
+	if err != nil {
+		t.Fatalf("build failed: %v", err)
+	}
+	assertContains(t, readOutput(t, stats, "blog", "my-post", "index.html"), "Spaces")
+	assertContains(t, readOutput(t, stats, "blog", "hello-world", "index.html"), "Custom")
+	assertContains(t, readOutput(t, stats, "blog", "cafe", "index.html"), "Accent")
+	assertContains(t, readOutput(t, stats, "blog", "my-notes", "index.html"), "Underscores")
+
+	files["site.json"] = `{"name": "Test", "baseURL": "https://example.com", "build": {"strictSlugs": true}}`
+	_, err = Build(Options{ConfigPath: writeSite(t, files)})
+	if err == nil || !strings.Contains(err.Error(), `slug "Hello World" is not URL-safe`) {
+		t.Fatalf("expected a strict slug error, got %v", err)
+	}
+}
+
+func TestBuildNavValidation(t *testing.T) {
+	nav := `[
+		{"title": "Blog", "url": "/blog/", "weight": 20},
+		{"title": "Docs", "url": "/docs/", "weight": 10, "children": [
+			{"title": "Install", "url": "/docs/install/", "weight": 2},
+			{"title": "Intro", "url": "/docs/", "weight": 1}
+		]},
+		{"title": "Home", "url": "/"}
+	]`
+	configPath := writeSite(t, map[string]string{
+		"site.json":          `{"name": "Test", "baseURL": "https://example.com", "nav": ` + nav + `}`,
+		"content/pages/a.md": "---\n{\"title\": \"A\"}\n---\n\nBody.\n",
+	})
+
+	stats, err := Build(Options{ConfigPath: configPath})
+	if err != nil {
+		t.Fatalf("build failed: %v", err)
+	}
+
+	home := readOutput(t, stats, "index.html")
+	if !(strings.Index(home, `href="/">Home`) < strings.Index(home, `href="/docs/">Docs`) &&
+		strings.Index(home, `href="/docs/">Docs`) < strings.Index(home, `href="/blog/">Blog`)) {
+		t.Errorf("expected nav sorted by weight, got:\n%s", home)
+	}
+	if len(stats.Warnings) != 1 {
+		t.Fatalf("expected one duplicate url warning, got %v", stats.Warnings)
+	}
+	assertContains(t, stats.Warnings[0], "duplicates the url /docs/")
+
+	configPath = writeSite(t, map[string]string{
+		"site.json": `{"name": "Test", "baseURL": "https://example.com", "nav": [{"title": "Ok", "url": "/"}, {"weight": 3}]}`,
+	})
+	if _, err := Build(Options{ConfigPath: configPath}); err == nil || !strings.Contains(err.Error(), "nav[1] has neither a title nor a url") {
+		t.Errorf("expected nav validation error, got %v", err)
+	}
+}
+
+func TestBuildMenus(t *testing.T) {
+	configPath := writeSite(t, map[string]string{
+		"site.json": `{"name": "Test", "baseURL": "https://example.com",
+			"nav": [{"title": "Blog", "url": "/blog/", "weight": 10}],
+			"menus": {"footer": [{"title": "Privacy", "url": "/privacy/", "weight": 20}]}}`,
+		"templates/layouts/base.html": `<nav>{{range .Site.Menus.main}}<a{{if .IsActive $.URL}} class="active"{{else if .HasActive $.URL}} class="open"{{end}} href="{{.URL}}">{{.Title}}</a>{{end}}</nav>` +
+			`<footer>{{range .Site.Menus.footer}}[{{.Title}} {{.Weight}}]{{end}}</footer>`,
+		"templates/layouts/page.html": ``,
+		"templates/layouts/list.html": ``,
+		"content/about.md":            "---\ntitle: About\nweight: 5\nmenu: main\n---\n",
+		"content/blog/post.md":        "---\n{\"title\": \"Post\", \"menu\": {\"footer\": {\"title\": \"Latest\", \"weight\": 1}}}\n---\n",
+	})
+
+	stats, err := Build(Options{ConfigPath: configPath})
+	if err != nil {
+		t.Fatalf("build failed: %v", err)
+	}
+
+	about := readOutput(t, stats, "about", "index.html")
+	assertContains(t, about, `<a class="active" href="/about/">About</a><a href="/blog/">Blog</a>`)
+	assertContains(t, about, `[Latest 1][Privacy 20]`)
+	assertContains(t, readOutput(t, stats, "blog", "post", "index.html"), `<a href="/about/">About</a><a class="open" href="/blog/">Blog</a>`)
+	assertContains(t, readOutput(t, stats, "blog", "index.html"), `<a class="active" href="/blog/">Blog</a>`)
+
+	configPath = writeSite(t, map[string]string{
+		"site.json": `{"name": "Test", "baseURL": "https://example.com", "menus": {"footer": [{"weight": 1}]}}`,
+	})
+	if _, err := Build(Options{ConfigPath: configPath}); err == nil || !strings.Contains(err.Error(), "menus.footer[0] has neither a title nor a url") {
+		t.Errorf("expected menu validation error, got %v", err)
+	}
+}
+
+func TestBuildAuthors(t *testing.T) {
+	configPath := writeSite(t, map[string]string{
+		"site.json":         `{"name": "Test", "baseURL": "https://example.com", "authors": {"jane": {"name": "Jane Doe", "bio": "Writes about Go.", "email": "jane@example.com"}}}`,
+		"content/blog/a.md": "---\n{\"title\": \"A\", \"date\": \"2026-01-02T00:00:00Z\", \"authors\": [\"jane\", \"sam\"]}\n---\n\nBody.\n",
+		"content/blog/b.md": "---\n{\"title\": \"B\", \"date\": \"2026-01-01T00:00:00Z\", \"author\": \"jane\"}\n---\n\nBody.\n",
+	})
+
+	stats, err := Build(Options{ConfigPath: configPath})
+	if err != nil {
+		t.Fatalf("build failed: %v", err)
+	}
+
+	jane := readOutput(t, stats, "authors", "jane", "index.html")
+	assertContains(t, jane, "<h1>Jane Doe</h1>")
+	assertContains(t, jane, "<p>Writes about Go.</p>")
+	assertContains(t, jane, `href="/blog/a/"`)
+	assertContains(t, jane, `href="/blog/b/"`)
+
+	assertContains(t, readOutput(t, stats, "authors", "sam", "index.html"), "<h1>sam</h1>")
+	assertContains(t, readOutput(t, stats, "authors", "index.html"), `href="/authors/jane/">Jane Doe</a>`)
+	assertContains(t, readOutput(t, stats, "blog", "a", "index.html"), `<a href="/authors/jane/" rel="author">Jane Doe</a>`)
+	rss := readOutput(t, stats, "rss.xml")
+	assertContains(t, rss, `xmlns:dc="http://purl.org/dc/elements/1.1/"`)
+	assertContains(t, rss, "<author>jane@example.com (Jane Doe)</author>")
+	assertContains(t, rss, "<dc:creator>Jane Doe</dc:creator>")
+	assertContains(t, rss, "<dc:creator>sam</dc:creator>")
+	if strings.Contains(rss, "<author>sam") || strings.Contains(rss, ", sam") {
+		t.Errorf("authors without an email belong in dc:creator only:\n%s", rss)
+	}
+}
+
+func TestBuildSeries(t *testing.T) {
+	page := func(title string, order int) string {
+		return fmt.Sprintf("---\n{\"title\": %q, \"series\": \"Go Basics\", \"seriesOrder\": %d}\n---\n\nBody.\n", title, order)
+	}
+	configPath := writeSite(t, map[string]string{
+		"content/guides/install.md":   page("Install", 1),
+		"content/blog/types.md":       page("Types", 3),
+		"content/guides/variables.md": page("Variables", 2),
+		"content/guides/other.md":     "---\n{\"title\": \"Other\"}\n---\n\nBody.\n",
+	})
+
+	stats, err := Build(Options{ConfigPath: configPath})
+	if err != nil {
+		t.Fatalf("build failed: %v", err)
+	}
+
+	variables := readOutput(t, stats, "guides", "variables", "index.html")
+	assertContains(t, variables, `Part 2 of 3 in <a href="/series/go-basics/">Go Basics</a>`)
+	assertContains(t, variables, `<a href="/guides/install/" rel="prev">`)
+	assertContains(t, variables, `<a href="/blog/types/" rel="next">`)
+
+	first := readOutput(t, stats, "guides", "install", "index.html")
+	if strings.Contains(first, `rel="prev"`) {
+		t.Error("expected no previous link on the first part")
+	}
+	if strings.Contains(readOutput(t, stats, "guides", "other", "index.html"), `class="series"`) {
+		t.Error("expected no series nav on pages outside a series")
+	}
+
+	index := readOutput(t, stats, "series", "go-basics", "index.html")
+	if !(strings.Index(index, "Install") < strings.Index(index, "Variables") && strings.Index(index, "Variables") < strings.Index(index, "Types")) {
+		t.Errorf("expected series index in series order, got:\n%s", index)
+	}
+	assertContains(t, readOutput(t, stats, "series", "index.html"), `href="/series/go-basics/">Go Basics</a>`)
+}
+
+func TestBuildCustomTaxonomy(t *testing.T) {
+	configPath := writeSite(t, map[string]string{
+		"site.json":         `{"name": "Test", "baseURL": "https://example.com", "taxonomies": [{"plural": "categories", "singular": "category"}]}`,
+		"content/blog/a.md": "---\n{\"title\": \"A\", \"tags\": [\"go\"], \"categories\": [\"tutorials\", \"news\"]}\n---\n\nBody.\n",
+		"content/blog/b.md": "---\ntitle: B\ncategories: tutorials\n---\n\nBody.\n",
+	})
+
+	stats, err := Build(Options{ConfigPath: configPath})
+	if err != nil {
+		t.Fatalf("build failed: %v", err)
+	}
+
+	index := readOutput(t, stats, "categories", "index.html")
+	assertContains(t, index, "<h1>Categories</h1>")
+	assertContains(t, index, `href="/categories/news/">Category: news</a>`)
+	assertContains(t, index, `href="/categories/tutorials/">Category: tutorials</a>`)
+
+	term := readOutput(t, stats, "categories", "tutorials", "index.html")
+	assertContains(t, term, "<h1>Category: tutorials</h1>")
+	assertContains(t, term, `href="/blog/a/"`)
+	assertContains(t, term, `href="/blog/b/"`)
+
+	// Tags keep their built-in naming
+	assertContains(t, readOutput(t, stats, "tags", "index.html"), "<h1>Tags</h1>")
+	assertContains(t, readOutput(t, stats, "tags", "go", "index.html"), "<h1>Go</h1>")
+}
+
+func TestBuildConfigFormats(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+	}{
+		{"site.yaml", "name: Test\nbaseURL: https://example.com\ntitle: Formats Site\nsearch:\n  enabled: false\n"},
+		{"site.yml", "name: Test\nbaseURL: https://example.com\ntitle: Formats Site\nsearch:\n  enabled: false\n"},
+		{"site.toml", "name = \"Test\"\nbaseURL = \"https://example.com\"\ntitle = \"Formats Site\"\n\n[search]\nenabled = false\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configPath := writeSite(t, map[string]string{
+				tt.name:              tt.config,
+				"content/pages/a.md": "---\n{\"title\": \"A\"}\n---\n\nBody.\n",
+			})
+
+			stats, err := Build(Options{ConfigPath: configPath})
+			if err != nil {
+				t.Fatalf("build failed: %v", err)
+			}
+
+			assertContains(t, readOutput(t, stats, "index.html"), "Formats Site")
+			if _, err := os.Stat(filepath.Join(stats.Output, "search.json")); !os.IsNotExist(err) {
+				t.Error("expected nested search.enabled to disable the search index")
+			}
+		})
+	}
+}
+
+func TestBuildConfigParseErrorNamesFile(t *testing.T) {
+	configPath := writeSite(t, map[string]string{
+		"site.yaml": "name: Test\n  baseURL: broken\n",
+	})
+
+	_, err := Build(Options{ConfigPath: configPath})
+	if err == nil {
+		t.Fatal("expected a config parse error")
+	}
+	assertContains(t, err.Error(), "parsing config site.yaml")
+}
+
+// writeSite creates a throwaway site from the given files and returns its
+// config path. A minimal site.json is supplied unless a site config in
+// any supported format is provided.
+func writeSite(t *testing.T, files map[string]string) string {
+	t.Helper()
+	root := t.TempDir()
+
+	configName := "site.json"
+	for _, name := range []string{"site.json", "site.yaml", "site.yml", "site.toml"} {
+		if _, ok := files[name]; ok {
+			configName = name
+			break
+		}
+	}
+	if _, ok := files[configName]; !ok {
+		files[configName] = `{"name": "Test", "baseURL": "https://example.com"}`
+	}
+
+	for name, contents := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("creating %s: %v", name, err)
+		}
+		if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+			t.Fatalf("writing %s: %v", name, err)
+		}
+	}
+
+	return filepath.Join(root, configName)
+}
+
+func readOutput(t *testing.T, stats *Stats, parts ...string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(append([]string{stats.Output}, parts...)...))
+	if err != nil {
+		t.Fatalf("reading output: %v", err)
+	}
+	return string(data)
+}
+
+// readTree returns every file under dir keyed by its slash-separated path.
+func readTree(t *testing.T, dir string) map[string]string {
+	t.Helper()
+	files := make(map[string]string)
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(rel)] = string(data)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("reading output tree: %v", err)
+	}
+	return files
+}
+
 func testdataPath(t *testing.T, parts ...string) string {
 	t.Helper()
 	_, file, _, ok := runtime.Caller(0)
@@ -0,0 +1,99 @@
+package build
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// assetManifestFile maps original static paths to fingerprinted ones.
+const assetManifestFile = "asset-manifest.json"
+
+// fingerprintAssets hashes the static files matching patterns and returns
+// their slash-separated paths mapped to fingerprinted names, e.g.
+// "css/site.css" -> "css/site.1a2b3c4d.css". A path in more than one
+// static dir is hashed from the first, matching CopyStatic. Missing
+// static directories yield no assets.
+func fingerprintAssets(staticDirs []string, patterns []string) (map[string]string, error) {
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("fingerprint pattern %q: %w", pattern, err)
+		}
+	}
+
+	assets := make(map[string]string)
+	if len(patterns) == 0 {
+		return assets, nil
+	}
+
+	seen := make(map[string]bool)
+	for _, staticDir := range staticDirs {
+		err := filepath.WalkDir(staticDir, func(file string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) && file == staticDir {
+					return fs.SkipAll
+				}
+				return err
+			}
+			if d.IsDir() {
+				return nil
+			}
+
+			rel, err := filepath.Rel(staticDir, file)
+			if err != nil {
+				return err
+			}
+			rel = filepath.ToSlash(rel)
+			if seen[rel] {
+				return nil
+			}
+			seen[rel] = true
+			if !matchesAny(patterns, rel) {
+				return nil
+			}
+
+			data, err := os.ReadFile(file)
+			if err != nil {
+				return err
+			}
+			sum := sha256.Sum256(data)
+			assets[rel] = fingerprintedName(rel, hex.EncodeToString(sum[:4]))
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return assets, nil
+}
+
+func matchesAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// fingerprintedName inserts hash before the extension: css/site.css ->
+// css/site.<hash>.css.
+func fingerprintedName(name, hash string) string {
+	ext := path.Ext(name)
+	return strings.TrimSuffix(name, ext) + "." + hash + ext
+}
+
+// renderAssetManifest encodes the fingerprint map with sorted keys.
+func renderAssetManifest(assets map[string]string) (string, error) {
+	data, err := json.MarshalIndent(assets, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data) + "\n", nil
+}
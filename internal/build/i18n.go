@@ -0,0 +1,43 @@
+package build
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// i18nDir holds translation tables, one <lang>.json per language.
+const i18nDir = "i18n"
+
+// loadTranslations reads each i18n/<lang>.json under rootDir, a flat
+// object of key -> translated text. A missing i18n directory yields none.
+func loadTranslations(rootDir string) (map[string]map[string]string, error) {
+	entries, err := os.ReadDir(filepath.Join(rootDir, i18nDir))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	tables := make(map[string]map[string]string)
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || filepath.Ext(name) != ".json" {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(rootDir, i18nDir, name))
+		if err != nil {
+			return nil, err
+		}
+		var table map[string]string
+		if err := json.Unmarshal(data, &table); err != nil {
+			return nil, fmt.Errorf("%s/%s: %w", i18nDir, name, err)
+		}
+		tables[strings.TrimSuffix(name, ".json")] = table
+	}
+	return tables, nil
+}
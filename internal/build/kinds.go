@@ -0,0 +1,76 @@
+package build
+
+import (
+	"sort"
+
+	"github.com/shanepadgett/canopy/internal/core"
+)
+
+// indexListPages creates a page for each list the build renders (home,
+// sections, taxonomy indexes, and their terms, authors and series
+// included) and sets site.AllPages to the content pages followed by them.
+// The list pages are returned by URL so index listings can reuse them.
+func indexListPages(site *core.Site, taxonomies []core.TaxonomyConfig) map[string]*core.Page {
+	byURL := make(map[string]*core.Page)
+	all := append([]*core.Page(nil), site.Pages...)
+	add := func(kind, section, title, url string) *core.Page {
+		page := &core.Page{Kind: kind, Section: section, Title: title, URL: url}
+		byURL[url] = page
+		all = append(all, page)
+		return page
+	}
+
+	// Multilingual sites have a home and section lists per language
+	cfg := site.Config
+	for _, lang := range siteLangs(cfg) {
+		add(core.KindHome, "", cfg.Title, cfg.LangPrefix(lang)+"/").Lang = lang
+	}
+
+	for _, name := range sortedKeys(site.Sections) {
+		if name == "" {
+			continue
+		}
+		for _, lang := range siteLangs(cfg) {
+			if len(pagesInLang(site.Sections[name].Pages, lang)) > 0 {
+				add(core.KindSection, name, site.Sections[name].Title, cfg.LangPrefix(lang)+"/"+name+"/").Lang = lang
+			}
+		}
+	}
+
+	for _, taxonomy := range taxonomies {
+		terms := site.Taxonomies[taxonomy.Plural]
+		if len(terms) == 0 {
+			continue
+		}
+		add(core.KindTaxonomy, taxonomy.Plural, taxonomy.Title, "/"+taxonomy.Plural+"/")
+		for _, term := range sortedKeys(terms) {
+			add(core.KindTerm, taxonomy.Plural, taxonomy.TermTitle(term), "/"+taxonomy.Plural+"/"+term+"/")
+		}
+	}
+
+	if len(site.Authors) > 0 {
+		add(core.KindTaxonomy, "authors", core.NewSection("authors").Title, "/authors/")
+		for _, id := range sortedKeys(site.Authors) {
+			add(core.KindTerm, "authors", site.Author(id).Name, "/authors/"+id+"/")
+		}
+	}
+
+	if len(site.Series) > 0 {
+		add(core.KindTaxonomy, "series", core.NewSection("series").Title, "/series/")
+		for _, name := range sortedKeys(site.Series) {
+			add(core.KindTerm, "series", name, site.Series[name][0].SeriesURL())
+		}
+	}
+
+	site.AllPages = all
+	return byURL
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
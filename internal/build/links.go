@@ -0,0 +1,105 @@
+package build
+
+import (
+	"fmt"
+	"html"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var (
+	linkAttrPattern = regexp.MustCompile(`(?i)\s(?:href|src)\s*=\s*(?:"([^"]*)"|'([^']*)')`)
+	idAttrPattern   = regexp.MustCompile(`(?i)\sid\s*=\s*(?:"([^"]*)"|'([^']*)')`)
+)
+
+// checkLinks finds internal href and src targets in the rendered pages
+// that match no written file, and #fragments that match no id on the
+// page they point to. External links (anything with a scheme or host
+// other than baseURL's) and anchor-only links are skipped. Problems are
+// returned sorted as "<page>: <message>".
+func checkLinks(baseURL string, outputs map[string]string, files []WrittenFile) []string {
+	written := make(map[string]bool, len(files))
+	for _, file := range files {
+		written[file.Path] = true
+	}
+
+	base, _ := url.Parse(strings.TrimRight(baseURL, "/") + "/")
+	ids := make(map[string]map[string]bool)
+
+	var problems []string
+	for pageURL, doc := range outputs {
+		pageBase := &url.URL{Path: pageURL}
+		seen := make(map[string]bool)
+
+		for _, match := range linkAttrPattern.FindAllStringSubmatch(doc, -1) {
+			raw := html.UnescapeString(match[1] + match[2])
+			if raw == "" || strings.HasPrefix(raw, "#") || seen[raw] {
+				continue
+			}
+			seen[raw] = true
+
+			ref, err := url.Parse(raw)
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("%s: malformed link %q", pageURL, raw))
+				continue
+			}
+			if ref.Scheme != "" || ref.Host != "" {
+				if base == nil || base.Host == "" || ref.Host != base.Host {
+					continue
+				}
+				// An absolute link to this site is checked like a local one
+				ref.Path = "/" + strings.TrimPrefix(strings.TrimPrefix(ref.Path, base.Path), "/")
+				ref.Scheme, ref.Host = "", ""
+			}
+
+			target := pageBase.ResolveReference(ref)
+			destURL, ok := resolveOutput(target.Path, written)
+			if !ok {
+				problems = append(problems, fmt.Sprintf("%s: broken link %q", pageURL, raw))
+				continue
+			}
+
+			if target.Fragment == "" {
+				continue
+			}
+			dest, ok := outputs[destURL]
+			if !ok {
+				continue
+			}
+			if ids[destURL] == nil {
+				ids[destURL] = collectIDs(dest)
+			}
+			if !ids[destURL][target.Fragment] {
+				problems = append(problems, fmt.Sprintf("%s: link %q points to a missing anchor", pageURL, raw))
+			}
+		}
+	}
+
+	sort.Strings(problems)
+	return problems
+}
+
+// resolveOutput maps a URL path to a written file, trying the clean URL
+// forms pages are written with. It returns the page URL for HTML pages.
+func resolveOutput(urlPath string, written map[string]bool) (string, bool) {
+	rel := strings.TrimPrefix(urlPath, "/")
+	switch {
+	case rel == "" || strings.HasSuffix(rel, "/"):
+		return urlPath, written[rel+"index.html"]
+	case written[rel+"/index.html"]:
+		return urlPath + "/", true
+	case rel == "index.html" || strings.HasSuffix(rel, "/index.html"):
+		return strings.TrimSuffix(urlPath, "index.html"), written[rel]
+	}
+	return urlPath, written[rel]
+}
+
+func collectIDs(doc string) map[string]bool {
+	ids := make(map[string]bool)
+	for _, match := range idAttrPattern.FindAllStringSubmatch(doc, -1) {
+		ids[html.UnescapeString(match[1]+match[2])] = true
+	}
+	return ids
+}
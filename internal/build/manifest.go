@@ -0,0 +1,88 @@
+package build
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// manifestFile is the name of the optional build manifest.
+const manifestFile = "build-manifest.json"
+
+type manifest struct {
+	Stats manifestStats   `json:"stats"`
+	Files []manifestEntry `json:"files"`
+}
+
+type manifestStats struct {
+	Pages    int    `json:"pages"`
+	Sections int    `json:"sections"`
+	Tags     int    `json:"tags"`
+	Duration string `json:"duration"`
+}
+
+type manifestEntry struct {
+	URL    string `json:"url"`
+	Source string `json:"source,omitempty"`
+	Output string `json:"output"`
+	Size   int64  `json:"size"`
+	Hash   string `json:"hash"`
+}
+
+// renderManifest describes every written file. Sources maps page URLs to
+// their content source paths; copied files report their own source
+// relative to rootDir.
+func renderManifest(rootDir, outputDir string, files []WrittenFile, sources map[string]string, stats *Stats) (string, error) {
+	entries := make([]manifestEntry, 0, len(files))
+	for _, file := range files {
+		data, err := os.ReadFile(filepath.Join(outputDir, filepath.FromSlash(file.Path)))
+		if err != nil {
+			return "", err
+		}
+		sum := sha256.Sum256(data)
+
+		url := "/" + file.Path
+		if file.Path == "index.html" || strings.HasSuffix(file.Path, "/index.html") {
+			url = strings.TrimSuffix(url, "index.html")
+		}
+
+		source := sources[url]
+		if file.Source != "" {
+			if rel, err := filepath.Rel(rootDir, file.Source); err == nil {
+				source = filepath.ToSlash(rel)
+			}
+		}
+
+		entries = append(entries, manifestEntry{
+			URL:    url,
+			Source: source,
+			Output: file.Path,
+			Size:   file.Size,
+			Hash:   hex.EncodeToString(sum[:]),
+		})
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Output < entries[j].Output
+	})
+
+	doc := manifest{
+		Stats: manifestStats{
+			Pages:    stats.Pages,
+			Sections: stats.Sections,
+			Tags:     stats.Tags,
+			Duration: stats.Duration.String(),
+		},
+		Files: entries,
+	}
+
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data) + "\n", nil
+}
@@ -0,0 +1,91 @@
+package build
+
+import (
+	"sort"
+
+	"github.com/shanepadgett/canopy/internal/core"
+)
+
+// buildMenus resolves the site's menus: Config.Nav as "main", then
+// Config.Menus, then pages registering through their menu front matter.
+// Each menu is sorted by weight, keeping configured entries ahead of
+// pages with the same weight.
+func buildMenus(cfg core.Config, pages []*core.Page) map[string][]*core.MenuEntry {
+	menus := make(map[string][]*core.MenuEntry)
+	if len(cfg.Nav) > 0 {
+		menus["main"] = menuEntries(cfg.Nav)
+	}
+	for name, items := range cfg.Menus {
+		menus[name] = append(menus[name], menuEntries(items)...)
+	}
+
+	for _, page := range pages {
+		for name, entry := range pageMenus(page) {
+			menus[name] = append(menus[name], entry)
+		}
+	}
+
+	for _, entries := range menus {
+		sort.SliceStable(entries, func(i, j int) bool {
+			return entries[i].Weight < entries[j].Weight
+		})
+	}
+	return menus
+}
+
+func menuEntries(items []core.NavItem) []*core.MenuEntry {
+	entries := make([]*core.MenuEntry, 0, len(items))
+	for _, item := range items {
+		entries = append(entries, &core.MenuEntry{
+			Title:    item.Title,
+			URL:      item.URL,
+			Weight:   item.Weight,
+			Children: menuEntries(item.Children),
+		})
+	}
+	return entries
+}
+
+// pageMenus reads a page's menu front matter: a menu name, a list of
+// names, or an object mapping names to {"title", "weight"} overrides.
+// Entries default to the page's title and weight.
+func pageMenus(page *core.Page) map[string]*core.MenuEntry {
+	entry := func(spec any) *core.MenuEntry {
+		e := &core.MenuEntry{Title: page.Title, URL: page.URL, Weight: page.Weight, Page: page}
+		if fields, ok := spec.(map[string]any); ok {
+			if title, ok := fields["title"].(string); ok && title != "" {
+				e.Title = title
+			}
+			switch weight := fields["weight"].(type) {
+			case int:
+				e.Weight = weight
+			case float64:
+				e.Weight = int(weight)
+			}
+		}
+		return e
+	}
+
+	menus := make(map[string]*core.MenuEntry)
+	switch value := page.Params["menu"].(type) {
+	case string:
+		if value != "" {
+			menus[value] = entry(nil)
+		}
+	case []string:
+		for _, name := range value {
+			menus[name] = entry(nil)
+		}
+	case []any:
+		for _, name := range value {
+			if s, ok := name.(string); ok && s != "" {
+				menus[s] = entry(nil)
+			}
+		}
+	case map[string]any:
+		for name, spec := range value {
+			menus[name] = entry(spec)
+		}
+	}
+	return menus
+}
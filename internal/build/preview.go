@@ -0,0 +1,64 @@
+package build
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/shanepadgett/canopy/internal/content"
+	"github.com/shanepadgett/canopy/internal/core"
+	"github.com/shanepadgett/canopy/internal/images"
+	"github.com/shanepadgett/canopy/internal/template"
+)
+
+// RenderSinglePage loads one content file and renders it to full HTML
+// through its layout, without building the rest of the site. sourcePath
+// is relative to the content directory (or absolute). The page is the
+// only one on the site model, so listings and cross-page links that
+// templates derive from the site only see this page. Drafts are rendered.
+func RenderSinglePage(rootDir string, cfg core.Config, sourcePath string) (string, error) {
+	loader := content.NewLoader(rootDir, cfg, true)
+	page, err := loader.LoadPage(sourcePath)
+	if err != nil {
+		return "", fmt.Errorf("loading content: %w", err)
+	}
+
+	cacheDir := resolveDir(rootDir, cfg.CacheDir, "images")
+	images.NewProcessor(filepath.Join(rootDir, cfg.ContentDir), cacheDir).Attach([]*core.Page{page})
+
+	site := core.NewSite(cfg)
+	site.Pages = []*core.Page{page}
+	site.AllPages = site.Pages
+	site.PagesBySource[filepath.ToSlash(page.SourcePath)] = page
+	section := core.NewSection(page.Section)
+	section.Pages = site.Pages
+	site.Sections[page.Section] = section
+	if site.I18n, err = loadTranslations(rootDir); err != nil {
+		return "", fmt.Errorf("loading translations: %w", err)
+	}
+
+	templateDirs, _, err := siteDirs(rootDir, cfg)
+	if err != nil {
+		return "", err
+	}
+	engine, err := template.NewEngine(templateDirs...)
+	if err != nil {
+		return "", fmt.Errorf("loading templates: %w", err)
+	}
+	engine.SetAssets(cfg.BaseURL, nil)
+	engine.SetTranslations(site)
+
+	opts := markdownOptions(cfg.Markdown, cfg.BaseURL)
+	opts.Site = site
+	opts.ShortcodeRenderer = engine
+	opts.RootDir = rootDir
+	// Other pages aren't loaded, so refs to them can't resolve here; a
+	// full build reports those.
+	_ = renderMarkdown(page, opts)
+	page.Assets = engine.ShortcodeAssets(page.UsedShortcodes)
+
+	html, err := engine.RenderPage(page, site)
+	if err != nil {
+		return "", fmt.Errorf("rendering %s: %w", page.SourcePath, err)
+	}
+	return html, nil
+}
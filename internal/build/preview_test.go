@@ -0,0 +1,31 @@
+package build
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/shanepadgett/canopy/internal/config"
+)
+
+func TestRenderSinglePage(t *testing.T) {
+	configPath := testdataPath(t, "testdata", "site", "site.json")
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		t.Fatalf("loading config: %v", err)
+	}
+	cfg.CacheDir = t.TempDir()
+
+	html, err := RenderSinglePage(filepath.Dir(configPath), cfg, filepath.Join("guides", "shortcodes.md"))
+	if err != nil {
+		t.Fatalf("RenderSinglePage failed: %v", err)
+	}
+
+	assertContains(t, html, "<!DOCTYPE html>")
+	assertContains(t, html, "<h1>Shortcodes</h1>")
+	assertContains(t, html, `class="shortcode-callout`)
+	assertContains(t, html, `class="shortcode-toc"`)
+
+	if _, err := RenderSinglePage(filepath.Dir(configPath), cfg, "missing.md"); err == nil {
+		t.Error("expected an error for a missing source file")
+	}
+}
@@ -0,0 +1,72 @@
+package build
+
+import (
+	"sort"
+
+	"github.com/shanepadgett/canopy/internal/core"
+)
+
+// linkRelated fills Page.Related by scoring pages on shared tags.
+// Ties are broken by date (newest first), then title.
+func linkRelated(pages []*core.Page, cfg core.RelatedConfig) {
+	if cfg.Limit <= 0 {
+		return
+	}
+
+	type candidate struct {
+		page  *core.Page
+		score int
+	}
+
+	for _, page := range pages {
+		if page.Draft {
+			continue
+		}
+
+		tags := make(map[string]bool, len(page.Tags))
+		for _, tag := range page.Tags {
+			tags[tag] = true
+		}
+
+		var candidates []candidate
+		for _, other := range pages {
+			if other == page || other.Draft {
+				continue
+			}
+
+			score := 0
+			for _, tag := range other.Tags {
+				if tags[tag] {
+					score++
+				}
+			}
+			if score == 0 {
+				continue
+			}
+			if cfg.SameSection && other.Section == page.Section {
+				score++
+			}
+			candidates = append(candidates, candidate{page: other, score: score})
+		}
+
+		sort.Slice(candidates, func(i, j int) bool {
+			ci, cj := candidates[i], candidates[j]
+			if ci.score != cj.score {
+				return ci.score > cj.score
+			}
+			if !ci.page.Date.Equal(cj.page.Date) {
+				return ci.page.Date.After(cj.page.Date)
+			}
+			return ci.page.Title < cj.page.Title
+		})
+
+		if len(candidates) > cfg.Limit {
+			candidates = candidates[:cfg.Limit]
+		}
+
+		page.Related = make([]*core.Page, len(candidates))
+		for i, c := range candidates {
+			page.Related[i] = c.page
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package build
+
+import (
+	"sort"
+
+	"github.com/shanepadgett/canopy/internal/core"
+)
+
+// linkSeries orders each series and fills SeriesPages, SeriesPrev, and
+// SeriesNext. Pages are ordered by SeriesOrder (pages without one come
+// last), then by date (oldest first), then by title.
+func linkSeries(series map[string][]*core.Page) {
+	for name, pages := range series {
+		sort.SliceStable(pages, func(i, j int) bool {
+			a, b := pages[i], pages[j]
+			if a.SeriesOrder != b.SeriesOrder {
+				if a.SeriesOrder == 0 || b.SeriesOrder == 0 {
+					return b.SeriesOrder == 0
+				}
+				return a.SeriesOrder < b.SeriesOrder
+			}
+			if !a.Date.Equal(b.Date) {
+				return a.Date.Before(b.Date)
+			}
+			return a.Title < b.Title
+		})
+		series[name] = pages
+
+		for i, page := range pages {
+			page.SeriesPages = pages
+			if i > 0 {
+				page.SeriesPrev = pages[i-1]
+			}
+			if i < len(pages)-1 {
+				page.SeriesNext = pages[i+1]
+			}
+		}
+	}
+}
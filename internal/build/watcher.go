@@ -0,0 +1,167 @@
+package build
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/shanepadgett/canopy/internal/core"
+)
+
+// Event reports that site sources changed and a rebuild is due.
+type Event struct {
+	// Changed lists created, modified, and removed paths relative to the
+	// site root, sorted.
+	Changed []string
+}
+
+// Watcher watches a site's content, templates, static files, and config
+// files and emits one debounced Event per burst of changes. It polls the
+// file system rather than relying on platform notification APIs, which
+// keeps Canopy dependency-free.
+type Watcher struct {
+	// Interval is how often the file system is scanned.
+	Interval time.Duration
+
+	// Debounce is how long sources must stay unchanged before an event
+	// is emitted, so a burst of editor saves triggers a single rebuild.
+	Debounce time.Duration
+
+	rootDir string
+	dirs    []string
+	events  chan Event
+}
+
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+// NewWatcher creates a watcher for the site at rootDir.
+func NewWatcher(rootDir string, cfg core.Config) *Watcher {
+	dirs := []string{
+		filepath.Join(rootDir, cfg.ContentDir),
+		filepath.Join(rootDir, cfg.TemplateDir),
+		filepath.Join(rootDir, cfg.StaticDir),
+	}
+	if cfg.Theme != "" {
+		dirs = append(dirs, filepath.Join(rootDir, "themes", cfg.Theme))
+	}
+	return &Watcher{
+		Interval: 50 * time.Millisecond,
+		Debounce: 100 * time.Millisecond,
+		rootDir:  rootDir,
+		dirs:     dirs,
+		events:   make(chan Event),
+	}
+}
+
+// Events returns the channel rebuild events are delivered on. It is
+// closed when Run returns.
+func (w *Watcher) Events() <-chan Event {
+	return w.events
+}
+
+// Run watches until ctx is cancelled. Changes present when Run starts
+// are not reported.
+func (w *Watcher) Run(ctx context.Context) {
+	defer close(w.events)
+
+	snapshot := w.scan()
+	pending := make(map[string]bool)
+	var lastChange time.Time
+
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			current := w.scan()
+			if changed := diffSnapshots(snapshot, current); len(changed) > 0 {
+				for _, path := range changed {
+					pending[path] = true
+				}
+				lastChange = now
+			}
+			snapshot = current
+
+			if len(pending) == 0 || now.Sub(lastChange) < w.Debounce {
+				continue
+			}
+
+			event := Event{Changed: make([]string, 0, len(pending))}
+			for path := range pending {
+				event.Changed = append(event.Changed, path)
+			}
+			sort.Strings(event.Changed)
+			pending = make(map[string]bool)
+
+			select {
+			case w.events <- event:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// scan records the state of every watched file, keyed by path relative
+// to the site root. Missing directories are skipped.
+func (w *Watcher) scan() map[string]fileState {
+	files := make(map[string]fileState)
+	add := func(path string, info fs.FileInfo) {
+		rel, err := filepath.Rel(w.rootDir, path)
+		if err != nil {
+			rel = path
+		}
+		files[filepath.ToSlash(rel)] = fileState{modTime: info.ModTime(), size: info.Size()}
+	}
+
+	for _, dir := range w.dirs {
+		filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return nil
+			}
+			if info, err := d.Info(); err == nil {
+				add(path, info)
+			}
+			return nil
+		})
+	}
+
+	// Config files, including environment overrides like site.production.json
+	entries, _ := os.ReadDir(w.rootDir)
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasPrefix(entry.Name(), "site.") {
+			continue
+		}
+		if info, err := entry.Info(); err == nil {
+			add(filepath.Join(w.rootDir, entry.Name()), info)
+		}
+	}
+
+	return files
+}
+
+// diffSnapshots returns paths that were added, removed, or modified.
+func diffSnapshots(before, after map[string]fileState) []string {
+	var changed []string
+	for path, state := range after {
+		if old, ok := before[path]; !ok || !old.modTime.Equal(state.modTime) || old.size != state.size {
+			changed = append(changed, path)
+		}
+	}
+	for path := range before {
+		if _, ok := after[path]; !ok {
+			changed = append(changed, path)
+		}
+	}
+	return changed
+}
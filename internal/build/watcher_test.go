@@ -0,0 +1,70 @@
+package build
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/shanepadgett/canopy/internal/core"
+)
+
+func TestWatcherDebouncesChanges(t *testing.T) {
+	configPath := writeSite(t, map[string]string{
+		"content/blog/a.md": "---\n{\"title\": \"A\"}\n---\n",
+		"static/old.css":    "body {}",
+	})
+	root := filepath.Dir(configPath)
+
+	w := NewWatcher(root, core.DefaultConfig())
+	w.Interval = 5 * time.Millisecond
+	w.Debounce = 60 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go w.Run(ctx)
+
+	// Let the watcher take its initial snapshot
+	time.Sleep(20 * time.Millisecond)
+
+	// A burst of saves, each well inside the debounce window
+	for i := 0; i < 5; i++ {
+		writeSiteFile(t, root, "content/blog/a.md", fmt.Sprintf("---\n{\"title\": \"A%d\"}\n---\n", i))
+		time.Sleep(10 * time.Millisecond)
+	}
+	writeSiteFile(t, root, "site.json", `{"name": "Changed", "baseURL": "https://example.com"}`)
+	if err := os.Remove(filepath.Join(root, "static", "old.css")); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case event := <-w.Events():
+		want := []string{"content/blog/a.md", "site.json", "static/old.css"}
+		if !reflect.DeepEqual(event.Changed, want) {
+			t.Errorf("Changed = %v, want %v", event.Changed, want)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for a rebuild event")
+	}
+
+	select {
+	case event := <-w.Events():
+		t.Fatalf("expected a single debounced event, got another: %v", event.Changed)
+	case <-time.After(150 * time.Millisecond):
+	}
+
+	cancel()
+	if _, ok := <-w.Events(); ok {
+		t.Error("expected the events channel to close after cancellation")
+	}
+}
+
+func writeSiteFile(t *testing.T, root, name, contents string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(root, filepath.FromSlash(name)), []byte(contents), 0o644); err != nil {
+		t.Fatalf("writing %s: %v", name, err)
+	}
+}
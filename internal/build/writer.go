@@ -1,17 +1,32 @@
 package build
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 	"io/fs"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 )
 
 // Writer handles writing output files.
 type Writer struct {
-	outputDir string
+	outputDir     string
+	ownedPath     string
+	warnAssetSize int64
+	assets        map[string]string
+	dryRun        bool
+	warnings      []string
+	written       []WrittenFile
+}
+
+// WrittenFile records a file produced by the Writer.
+type WrittenFile struct {
+	Path   string // slash-separated, relative to the output directory
+	Source string // source file for copies, empty for generated files
+	Size   int64
 }
 
 // NewWriter creates a new output writer.
@@ -19,8 +34,159 @@ func NewWriter(outputDir string) *Writer {
 	return &Writer{outputDir: outputDir}
 }
 
+// WarnAssetSize makes CopyStatic warn about files larger than limit bytes.
+// A limit of zero disables the check.
+func (w *Writer) WarnAssetSize(limit int64) {
+	w.warnAssetSize = limit
+}
+
+// DryRun makes the writer record what it would write without touching
+// the file system. Prepare, Clean, and Prune become no-ops.
+func (w *Writer) DryRun() {
+	w.dryRun = true
+}
+
+// Fingerprint makes CopyStatic publish the static files in assets under
+// their fingerprinted names. Keys and values are slash-separated paths
+// relative to the static and output directories.
+func (w *Writer) Fingerprint(assets map[string]string) {
+	w.assets = assets
+}
+
+// Warnings returns the warnings collected while writing.
+func (w *Writer) Warnings() []string {
+	return w.warnings
+}
+
+// Written returns the files written so far, in write order.
+func (w *Writer) Written() []WrittenFile {
+	return w.written
+}
+
+func (w *Writer) record(filePath, source string, size int64) {
+	rel, err := filepath.Rel(w.outputDir, filePath)
+	if err != nil {
+		rel = filePath
+	}
+	w.written = append(w.written, WrittenFile{Path: filepath.ToSlash(rel), Source: source, Size: size})
+}
+
+// legacyOwnedFilesName is where earlier versions listed the files they
+// wrote, inside the output directory. Prune removes it so it isn't
+// deployed.
+const legacyOwnedFilesName = ".canopy-files.json"
+
+// ownedFiles is the record TrackFiles keeps of a build's output.
+type ownedFiles struct {
+	OutputDir string   `json:"outputDir"`
+	Files     []string `json:"files"`
+}
+
+// TrackFiles makes Prune keep the list of files each build writes at
+// path, outside the output directory so it isn't deployed. The next build
+// into the same directory uses it to tell its stale files from files
+// users placed there. Without it, Prune removes nothing.
+func (w *Writer) TrackFiles(path string) {
+	w.ownedPath = path
+}
+
+// Prepare creates the output directory if needed, keeping its contents.
+// Pair it with Prune once everything is written.
+func (w *Writer) Prepare() error {
+	if w.dryRun {
+		return nil
+	}
+	if err := os.MkdirAll(w.outputDir, 0o755); err != nil {
+		return fmt.Errorf("creating output dir: %w", err)
+	}
+	return nil
+}
+
+// Prune removes files the previous build wrote that this build did not,
+// along with directories they leave empty, and records this build's files
+// for next time. Files no build wrote, like a hand-added CNAME, are kept.
+func (w *Writer) Prune() error {
+	if w.dryRun {
+		return nil
+	}
+
+	current := make(map[string]bool, len(w.written))
+	owned := make([]string, 0, len(w.written))
+	for _, file := range w.written {
+		if !current[file.Path] {
+			current[file.Path] = true
+			owned = append(owned, file.Path)
+		}
+	}
+	sort.Strings(owned)
+
+	if err := os.Remove(filepath.Join(w.outputDir, legacyOwnedFilesName)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("removing %s: %w", legacyOwnedFilesName, err)
+	}
+	if w.ownedPath == "" {
+		return nil
+	}
+
+	name := filepath.Base(w.ownedPath)
+	outputDir, err := filepath.Abs(w.outputDir)
+	if err != nil {
+		return err
+	}
+	if data, err := os.ReadFile(w.ownedPath); err == nil {
+		var previous ownedFiles
+		if err := json.Unmarshal(data, &previous); err != nil {
+			return fmt.Errorf("reading %s: %w", name, err)
+		}
+		// A list from another output directory says nothing about this one
+		if previous.OutputDir == outputDir {
+			for _, rel := range previous.Files {
+				if current[rel] || !filepath.IsLocal(filepath.FromSlash(rel)) {
+					continue
+				}
+				if err := w.removeStale(rel); err != nil {
+					return err
+				}
+			}
+		}
+	} else if !os.IsNotExist(err) {
+		return fmt.Errorf("reading %s: %w", name, err)
+	}
+
+	data, err := json.MarshalIndent(ownedFiles{OutputDir: outputDir, Files: owned}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(w.ownedPath), 0o755); err != nil {
+		return fmt.Errorf("writing %s: %w", name, err)
+	}
+	if err := os.WriteFile(w.ownedPath, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("writing %s: %w", name, err)
+	}
+	return nil
+}
+
+// removeStale deletes a file from an earlier build and then any parent
+// directories left empty.
+func (w *Writer) removeStale(rel string) error {
+	path := filepath.Join(w.outputDir, filepath.FromSlash(rel))
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("removing stale file %s: %w", rel, err)
+	}
+
+	for dir := filepath.Dir(path); dir != w.outputDir && strings.HasPrefix(dir, w.outputDir); dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			break // not empty
+		}
+	}
+	return nil
+}
+
 // Clean removes and recreates the output directory.
 func (w *Writer) Clean() error {
+	if w.dryRun {
+		return nil
+	}
+
 	// Remove existing output
 	if err := os.RemoveAll(w.outputDir); err != nil {
 		return fmt.Errorf("removing output dir: %w", err)
@@ -40,6 +206,10 @@ func (w *Writer) Clean() error {
 func (w *Writer) WritePage(url, html string) error {
 	// Convert URL to file path
 	filePath := w.urlToPath(url)
+	if w.dryRun {
+		w.record(filePath, "", int64(len(html)))
+		return nil
+	}
 
 	// Create parent directories
 	dir := filepath.Dir(filePath)
@@ -52,6 +222,7 @@ func (w *Writer) WritePage(url, html string) error {
 		return fmt.Errorf("writing file %s: %w", filePath, err)
 	}
 
+	w.record(filePath, "", int64(len(html)))
 	return nil
 }
 
@@ -63,6 +234,11 @@ func (w *Writer) WriteFile(relPath, contents string) error {
 	}
 
 	filePath := filepath.Join(w.outputDir, filepath.FromSlash(path))
+	if w.dryRun {
+		w.record(filePath, "", int64(len(contents)))
+		return nil
+	}
+
 	if err := os.MkdirAll(filepath.Dir(filePath), 0o755); err != nil {
 		return fmt.Errorf("creating directory %s: %w", filepath.Dir(filePath), err)
 	}
@@ -71,6 +247,33 @@ func (w *Writer) WriteFile(relPath, contents string) error {
 		return fmt.Errorf("writing file %s: %w", filePath, err)
 	}
 
+	w.record(filePath, "", int64(len(contents)))
+	return nil
+}
+
+// CopyFile copies a source file to a path relative to the output directory.
+func (w *Writer) CopyFile(src, relPath string) error {
+	path := strings.TrimPrefix(relPath, "/")
+	if path == "" {
+		return fmt.Errorf("empty output path")
+	}
+
+	dst := filepath.Join(w.outputDir, filepath.FromSlash(path))
+	if w.dryRun {
+		info, err := os.Stat(src)
+		if err != nil {
+			return err
+		}
+		w.record(dst, src, info.Size())
+		return nil
+	}
+
+	n, err := copyFile(src, dst)
+	if err != nil {
+		return err
+	}
+
+	w.record(dst, src, n)
 	return nil
 }
 
@@ -90,21 +293,63 @@ func (w *Writer) urlToPath(url string) string {
 	return filepath.Join(w.outputDir, url, "index.html")
 }
 
-// CopyStatic copies the static directory to the output directory.
-func (w *Writer) CopyStatic(staticDir string) error {
-	// Check if static directory exists
-	info, err := os.Stat(staticDir)
-	if os.IsNotExist(err) {
+// CopyStatic copies the static directories to the output directory. A
+// file in more than one comes from the first, so a site's static files
+// override its theme's. Missing directories are skipped unless all are.
+func (w *Writer) CopyStatic(staticDirs ...string) error {
+	// Walk first so directories exist before the concurrent copies and
+	// warnings and records keep walk order
+	var files []staticFile
+	seen := make(map[string]bool)
+	found := false
+	for _, staticDir := range staticDirs {
+		info, err := os.Stat(staticDir)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("static path is not a directory")
+		}
+		found = true
+
+		if files, err = w.walkStatic(staticDir, seen, files); err != nil {
+			return err
+		}
+	}
+	if !found {
 		return fmt.Errorf("static directory does not exist")
 	}
+
+	err := forEachIndex(len(files), true, func(i int) error {
+		file := files[i]
+		if w.dryRun || unchanged(file.info, file.dst) {
+			return nil
+		}
+		_, err := copyFile(file.src, file.dst)
+		return err
+	})
 	if err != nil {
 		return err
 	}
-	if !info.IsDir() {
-		return fmt.Errorf("static path is not a directory")
+
+	for _, file := range files {
+		w.record(file.dst, file.src, file.info.Size())
 	}
+	return nil
+}
 
-	return filepath.WalkDir(staticDir, func(path string, d fs.DirEntry, err error) error {
+type staticFile struct {
+	src, dst string
+	info     fs.FileInfo
+}
+
+// walkStatic appends the files under staticDir whose relative paths aren't
+// in seen, creating their output directories.
+func (w *Writer) walkStatic(staticDir string, seen map[string]bool, files []staticFile) ([]staticFile, error) {
+	err := filepath.WalkDir(staticDir, func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
@@ -118,31 +363,77 @@ func (w *Writer) CopyStatic(staticDir string) error {
 		destPath := filepath.Join(w.outputDir, relPath)
 
 		if d.IsDir() {
+			if w.dryRun {
+				return nil
+			}
 			return os.MkdirAll(destPath, 0o755)
 		}
+		if seen[relPath] {
+			return nil
+		}
+		seen[relPath] = true
+		if hashed, ok := w.assets[filepath.ToSlash(relPath)]; ok {
+			destPath = filepath.Join(w.outputDir, filepath.FromSlash(hashed))
+		}
 
-		return copyFile(path, destPath)
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if w.warnAssetSize > 0 && info.Size() > w.warnAssetSize {
+			w.warnings = append(w.warnings, fmt.Sprintf("large asset %s: %d bytes exceeds %d byte limit",
+				filepath.ToSlash(relPath), info.Size(), w.warnAssetSize))
+		}
+
+		files = append(files, staticFile{src: path, dst: destPath, info: info})
+		return nil
 	})
+	return files, err
+}
+
+// unchanged reports whether dst already matches the source file's size
+// and modification time, as left by an earlier copyFile.
+func unchanged(src fs.FileInfo, dst string) bool {
+	info, err := os.Stat(dst)
+	return err == nil && info.Mode().IsRegular() &&
+		info.Size() == src.Size() && info.ModTime().Equal(src.ModTime())
 }
 
-func copyFile(src, dst string) error {
+// copyFile copies src to dst, keeping the source's permission bits and
+// modification time so later builds can skip unchanged files.
+func copyFile(src, dst string) (int64, error) {
 	srcFile, err := os.Open(src)
 	if err != nil {
-		return err
+		return 0, err
 	}
 	defer srcFile.Close()
 
+	info, err := srcFile.Stat()
+	if err != nil {
+		return 0, err
+	}
+
 	// Ensure parent directory exists
 	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
-		return err
+		return 0, err
 	}
 
-	dstFile, err := os.Create(dst)
+	dstFile, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
 	if err != nil {
-		return err
+		return 0, err
 	}
-	defer dstFile.Close()
 
-	_, err = io.Copy(dstFile, srcFile)
-	return err
+	n, err := io.Copy(dstFile, srcFile)
+	if closeErr := dstFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return n, err
+	}
+
+	// OpenFile leaves an existing file's mode alone and applies the umask
+	if err := os.Chmod(dst, info.Mode().Perm()); err != nil {
+		return n, err
+	}
+	return n, os.Chtimes(dst, info.ModTime(), info.ModTime())
 }
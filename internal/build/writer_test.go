@@ -0,0 +1,72 @@
+package build
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCopyStatic(t *testing.T) {
+	staticDir := t.TempDir()
+	outputDir := t.TempDir()
+	for _, dir := range []string{"css", "js/vendor"} {
+		if err := os.MkdirAll(filepath.Join(staticDir, dir), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeSiteFile(t, staticDir, "css/site.css", "body {}")
+	writeSiteFile(t, staticDir, "run.sh", "#!/bin/sh\n")
+	writeSiteFile(t, staticDir, "js/vendor/lib.js", "lib()")
+	if err := os.Chmod(filepath.Join(staticDir, "run.sh"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	writer := NewWriter(outputDir)
+	if err := writer.CopyStatic(staticDir); err != nil {
+		t.Fatalf("CopyStatic() error = %v", err)
+	}
+
+	var paths []string
+	for _, file := range writer.Written() {
+		paths = append(paths, file.Path)
+	}
+	if want := []string{"css/site.css", "js/vendor/lib.js", "run.sh"}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("written = %v, want %v", paths, want)
+	}
+
+	info, err := os.Stat(filepath.Join(outputDir, "run.sh"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o755 {
+		t.Errorf("mode = %v, want the source's 0755", info.Mode().Perm())
+	}
+
+	// Same size and mtime as the source: left alone
+	dst := filepath.Join(outputDir, "css", "site.css")
+	src, _ := os.Stat(filepath.Join(staticDir, "css", "site.css"))
+	writeSiteFile(t, outputDir, "css/site.css", "BODY {}")
+	if err := os.Chtimes(dst, src.ModTime(), src.ModTime()); err != nil {
+		t.Fatal(err)
+	}
+	if err := NewWriter(outputDir).CopyStatic(staticDir); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(dst); string(data) != "BODY {}" {
+		t.Errorf("expected an unchanged file to be skipped, got %q", data)
+	}
+
+	// A newer source is copied again
+	later := src.ModTime().Add(time.Minute)
+	if err := os.Chtimes(filepath.Join(staticDir, "css", "site.css"), later, later); err != nil {
+		t.Fatal(err)
+	}
+	if err := NewWriter(outputDir).CopyStatic(staticDir); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(dst); string(data) != "body {}" {
+		t.Errorf("expected a changed file to be copied, got %q", data)
+	}
+}
@@ -1,18 +1,35 @@
 package config
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/shanepadgett/canopy/internal/core"
+	"github.com/shanepadgett/canopy/internal/toml"
+	"github.com/shanepadgett/canopy/internal/yaml"
 )
 
-// Load reads site.json from the given directory and returns a Config.
-// If path is empty, it searches upward from cwd for site.json.
+// configNames lists the recognized config file names in priority order.
+var configNames = []string{"site.json", "site.yaml", "site.yml", "site.toml"}
+
+// Load reads a site config file and returns a Config. The format is chosen
+// by extension: .json, .yaml/.yml, or .toml.
+// If path is empty, it searches upward from cwd for a config file.
 func Load(path string) (core.Config, error) {
+	return LoadEnvironment(path, "")
+}
+
+// LoadEnvironment reads the base config like Load and, when env is set,
+// deep-merges an environment override file from the same directory over it.
+// For a base of site.json and env "production", site.production.json is
+// used; the override may be any supported format. A missing override file
+// is not an error. See mergeValues for merge semantics.
+func LoadEnvironment(path, env string) (core.Config, error) {
 	cfg := core.DefaultConfig()
 	cfg.Search.Enabled = true
 
@@ -24,13 +41,23 @@ func Load(path string) (core.Config, error) {
 		}
 	}
 
-	data, err := os.ReadFile(path)
+	values, err := readValues(path)
 	if err != nil {
-		return cfg, fmt.Errorf("reading config: %w", err)
+		return cfg, err
 	}
 
-	if err := json.Unmarshal(data, &cfg); err != nil {
-		return cfg, fmt.Errorf("parsing config: %w", err)
+	if env != "" {
+		if overridePath, ok := findOverride(path, env); ok {
+			override, err := readValues(overridePath)
+			if err != nil {
+				return cfg, err
+			}
+			values = mergeValues(values, override)
+		}
+	}
+
+	if err := apply(values, &cfg); err != nil {
+		return cfg, fmt.Errorf("parsing config %s: %w", filepath.Base(path), err)
 	}
 
 	// Validate required fields
@@ -54,16 +81,91 @@ func Load(path string) (core.Config, error) {
 	if cfg.Params == nil {
 		cfg.Params = make(map[string]any)
 	}
+	if cfg.Feeds.Path == "" {
+		cfg.Feeds.Path = "rss.xml"
+	}
+	if cfg.Search.Path == "" {
+		cfg.Search.Path = "search.json"
+	}
 
 	return cfg, nil
 }
 
-// Find searches upward from cwd for site.json and returns its path.
+// findOverride returns the environment override next to the base config,
+// trying each supported extension in configNames order.
+func findOverride(basePath, env string) (string, bool) {
+	dir := filepath.Dir(basePath)
+	stem := strings.TrimSuffix(filepath.Base(basePath), filepath.Ext(basePath))
+
+	for _, name := range configNames {
+		candidate := filepath.Join(dir, stem+"."+env+filepath.Ext(name))
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate, true
+		}
+	}
+	return "", false
+}
+
+// readValues reads a config file into a generic map. The values are also
+// applied to a scratch Config so type errors name the file they came from.
+func readValues(path string) (map[string]any, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading config: %w", err)
+	}
+
+	values, err := decode(path, data)
+	if err == nil {
+		var scratch core.Config
+		err = apply(values, &scratch)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parsing config %s: %w", filepath.Base(path), err)
+	}
+
+	return values, nil
+}
+
+// decode parses config data according to the file extension.
+func decode(path string, data []byte) (map[string]any, error) {
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".json":
+		var values map[string]any
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		if err := decoder.Decode(&values); err != nil {
+			return nil, err
+		}
+		if values == nil {
+			values = map[string]any{}
+		}
+		return values, nil
+	case ".yaml", ".yml":
+		return yaml.Parse(data)
+	case ".toml":
+		return toml.Parse(data)
+	default:
+		return nil, fmt.Errorf("unsupported config format %q", ext)
+	}
+}
+
+// apply round-trips generic values through JSON so that every format
+// shares the json struct tags on core.Config.
+func apply(values map[string]any, cfg *core.Config) error {
+	encoded, err := json.Marshal(values)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(encoded, cfg)
+}
+
+// Find searches upward from cwd for a site config file and returns its path.
 func Find() (string, error) {
 	return findConfig()
 }
 
-// findConfig searches upward from cwd for site.json.
+// findConfig searches upward from cwd for a site config file. Within a
+// directory, names are tried in configNames order so site.json wins.
 func findConfig() (string, error) {
 	dir, err := os.Getwd()
 	if err != nil {
@@ -71,9 +173,11 @@ func findConfig() (string, error) {
 	}
 
 	for {
-		candidate := filepath.Join(dir, "site.json")
-		if _, err := os.Stat(candidate); err == nil {
-			return candidate, nil
+		for _, name := range configNames {
+			candidate := filepath.Join(dir, name)
+			if _, err := os.Stat(candidate); err == nil {
+				return candidate, nil
+			}
 		}
 
 		parent := filepath.Dir(dir)
@@ -83,10 +187,10 @@ func findConfig() (string, error) {
 		dir = parent
 	}
 
-	return "", errors.New("site.json not found (searched upward from cwd)")
+	return "", errors.New("site config not found (searched upward from cwd for site.json, site.yaml, site.yml, site.toml)")
 }
 
-// RootDir returns the directory containing site.json.
+// RootDir returns the directory containing the site config file.
 func RootDir(configPath string) string {
 	return filepath.Dir(configPath)
 }
@@ -0,0 +1,88 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMergeValues(t *testing.T) {
+	base := map[string]any{
+		"baseURL": "http://localhost:8080",
+		"menu":    []any{"home", "blog"},
+		"search":  map[string]any{"enabled": true, "versioned": false},
+		"params":  map[string]any{"analytics": "dev-id", "theme": "light"},
+	}
+	override := map[string]any{
+		"baseURL": "https://example.com",
+		"menu":    []any{"home"},
+		"search":  map[string]any{"versioned": true},
+		"params":  map[string]any{"analytics": nil},
+		"new":     "value",
+	}
+
+	got := mergeValues(base, override)
+	want := map[string]any{
+		"baseURL": "https://example.com",
+		"menu":    []any{"home"},
+		"search":  map[string]any{"enabled": true, "versioned": true},
+		"params":  map[string]any{"theme": "light"},
+		"new":     "value",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("mergeValues() = %#v, want %#v", got, want)
+	}
+
+	if _, ok := base["new"]; ok {
+		t.Error("mergeValues modified its base input")
+	}
+	if base["search"].(map[string]any)["versioned"] != false {
+		t.Error("mergeValues modified a nested base map")
+	}
+}
+
+func TestLoadEnvironment(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "site.json", `{"name": "Test", "baseURL": "http://localhost:8080", "buildDrafts": true, "params": {"env": "dev", "keep": 1}}`)
+	writeFile(t, dir, "site.production.yaml", "baseURL: https://example.com\nbuildDrafts: false\nparams:\n  env: prod\n")
+	path := filepath.Join(dir, "site.json")
+
+	cfg, err := LoadEnvironment(path, "production")
+	if err != nil {
+		t.Fatalf("LoadEnvironment() error = %v", err)
+	}
+	if cfg.BaseURL != "https://example.com" || cfg.BuildDrafts {
+		t.Errorf("override not applied: baseURL=%q buildDrafts=%v", cfg.BaseURL, cfg.BuildDrafts)
+	}
+	if cfg.Params["env"] != "prod" || cfg.Params["keep"] != float64(1) {
+		t.Errorf("params not deep-merged: %#v", cfg.Params)
+	}
+
+	cfg, err = LoadEnvironment(path, "staging")
+	if err != nil {
+		t.Fatalf("missing override should not fail: %v", err)
+	}
+	if cfg.BaseURL != "http://localhost:8080" || !cfg.BuildDrafts {
+		t.Errorf("expected base config without override, got baseURL=%q buildDrafts=%v", cfg.BaseURL, cfg.BuildDrafts)
+	}
+}
+
+func TestLoadEnvironmentOverrideError(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "site.json", `{"name": "Test", "baseURL": "https://example.com"}`)
+	writeFile(t, dir, "site.production.json", `{"buildDrafts": "yes"}`)
+
+	_, err := LoadEnvironment(filepath.Join(dir, "site.json"), "production")
+	if err == nil || !strings.Contains(err.Error(), "site.production.json") {
+		t.Errorf("expected error naming the override file, got %v", err)
+	}
+}
+
+func writeFile(t *testing.T, dir, name, contents string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(contents), 0o644); err != nil {
+		t.Fatalf("writing %s: %v", name, err)
+	}
+}
@@ -0,0 +1,34 @@
+package config
+
+// mergeValues deep-merges override over base and returns the result:
+//
+//   - maps merge key by key, recursively
+//   - slices replace the base slice wholesale (they are not appended)
+//   - scalars replace the base value
+//   - an explicit null removes the key, restoring the built-in default
+//
+// Neither input is modified.
+func mergeValues(base, override map[string]any) map[string]any {
+	merged := make(map[string]any, len(base)+len(override))
+	for key, value := range base {
+		merged[key] = value
+	}
+
+	for key, value := range override {
+		if value == nil {
+			delete(merged, key)
+			continue
+		}
+
+		baseMap, baseIsMap := merged[key].(map[string]any)
+		overrideMap, overrideIsMap := value.(map[string]any)
+		if baseIsMap && overrideIsMap {
+			merged[key] = mergeValues(baseMap, overrideMap)
+			continue
+		}
+
+		merged[key] = value
+	}
+
+	return merged
+}
@@ -2,11 +2,13 @@
 package content
 
 import (
+	"bytes"
 	"fmt"
 	"os"
 	"path/filepath"
 	"sort"
 	"strings"
+	"unicode"
 
 	"github.com/shanepadgett/canopy/internal/core"
 )
@@ -17,6 +19,7 @@ type Loader struct {
 	contentDir  string
 	config      core.Config
 	buildDrafts bool
+	draftsOnly  bool
 }
 
 // NewLoader creates a content loader.
@@ -29,6 +32,13 @@ func NewLoader(rootDir string, cfg core.Config, buildDrafts bool) *Loader {
 	}
 }
 
+// DraftsOnly makes Load return only draft pages, for previewing
+// unpublished work in isolation. It overrides the buildDrafts setting.
+func (l *Loader) DraftsOnly() {
+	l.buildDrafts = true
+	l.draftsOnly = true
+}
+
 // LoadResult contains the loaded pages and any errors encountered.
 type LoadResult struct {
 	Pages  []*core.Page
@@ -65,8 +75,9 @@ func (l *Loader) Load() (*LoadResult, error) {
 			return nil
 		}
 
-		// Skip drafts unless buildDrafts is true
-		if page.Draft && !l.buildDrafts {
+		// Skip drafts unless buildDrafts is true, and everything else in
+		// drafts-only mode
+		if (page.Draft && !l.buildDrafts) || (!page.Draft && l.draftsOnly) {
 			return nil
 		}
 
@@ -99,6 +110,21 @@ func (l *Loader) Load() (*LoadResult, error) {
 	return result, nil
 }
 
+// LoadPage loads a single content file. A relative path is resolved
+// against the content directory. Drafts are returned regardless of the
+// loader's draft setting.
+func (l *Loader) LoadPage(path string) (*core.Page, error) {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(l.contentDir, path)
+	}
+
+	page, loadErr := l.loadPage(path)
+	if loadErr != nil {
+		return nil, loadErr
+	}
+	return page, nil
+}
+
 func (l *Loader) loadPage(path string) (*core.Page, *LoadError) {
 	// Read file
 	data, err := os.ReadFile(path)
@@ -118,10 +144,36 @@ func (l *Loader) loadPage(path string) (*core.Page, *LoadError) {
 		return nil, &LoadError{Path: path, Message: fmt.Sprintf("computing relative path: %v", err)}
 	}
 
+	// A language suffix or directory is stripped before deriving the
+	// bundle, section, and slug, so about.fr.md and fr/about.md both
+	// become about.md in French
+	pathLang, langPath := l.splitLang(relPath)
+	lang := fm.Lang
+	if lang == "" {
+		lang = pathLang
+	}
+	if lang == "" {
+		lang = l.config.Language
+	}
+
+	// An index.md inside a directory makes that directory a page bundle,
+	// unless the directory holds other pages, as a section's does
+	bundle := bundleDir(langPath)
+	if bundle != "" && !l.isLeafDir(filepath.Dir(path)) {
+		bundle = ""
+	}
+
 	// Derive section from first path segment
-	section := deriveSection(relPath)
+	section := deriveSection(langPath)
+	if bundle != "" {
+		section = deriveSection(bundle)
+	}
 
-	// Apply section defaults
+	// Apply cascades from the nearest directory up, then section defaults;
+	// ApplyDefaults never overwrites, so earlier values win
+	for _, dir := range ancestorDirs(langPath) {
+		fm.ApplyDefaults(l.config.Sections[dir].Cascade)
+	}
 	if sectionCfg, ok := l.config.Sections[section]; ok {
 		fm.ApplyDefaults(sectionCfg.Defaults)
 	}
@@ -141,10 +193,42 @@ func (l *Loader) loadPage(path string) (*core.Page, *LoadError) {
 	}
 
 	// Derive slug
-	slug := deriveSlug(relPath, fm.Slug)
+	slug := deriveSlug(langPath, fm.Slug)
+	if bundle != "" && fm.Slug == "" {
+		slug = filepath.Base(bundle)
+	}
+	// Underscores separate words in file names, so they become hyphens
+	// here rather than being dropped as Slugify does elsewhere.
+	if clean := core.Slugify(strings.ReplaceAll(slug, "_", "-")); clean != slug {
+		if clean == "" || l.config.Build.StrictSlugs {
+			return nil, &LoadError{
+				Path:    path,
+				Message: fmt.Sprintf("slug %q is not URL-safe", slug),
+			}
+		}
+		slug = clean
+	}
+
+	// Compute URL, under the language's prefix
+	url := l.config.LangPrefix(lang) + computeURL(l.config, section, slug, fm.Date)
 
-	// Compute URL
-	url := computeURL(l.config, section, slug, fm.Date)
+	lastMod := fm.LastMod
+	if lastMod.IsZero() && l.config.Build.FileModTime {
+		if info, err := os.Stat(path); err == nil {
+			lastMod = info.ModTime()
+		}
+	}
+
+	contentType := fm.Type
+	if contentType == "" {
+		contentType = section
+	}
+
+	// Pages at the same path in different languages are translations
+	translationKey := fm.TranslationKey
+	if translationKey == "" && len(l.config.Languages) > 0 {
+		translationKey = filepath.ToSlash(langPath)
+	}
 
 	// Build page
 	page := &core.Page{
@@ -154,18 +238,102 @@ func (l *Loader) loadPage(path string) (*core.Page, *LoadError) {
 		Title:       fm.Title,
 		Description: fm.Description,
 		RawContent:  string(body),
+		ContentLine: contentLine(data, body),
+		Kind:        core.KindPage,
 		Section:     section,
+		Type:        contentType,
+		Layout:      fm.Layout,
 		Tags:        fm.Tags,
+		Authors:     fm.AuthorList(),
 		Draft:       fm.Draft,
 		Date:        fm.Date,
+		LastMod:     lastMod,
 		Aliases:     fm.Aliases,
 		Weight:      fm.Weight,
+		Series:      fm.Series,
+		SeriesOrder: fm.SeriesOrder,
 		Params:      fm.Extra,
+
+		Lang:           lang,
+		TranslationKey: translationKey,
+	}
+
+	// Resources sit beside the index file itself, under any language
+	// directory: content/fr/blog/trip/index.md -> fr/blog/trip
+	if bundle != "" {
+		resources, err := l.loadResources(filepath.ToSlash(filepath.Dir(relPath)), url)
+		if err != nil {
+			return nil, &LoadError{Path: path, Message: fmt.Sprintf("loading bundle resources: %v", err)}
+		}
+		page.Resources = resources
 	}
 
 	return page, nil
 }
 
+// loadResources collects the non-Markdown files inside a bundle directory.
+func (l *Loader) loadResources(bundle, pageURL string) ([]*core.Resource, error) {
+	bundlePath := filepath.Join(l.contentDir, bundle)
+	var resources []*core.Resource
+
+	err := filepath.WalkDir(bundlePath, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || strings.HasSuffix(path, ".md") {
+			return nil
+		}
+
+		name, err := filepath.Rel(bundlePath, path)
+		if err != nil {
+			return err
+		}
+		name = filepath.ToSlash(name)
+
+		resources = append(resources, &core.Resource{
+			Name:       name,
+			SourcePath: filepath.ToSlash(filepath.Join(bundle, name)),
+			URL:        pageURL + name,
+		})
+		return nil
+	})
+
+	return resources, err
+}
+
+// bundleDir returns the bundle directory for an index.md page.
+// content/blog/trip/index.md -> "blog/trip"
+// content/index.md -> ""
+func bundleDir(relPath string) string {
+	if filepath.Base(relPath) != "index.md" {
+		return ""
+	}
+	dir := filepath.Dir(relPath)
+	if dir == "." {
+		return ""
+	}
+	return filepath.ToSlash(dir)
+}
+
+// isLeafDir reports whether dir holds no content pages besides its own
+// index files, such as index.md and index.fr.md.
+func (l *Loader) isLeafDir(dir string) bool {
+	leaf := true
+	filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.HasSuffix(path, ".md") {
+			return nil
+		}
+		if rel, err := filepath.Rel(l.contentDir, path); err == nil && filepath.Dir(path) == dir {
+			if _, langPath := l.splitLang(rel); filepath.Base(langPath) == "index.md" {
+				return nil
+			}
+		}
+		leaf = false
+		return filepath.SkipAll
+	})
+	return leaf
+}
+
 // deriveSection extracts the section from the relative path.
 // content/blog/post.md -> "blog"
 // content/guides/intro/start.md -> "guides"
@@ -177,7 +345,23 @@ func deriveSection(relPath string) string {
 	return ""
 }
 
-// deriveSlug determines the page slug.
+// ancestorDirs lists the directories containing relPath, nearest first,
+// as slash-separated paths: docs/guides/intro.md -> docs/guides, docs.
+func ancestorDirs(relPath string) []string {
+	var dirs []string
+	dir := filepath.ToSlash(filepath.Dir(relPath))
+	for dir != "." && dir != "/" && dir != "" {
+		dirs = append(dirs, dir)
+		i := strings.LastIndex(dir, "/")
+		if i == -1 {
+			break
+		}
+		dir = dir[:i]
+	}
+	return dirs
+}
+
+// deriveSlug determines the page slug before slugification.
 // Front matter slug takes precedence over filename.
 func deriveSlug(relPath, fmSlug string) string {
 	if fmSlug != "" {
@@ -188,3 +372,33 @@ func deriveSlug(relPath, fmSlug string) string {
 	base := filepath.Base(relPath)
 	return strings.TrimSuffix(base, filepath.Ext(base))
 }
+
+// splitLang returns the configured language a content path names, either
+// as a filename suffix (about.fr.md) or a top-level directory
+// (fr/about.md), and the path without it. Paths naming no language come
+// back unchanged with an empty language.
+func (l *Loader) splitLang(relPath string) (string, string) {
+	dir, file := filepath.Split(relPath)
+	stem := strings.TrimSuffix(file, filepath.Ext(file))
+	if ext := filepath.Ext(stem); ext != "" {
+		if _, ok := l.config.Languages[ext[1:]]; ok {
+			return ext[1:], dir + strings.TrimSuffix(stem, ext) + filepath.Ext(file)
+		}
+	}
+
+	first, rest, ok := strings.Cut(filepath.ToSlash(relPath), "/")
+	if _, known := l.config.Languages[first]; ok && known {
+		return first, filepath.FromSlash(rest)
+	}
+	return "", relPath
+}
+
+// contentLine returns the 1-based line of data where body begins. body is
+// the tail of data that ParseFrontMatter returns, minus trailing space.
+func contentLine(data, body []byte) int {
+	offset := len(bytes.TrimRightFunc(data, unicode.IsSpace)) - len(body)
+	if offset < 0 {
+		offset = 0
+	}
+	return bytes.Count(data[:offset], []byte("\n")) + 1
+}
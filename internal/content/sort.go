@@ -0,0 +1,110 @@
+package content
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/shanepadgett/canopy/internal/core"
+)
+
+// SortPages orders pages in place by the given key and order. Keys are
+// "date", "weight", "title", "filename" (the source path, so numbered
+// files like 01-intro.md keep their order), or a front matter param
+// name. Pages that compare equal keep their existing relative order.
+func SortPages(pages []*core.Page, by, order string) {
+	if by == "" {
+		return
+	}
+
+	desc := order == "desc" || (order == "" && by == "date")
+
+	sort.SliceStable(pages, func(i, j int) bool {
+		// Pages missing a param sort last in either order
+		if missing := missingKey(pages[i], by) - missingKey(pages[j], by); missing != 0 {
+			return missing < 0
+		}
+		c := comparePages(pages[i], pages[j], by)
+		if desc {
+			return c > 0
+		}
+		return c < 0
+	})
+}
+
+// missingKey is 1 when page lacks the param it is sorted by, else 0.
+func missingKey(page *core.Page, by string) int {
+	switch by {
+	case "date", "weight", "title", "filename":
+		return 0
+	}
+	if page.Params[by] == nil {
+		return 1
+	}
+	return 0
+}
+
+func comparePages(a, b *core.Page, by string) int {
+	switch by {
+	case "date":
+		return a.Date.Compare(b.Date)
+	case "weight":
+		return compareInts(a.Weight, b.Weight)
+	case "title":
+		return strings.Compare(strings.ToLower(a.Title), strings.ToLower(b.Title))
+	case "filename":
+		return strings.Compare(filepath.ToSlash(a.SourcePath), filepath.ToSlash(b.SourcePath))
+	default:
+		return compareValues(a.Params[by], b.Params[by])
+	}
+}
+
+// compareValues orders front matter values. Missing values sort last;
+// SortPages keeps them last when descending too.
+func compareValues(a, b any) int {
+	if a == nil || b == nil {
+		switch {
+		case a == nil && b == nil:
+			return 0
+		case a == nil:
+			return 1
+		default:
+			return -1
+		}
+	}
+
+	switch av := a.(type) {
+	case float64:
+		if bv, ok := b.(float64); ok {
+			switch {
+			case av < bv:
+				return -1
+			case av > bv:
+				return 1
+			}
+			return 0
+		}
+	case int:
+		if bv, ok := b.(int); ok {
+			return compareInts(av, bv)
+		}
+	case time.Time:
+		if bv, ok := b.(time.Time); ok {
+			return av.Compare(bv)
+		}
+	}
+
+	return strings.Compare(strings.ToLower(fmt.Sprint(a)), strings.ToLower(fmt.Sprint(b)))
+}
+
+func compareInts(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,177 @@
+package core
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Validate checks the config for structural problems and normalizes it.
+// Nav and menu items and their children are sorted by Weight (stable, so equal
+// weights keep their configured order), which is the order templates see
+// through Config.Nav. Entries with neither a title nor a URL are errors;
+// duplicate URLs within a menu are reported as warnings.
+func (c *Config) Validate() ([]string, error) {
+	var warnings []string
+	seen := make(map[string]string)
+
+	if err := validateNav(c.Nav, "nav", seen, &warnings); err != nil {
+		return warnings, err
+	}
+	for _, name := range sortedMenuNames(c.Menus) {
+		if err := validateNav(c.Menus[name], "menus."+name, make(map[string]string), &warnings); err != nil {
+			return warnings, err
+		}
+	}
+
+	plurals := make(map[string]bool)
+	for i, taxonomy := range c.Taxonomies {
+		switch {
+		case taxonomy.Plural == "":
+			return warnings, fmt.Errorf("config: taxonomies[%d] is missing plural", i)
+		case plurals[taxonomy.Plural]:
+			return warnings, fmt.Errorf("config: taxonomy %q is declared more than once", taxonomy.Plural)
+		}
+		plurals[taxonomy.Plural] = true
+	}
+
+	if err := c.validateOutputs(); err != nil {
+		return warnings, err
+	}
+
+	// The theme is a directory under themes/, so it must not reach
+	// outside it
+	if c.Theme == "." || c.Theme == ".." || strings.ContainsAny(c.Theme, `/\`) {
+		return warnings, fmt.Errorf("config: invalid theme %q: must be a directory name under themes/", c.Theme)
+	}
+
+	return warnings, nil
+}
+
+// LangPrefix returns the URL prefix for a configured language's pages:
+// its prefix setting, else none for the default language and "/<code>"
+// for others. Languages missing from Config.Languages get no prefix.
+func (c *Config) LangPrefix(lang string) string {
+	language, ok := c.Languages[lang]
+	switch {
+	case !ok:
+		return ""
+	case language.Prefix != "":
+		return "/" + strings.Trim(language.Prefix, "/")
+	case lang == c.Language:
+		return ""
+	}
+	return "/" + lang
+}
+
+// LanguageCodes returns the default language and the configured ones,
+// sorted.
+func (c *Config) LanguageCodes() []string {
+	codes := []string{c.Language}
+	for code := range c.Languages {
+		if code != c.Language {
+			codes = append(codes, code)
+		}
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// TaxonomyList returns every taxonomy the site builds: tags first, then
+// the configured taxonomies in order, with naming defaults applied.
+func (c *Config) TaxonomyList() []TaxonomyConfig {
+	taxonomies := []TaxonomyConfig{{Plural: "tags"}}
+	for _, taxonomy := range c.Taxonomies {
+		if taxonomy.Plural == "tags" {
+			taxonomies[0] = taxonomy
+		} else {
+			taxonomies = append(taxonomies, taxonomy)
+		}
+	}
+
+	for i := range taxonomies {
+		if taxonomies[i].Title == "" {
+			taxonomies[i].Title = titleize(taxonomies[i].Plural)
+		}
+	}
+	return taxonomies
+}
+
+// TermTitle returns the display title for a term page.
+func (t TaxonomyConfig) TermTitle(term string) string {
+	if t.Singular == "" {
+		return titleize(term)
+	}
+	return titleize(t.Singular) + ": " + term
+}
+
+// validateOutputs checks that output formats name a file beside
+// index.html and that sections only select configured formats.
+func (c *Config) validateOutputs() error {
+	for _, name := range sortedKeys(c.OutputFormats) {
+		switch fileName := c.OutputFormats[name].FileName; {
+		case fileName == "":
+			return fmt.Errorf("config: output format %q is missing fileName", name)
+		case fileName == "index.html" || fileName == "." || fileName == ".." ||
+			filepath.Clean(fileName) != fileName || strings.ContainsAny(fileName, `/\`):
+			return fmt.Errorf("config: output format %q has invalid fileName %q", name, fileName)
+		}
+	}
+	for _, section := range sortedKeys(c.Sections) {
+		for _, name := range c.Sections[section].Outputs {
+			if _, ok := c.OutputFormats[name]; !ok {
+				return fmt.Errorf("config: sections.%s.outputs names unknown output format %q", section, name)
+			}
+		}
+	}
+	return nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sortedMenuNames(menus map[string][]NavItem) []string {
+	names := make([]string, 0, len(menus))
+	for name := range menus {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func validateNav(items []NavItem, path string, seen map[string]string, warnings *[]string) error {
+	// Validate before sorting so paths match the configured order
+	for i := range items {
+		item := &items[i]
+		itemPath := fmt.Sprintf("%s[%d]", path, i)
+
+		if item.Title == "" && item.URL == "" {
+			return fmt.Errorf("config: %s has neither a title nor a url", itemPath)
+		}
+
+		if item.URL != "" {
+			if first, ok := seen[item.URL]; ok {
+				*warnings = append(*warnings, fmt.Sprintf("config: %s (%q) duplicates the url %s of %s", itemPath, item.Title, item.URL, first))
+			} else {
+				seen[item.URL] = itemPath
+			}
+		}
+
+		if err := validateNav(item.Children, itemPath+".children", seen, warnings); err != nil {
+			return err
+		}
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].Weight < items[j].Weight
+	})
+
+	return nil
+}
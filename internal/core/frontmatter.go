@@ -5,6 +5,8 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"regexp"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -13,15 +15,36 @@ import (
 type FrontMatter struct {
 	Title       string    `json:"title"`
 	Date        time.Time `json:"date"`
+	LastMod     time.Time `json:"lastmod"`
 	Slug        string    `json:"slug"`
 	Description string    `json:"description"`
 	Tags        []string  `json:"tags"`
+	Author      string    `json:"author"`
+	Authors     []string  `json:"authors"`
 	Draft       bool      `json:"draft"`
 	Aliases     []string  `json:"aliases"`
 	Weight      int       `json:"weight"`
 
+	// Type picks a layouts/<type>.html template; defaults to the section
+	Type string `json:"type"`
+
+	// Layout names a layouts/<layout>.html template for this page
+	Layout string `json:"layout"`
+
+	// Series groups pages into an ordered sequence
+	Series      string `json:"series"`
+	SeriesOrder int    `json:"seriesOrder"`
+
+	// Multilingual
+	Lang           string `json:"lang"`
+	TranslationKey string `json:"translationKey"`
+
 	// Extra holds any additional fields not in the struct
 	Extra map[string]any `json:"-"`
+
+	// set records the lowercased keys the page or an applied default
+	// gave a value, so later defaults leave them alone
+	set map[string]bool
 }
 
 // ParseFrontMatter extracts front matter from content.
@@ -74,8 +97,12 @@ func parseJSONFrontMatter(data []byte, fm *FrontMatter) error {
 		return err
 	}
 
+	for k := range raw {
+		fm.markSet(k)
+	}
+
 	// Remove known fields
-	known := []string{"title", "date", "slug", "description", "tags", "draft", "aliases", "weight"}
+	known := []string{"title", "date", "lastmod", "slug", "description", "tags", "author", "authors", "draft", "aliases", "weight", "type", "layout", "series", "seriesOrder", "lang", "translationKey"}
 	for _, k := range known {
 		delete(raw, k)
 	}
@@ -87,8 +114,8 @@ func parseJSONFrontMatter(data []byte, fm *FrontMatter) error {
 func parseSimpleFrontMatter(data []byte, fm *FrontMatter) error {
 	lines := bytes.Split(data, []byte("\n"))
 
-	for _, line := range lines {
-		line = bytes.TrimSpace(line)
+	for i := 0; i < len(lines); i++ {
+		line := bytes.TrimSpace(lines[i])
 		if len(line) == 0 {
 			continue
 		}
@@ -100,6 +127,15 @@ func parseSimpleFrontMatter(data []byte, fm *FrontMatter) error {
 
 		key := strings.ToLower(string(bytes.TrimSpace(line[:idx])))
 		val := string(bytes.TrimSpace(line[idx+1:]))
+		fm.markSet(key)
+
+		// An empty value may open a block list of "- item" lines
+		var block []string
+		if val == "" {
+			var n int
+			block, n = parseBlockList(lines[i+1:])
+			i += n
+		}
 
 		switch key {
 		case "title":
@@ -109,24 +145,129 @@ func parseSimpleFrontMatter(data []byte, fm *FrontMatter) error {
 		case "slug":
 			fm.Slug = unquote(val)
 		case "draft":
-			fm.Draft = val == "true" || val == "yes"
+			fm.Draft, _ = parseBool(val)
 		case "date":
 			t, err := parseDate(val)
 			if err == nil {
 				fm.Date = t
 			}
+		case "lastmod":
+			t, err := parseDate(val)
+			if err == nil {
+				fm.LastMod = t
+			}
 		case "tags":
-			fm.Tags = parseList(val)
+			fm.Tags = listValue(val, block)
+		case "author":
+			fm.Author = unquote(val)
+		case "authors":
+			fm.Authors = listValue(val, block)
+		case "aliases":
+			fm.Aliases = listValue(val, block)
 		case "weight":
 			fmt.Sscanf(val, "%d", &fm.Weight)
+		case "type":
+			fm.Type = unquote(val)
+		case "layout":
+			fm.Layout = unquote(val)
+		case "series":
+			fm.Series = unquote(val)
+		case "seriesorder":
+			fmt.Sscanf(val, "%d", &fm.SeriesOrder)
+		case "lang":
+			fm.Lang = unquote(val)
+		case "translationkey":
+			fm.TranslationKey = unquote(val)
 		default:
-			fm.Extra[key] = unquote(val)
+			switch {
+			case block != nil:
+				fm.Extra[key] = block
+			case strings.HasPrefix(val, "[") && strings.HasSuffix(val, "]"):
+				fm.Extra[key] = parseList(val)
+			default:
+				fm.Extra[key] = parseScalar(val)
+			}
 		}
 	}
 
 	return nil
 }
 
+// parseBlockList collects the "- item" lines at the start of lines, as
+// written under a key with an empty value. It returns the items and how
+// many lines they took; a nil list means there was no block.
+func parseBlockList(lines [][]byte) ([]string, int) {
+	var items []string
+	n := 0
+	for _, line := range lines {
+		line = bytes.TrimSpace(line)
+		if len(line) > 0 && line[0] != '-' {
+			break
+		}
+		n++
+		if len(line) == 0 {
+			continue
+		}
+		if item := unquote(string(bytes.TrimSpace(line[1:]))); item != "" {
+			items = append(items, item)
+		}
+	}
+	if items == nil {
+		return nil, 0
+	}
+	return items, n
+}
+
+// listValue picks a block list when one was given, else parses val inline.
+func listValue(val string, block []string) []string {
+	if block != nil {
+		return block
+	}
+	return parseList(val)
+}
+
+// parseBool reads a boolean-looking value: true/false, yes/no, on/off,
+// or 1/0, in any case. ok is false for anything else.
+func parseBool(s string) (value, ok bool) {
+	switch strings.ToLower(s) {
+	case "true", "yes", "on", "1":
+		return true, true
+	case "false", "no", "off", "0":
+		return false, true
+	}
+	return false, false
+}
+
+// decimalPattern matches plain decimal numbers, leaving out the hex,
+// Inf, and NaN forms strconv.ParseFloat also accepts.
+var decimalPattern = regexp.MustCompile(`^[-+]?(\d+\.?\d*|\.\d+)([eE][-+]?\d+)?$`)
+
+// parseScalar types an unquoted simple front matter value so templates see
+// real bools and numbers: "off" is false, "3" is 3, "1.5" is 1.5. Integers
+// win over 1/0 booleans; both test the same in {{if}}. Integers written
+// another way, like "007" or "+3", stay strings so zip codes and IDs keep
+// their digits. Quoted values always stay strings.
+func parseScalar(s string) any {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') {
+		return unquote(s)
+	}
+	if n, err := strconv.Atoi(s); err == nil {
+		if strconv.Itoa(n) != s {
+			return s
+		}
+		return n
+	}
+	if decimalPattern.MatchString(s) {
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return f
+		}
+	}
+	if b, ok := parseBool(s); ok {
+		return b
+	}
+	return s
+}
+
 func unquote(s string) string {
 	s = strings.TrimSpace(s)
 	if len(s) >= 2 {
@@ -163,7 +304,10 @@ func parseList(s string) []string {
 			return list
 		}
 	}
-	// Handle comma-separated
+	// Handle comma-separated, with or without unquoted [brackets]
+	if strings.HasPrefix(s, "[") && strings.HasSuffix(s, "]") {
+		s = s[1 : len(s)-1]
+	}
 	parts := strings.Split(s, ",")
 	result := make([]string, 0, len(parts))
 	for _, p := range parts {
@@ -208,6 +352,10 @@ func (fm *FrontMatter) Validate(required []string) []ValidationError {
 			if fm.Slug == "" {
 				errs = append(errs, ValidationError{Field: "slug", Message: "required"})
 			}
+		case "author", "authors":
+			if len(fm.AuthorList()) == 0 {
+				errs = append(errs, ValidationError{Field: field, Message: "required"})
+			}
 		default:
 			// Check extra fields
 			if _, ok := fm.Extra[field]; !ok {
@@ -219,18 +367,152 @@ func (fm *FrontMatter) Validate(required []string) []ValidationError {
 	return errs
 }
 
-// ApplyDefaults fills in missing fields from defaults.
+// AuthorList merges the single author and authors fields, dropping
+// duplicates and keeping front matter order.
+func (fm *FrontMatter) AuthorList() []string {
+	var authors []string
+	seen := make(map[string]bool)
+	for _, author := range append([]string{fm.Author}, fm.Authors...) {
+		if author != "" && !seen[author] {
+			seen[author] = true
+			authors = append(authors, author)
+		}
+	}
+	return authors
+}
+
+// ApplyDefaults fills in the fields the page hasn't set from defaults,
+// typed like front matter. Each key is only applied once, so a nearer
+// cascade applied first wins over later ones, and an explicit false or
+// zero in the page wins over a default.
 func (fm *FrontMatter) ApplyDefaults(defaults map[string]any) {
 	for k, v := range defaults {
-		switch k {
-		case "draft":
-			if b, ok := v.(bool); ok && !fm.Draft {
-				fm.Draft = b
+		key := strings.ToLower(k)
+		if fm.set[key] || ((key == "author" || key == "authors") && (fm.set["author"] || fm.set["authors"])) {
+			continue
+		}
+		if !fm.applyDefault(key, v) {
+			if _, exists := fm.Extra[k]; exists {
+				continue
 			}
-		default:
-			if _, exists := fm.Extra[k]; !exists {
-				fm.Extra[k] = v
+			if fm.Extra == nil {
+				fm.Extra = make(map[string]any)
+			}
+			fm.Extra[k] = v
+		}
+		fm.markSet(key)
+	}
+}
+
+// applyDefault sets the typed field named by key from a config value,
+// reporting false for keys that aren't typed fields. Values of the wrong
+// type are ignored.
+func (fm *FrontMatter) applyDefault(key string, v any) bool {
+	switch key {
+	case "title":
+		fm.Title = defaultString(v, fm.Title)
+	case "description":
+		fm.Description = defaultString(v, fm.Description)
+	case "slug":
+		fm.Slug = defaultString(v, fm.Slug)
+	case "author":
+		fm.Author = defaultString(v, fm.Author)
+	case "type":
+		fm.Type = defaultString(v, fm.Type)
+	case "layout":
+		fm.Layout = defaultString(v, fm.Layout)
+	case "series":
+		fm.Series = defaultString(v, fm.Series)
+	case "lang":
+		fm.Lang = defaultString(v, fm.Lang)
+	case "translationkey":
+		fm.TranslationKey = defaultString(v, fm.TranslationKey)
+	case "draft":
+		switch b := v.(type) {
+		case bool:
+			fm.Draft = b
+		case string:
+			if parsed, ok := parseBool(b); ok {
+				fm.Draft = parsed
+			}
+		}
+	case "weight":
+		fm.Weight = defaultInt(v, fm.Weight)
+	case "seriesorder":
+		fm.SeriesOrder = defaultInt(v, fm.SeriesOrder)
+	case "date":
+		fm.Date = defaultTime(v, fm.Date)
+	case "lastmod":
+		fm.LastMod = defaultTime(v, fm.LastMod)
+	case "tags":
+		fm.Tags = defaultStrings(v, fm.Tags)
+	case "authors":
+		fm.Authors = defaultStrings(v, fm.Authors)
+	case "aliases":
+		fm.Aliases = defaultStrings(v, fm.Aliases)
+	default:
+		return false
+	}
+	return true
+}
+
+func (fm *FrontMatter) markSet(key string) {
+	if fm.set == nil {
+		fm.set = make(map[string]bool)
+	}
+	fm.set[strings.ToLower(key)] = true
+}
+
+func defaultString(v any, current string) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	return current
+}
+
+// defaultInt reads whole numbers from config, where JSON gives float64.
+func defaultInt(v any, current int) int {
+	switch n := v.(type) {
+	case int:
+		return n
+	case int64:
+		return int(n)
+	case float64:
+		if n == float64(int(n)) {
+			return int(n)
+		}
+	}
+	return current
+}
+
+func defaultTime(v any, current time.Time) time.Time {
+	switch t := v.(type) {
+	case time.Time:
+		return t
+	case string:
+		if parsed, err := parseDate(t); err == nil {
+			return parsed
+		}
+	}
+	return current
+}
+
+func defaultStrings(v any, current []string) []string {
+	switch list := v.(type) {
+	case []string:
+		return list
+	case []any:
+		strs := make([]string, 0, len(list))
+		for _, item := range list {
+			s, ok := item.(string)
+			if !ok {
+				return current
 			}
+			strs = append(strs, s)
 		}
+		return strs
+	case string:
+		return parseList(list)
 	}
+	return current
 }
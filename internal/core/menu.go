@@ -0,0 +1,50 @@
+package core
+
+import "strings"
+
+// MenuEntry is a resolved menu link. Entries come from Config.Nav (the
+// "main" menu), Config.Menus, and pages whose front matter names a menu.
+type MenuEntry struct {
+	Title    string
+	URL      string
+	Weight   int
+	Page     *Page // the registering page, nil for configured entries
+	Children []*MenuEntry
+}
+
+// IsActive reports whether the entry links to url, the URL being rendered,
+// ignoring trailing slashes:
+//
+//	{{range .Site.Menus.main}}<a{{if .IsActive $.URL}} aria-current="page"{{end}} href="{{.URL}}">{{.Title}}</a>{{end}}
+func (e *MenuEntry) IsActive(url string) bool {
+	return url != "" && e.URL != "" && trimSlash(e.URL) == trimSlash(url)
+}
+
+// HasActive reports whether url is a child entry's link or lives under
+// the entry's URL, so a "/blog/" entry is marked for every blog post.
+func (e *MenuEntry) HasActive(url string) bool {
+	if !e.IsActive(url) && URLActive(url, e.URL) {
+		return true
+	}
+	for _, child := range e.Children {
+		if child.IsActive(url) || child.HasActive(url) {
+			return true
+		}
+	}
+	return false
+}
+
+// URLActive reports whether link is the current URL or one of its
+// ancestors, ignoring trailing slashes, so "/blog" is active on
+// "/blog/" and "/blog/post/". The root URL only matches itself.
+func URLActive(current, link string) bool {
+	if current == "" || link == "" {
+		return false
+	}
+	current, link = trimSlash(current), trimSlash(link)
+	return current == link || (link != "" && strings.HasPrefix(current, link+"/"))
+}
+
+func trimSlash(url string) string {
+	return strings.TrimRight(url, "/")
+}
@@ -0,0 +1,275 @@
+package core
+
+import (
+	"fmt"
+	"hash/fnv"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Slugify lowercases text, romanizes accented Latin, Greek, and Cyrillic
+// letters, and joins words with single hyphens, dropping anything other
+// than ASCII letters and digits. Text with nothing left, such as CJK or
+// emoji, gets a stable hash instead so it still has a usable slug.
+func Slugify(text string) string {
+	var result strings.Builder
+	hyphen := false
+	for _, c := range strings.ToLower(text) {
+		var part string
+		switch {
+		case unicode.IsSpace(c) || c == '-':
+			hyphen = result.Len() > 0
+			continue
+		case (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9'):
+			part = string(c)
+		default:
+			part = transliterations[c]
+		}
+		if part == "" {
+			continue
+		}
+		if hyphen {
+			result.WriteByte('-')
+			hyphen = false
+		}
+		result.WriteString(part)
+	}
+
+	if result.Len() == 0 && strings.TrimSpace(text) != "" {
+		h := fnv.New32a()
+		h.Write([]byte(text))
+		return fmt.Sprintf("%08x", h.Sum32())
+	}
+	return result.String()
+}
+
+// Terms returns the page's terms for a taxonomy. Tags come from
+// Page.Tags; other taxonomies are read from the front matter key of the
+// same name as a list or comma-separated string.
+func (p *Page) Terms(taxonomy string) []string {
+	if taxonomy == "tags" {
+		return p.Tags
+	}
+
+	switch value := p.Params[taxonomy].(type) {
+	case []string:
+		return value
+	case []any:
+		terms := make([]string, 0, len(value))
+		for _, v := range value {
+			if s, ok := v.(string); ok && s != "" {
+				terms = append(terms, s)
+			}
+		}
+		return terms
+	case string:
+		return parseList(value)
+	}
+	return nil
+}
+
+// RegularPages returns the content pages in AllPages, leaving out list
+// pages.
+func (s *Site) RegularPages() []*Page {
+	return s.PagesOfKind(KindPage)
+}
+
+// PagesOfKind returns the pages in AllPages with the given Kind.
+func (s *Site) PagesOfKind(kind string) []*Page {
+	var pages []*Page
+	for _, page := range s.AllPages {
+		if page.Kind == kind {
+			pages = append(pages, page)
+		}
+	}
+	return pages
+}
+
+// Home returns the home page, or nil before the build has indexed it.
+func (s *Site) Home() *Page {
+	for _, page := range s.AllPages {
+		if page.Kind == KindHome {
+			return page
+		}
+	}
+	return nil
+}
+
+// ReadingTime returns the minutes needed to read words at wordsPerMinute,
+// rounded up, with 200 words per minute when it isn't positive.
+func ReadingTime(words, wordsPerMinute int) int {
+	if wordsPerMinute <= 0 {
+		wordsPerMinute = 200
+	}
+	return (words + wordsPerMinute - 1) / wordsPerMinute
+}
+
+// Translate looks up key in lang's translations, then the default
+// language's.
+func (s *Site) Translate(lang, key string) (string, bool) {
+	if text, ok := s.I18n[lang][key]; ok {
+		return text, true
+	}
+	text, ok := s.I18n[s.Config.Language][key]
+	return text, ok
+}
+
+// Resource returns the bundled file with the given name, relative to the
+// bundle directory, or nil when the page has none by that name.
+func (p *Page) Resource(name string) *Resource {
+	for _, res := range p.Resources {
+		if res.Name == name {
+			return res
+		}
+	}
+	return nil
+}
+
+// HasShortcode reports whether the page body uses the named shortcode:
+//
+//	{{if .Page.HasShortcode "tweet"}}<script async src="https://platform.twitter.com/widgets.js"></script>{{end}}
+func (p *Page) HasShortcode(name string) bool {
+	_, found := slices.BinarySearch(p.UsedShortcodes, name)
+	return found
+}
+
+// Param looks up a front matter value. Dotted keys like "meta.reviewer"
+// walk nested maps in Params. Keys not in Params fall back to the standard
+// fields by their front matter name, such as "title" or "date".
+func (p *Page) Param(key string) (any, bool) {
+	var value any = p.Params
+	for _, part := range strings.Split(key, ".") {
+		m, ok := value.(map[string]any)
+		if !ok {
+			value = nil
+			break
+		}
+		if value, ok = m[part]; !ok {
+			break
+		}
+	}
+	if value != nil {
+		return value, true
+	}
+
+	switch key {
+	case "title":
+		return p.Title, true
+	case "description":
+		return p.Description, true
+	case "slug":
+		return p.Slug, true
+	case "section":
+		return p.Section, true
+	case "summary":
+		return p.Summary, true
+	case "date":
+		return p.Date, !p.Date.IsZero()
+	case "lastmod":
+		return p.LastMod, !p.LastMod.IsZero()
+	case "tags":
+		return p.Tags, len(p.Tags) > 0
+	case "authors":
+		return p.Authors, len(p.Authors) > 0
+	case "series":
+		return p.Series, p.Series != ""
+	case "weight":
+		return p.Weight, true
+	}
+	return nil, false
+}
+
+// SeriesURL returns the URL of the page's series index, or "" when the
+// page is not part of a series.
+func (p *Page) SeriesURL() string {
+	if p.Series == "" {
+		return ""
+	}
+	return "/series/" + Slugify(p.Series) + "/"
+}
+
+// SeriesPart returns the page's 1-based position in its series, or 0.
+func (p *Page) SeriesPart() int {
+	for i, page := range p.SeriesPages {
+		if page == p {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// TagCount pairs a tag with the number of pages using it.
+type TagCount struct {
+	Name  string
+	Count int
+	Pages []*Page
+}
+
+// PageByPath returns the page whose source path is sourcePath, or nil.
+// A leading slash is ignored, and "blog/post" also matches "blog/post.md"
+// and the bundle "blog/post/index.md".
+func (s *Site) PageByPath(sourcePath string) *Page {
+	sourcePath = strings.TrimPrefix(path.Clean("/"+strings.ReplaceAll(sourcePath, "\\", "/")), "/")
+	for _, candidate := range []string{sourcePath, sourcePath + ".md", sourcePath + "/index.md"} {
+		if page, ok := s.PagesBySource[candidate]; ok {
+			return page
+		}
+	}
+	return nil
+}
+
+// SectionList returns the site's sections sorted by name.
+func (s *Site) SectionList() []*Section {
+	sections := make([]*Section, 0, len(s.Sections))
+	for _, section := range s.Sections {
+		sections = append(sections, section)
+	}
+	sort.Slice(sections, func(i, j int) bool {
+		return sections[i].Name < sections[j].Name
+	})
+	return sections
+}
+
+// Author returns metadata for an author ID. Authors missing from
+// Config.Authors get their ID as Name, so templates can always render one.
+func (s *Site) Author(id string) AuthorInfo {
+	info := s.Config.Authors[id]
+	info.ID = id
+	if info.Name == "" {
+		info.Name = id
+	}
+	return info
+}
+
+// TagList returns the site's tags sorted by page count (descending),
+// then by name.
+func (s *Site) TagList() []TagCount {
+	tags := make([]TagCount, 0, len(s.Tags))
+	for name, pages := range s.Tags {
+		tags = append(tags, TagCount{Name: name, Count: len(pages), Pages: pages})
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		if tags[i].Count != tags[j].Count {
+			return tags[i].Count > tags[j].Count
+		}
+		return tags[i].Name < tags[j].Name
+	})
+	return tags
+}
+
+// titleize turns a section or term name into a display title.
+// "getting-started" -> "Getting Started"
+func titleize(name string) string {
+	words := strings.FieldsFunc(name, func(r rune) bool {
+		return r == '-' || r == '_' || unicode.IsSpace(r)
+	})
+	for i, word := range words {
+		r, size := utf8.DecodeRuneInString(word)
+		words[i] = string(unicode.ToUpper(r)) + word[size:]
+	}
+	return strings.Join(words, " ")
+}
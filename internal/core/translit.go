@@ -0,0 +1,59 @@
+package core
+
+// transliterations romanizes lowercase letters Slugify would otherwise
+// drop: Latin letters with diacritics, ligatures, Greek, and Cyrillic.
+var transliterations = buildTransliterations()
+
+func buildTransliterations() map[rune]string {
+	table := map[rune]string{
+		'æ': "ae", 'œ': "oe", 'ß': "ss", 'þ': "th", 'ĳ': "ij",
+
+		// Greek
+		'α': "a", 'β': "v", 'γ': "g", 'δ': "d", 'ε': "e", 'ζ': "z",
+		'η': "i", 'θ': "th", 'ι': "i", 'κ': "k", 'λ': "l", 'μ': "m",
+		'ν': "n", 'ξ': "x", 'ο': "o", 'π': "p", 'ρ': "r", 'σ': "s",
+		'ς': "s", 'τ': "t", 'υ': "y", 'φ': "f", 'χ': "ch", 'ψ': "ps",
+		'ω': "o", 'ά': "a", 'έ': "e", 'ή': "i", 'ί': "i", 'ό': "o",
+		'ύ': "y", 'ώ': "o", 'ϊ': "i", 'ϋ': "y", 'ΐ': "i", 'ΰ': "y",
+
+		// Cyrillic
+		'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e",
+		'ё': "e", 'ж': "zh", 'з': "z", 'и': "i", 'й': "y", 'к': "k",
+		'л': "l", 'м': "m", 'н': "n", 'о': "o", 'п': "p", 'р': "r",
+		'с': "s", 'т': "t", 'у': "u", 'ф': "f", 'х': "kh", 'ц': "ts",
+		'ч': "ch", 'ш': "sh", 'щ': "shch", 'ъ': "", 'ы': "y", 'ь': "",
+		'э': "e", 'ю': "yu", 'я': "ya", 'і': "i", 'ї': "yi", 'є': "ye",
+		'ґ': "g", 'ў': "u", 'ј': "j", 'љ': "lj", 'њ': "nj", 'ћ': "c",
+		'ђ': "dj", 'џ': "dz",
+	}
+
+	// Latin letters with diacritics, by base letter
+	accented := map[string]string{
+		"a": "àáâãäåāăą",
+		"c": "çćĉċč",
+		"d": "ďđð",
+		"e": "èéêëēĕėęě",
+		"g": "ĝğġģ",
+		"h": "ĥħ",
+		"i": "ìíîïĩīĭįı",
+		"j": "ĵ",
+		"k": "ķ",
+		"l": "ĺļľŀł",
+		"n": "ñńņňŉ",
+		"o": "òóôõöøōŏő",
+		"r": "ŕŗř",
+		"s": "śŝşšș",
+		"t": "ţťŧț",
+		"u": "ùúûüũūŭůűų",
+		"w": "ŵ",
+		"y": "ýÿŷ",
+		"z": "źżž",
+	}
+	for base, letters := range accented {
+		for _, r := range letters {
+			table[r] = base
+		}
+	}
+
+	return table
+}
@@ -2,6 +2,7 @@
 package core
 
 import (
+	"fmt"
 	"time"
 )
 
@@ -9,25 +10,80 @@ import (
 type Site struct {
 	Config   Config
 	Sections map[string]*Section
-	Pages    []*Page
+	Pages    []*Page // content pages
 	Tags     map[string][]*Page
+	Authors  map[string][]*Page
+	Series   map[string][]*Page // keyed by series name, in series order
+
+	// Taxonomies maps a taxonomy's plural name to its terms' pages.
+	// Taxonomies["tags"] is the same map as Tags.
+	Taxonomies map[string]map[string][]*Page
+
+	// PagesBySource indexes pages by their content-relative source path
+	// with forward slashes, e.g. "blog/hello.md".
+	PagesBySource map[string]*Page
+
+	// AllPages is Pages plus the generated list pages (home, sections,
+	// taxonomies, and terms), each with its Kind set
+	AllPages []*Page
+
+	// I18n holds template string translations: language code -> key -> text
+	I18n map[string]map[string]string
+
+	// Translations groups pages that are versions of each other, keyed by
+	// translation key, each group sorted by Lang
+	Translations map[string][]*Page
+
+	// Menus holds each named menu's entries sorted by weight
+	Menus map[string][]*MenuEntry
 }
 
 // NewSite creates a new site with initialized maps.
 func NewSite(cfg Config) *Site {
+	tags := make(map[string][]*Page)
 	return &Site{
-		Config:   cfg,
-		Sections: make(map[string]*Section),
-		Tags:     make(map[string][]*Page),
+		Config:        cfg,
+		Sections:      make(map[string]*Section),
+		Tags:          tags,
+		Authors:       make(map[string][]*Page),
+		Series:        make(map[string][]*Page),
+		Taxonomies:    map[string]map[string][]*Page{"tags": tags},
+		PagesBySource: make(map[string]*Page),
+		Translations:  make(map[string][]*Page),
+		Menus:         make(map[string][]*MenuEntry),
 	}
 }
 
 // Section represents a content section (blog, guides, etc.).
 type Section struct {
 	Name  string
+	Title string // display name, e.g. "Getting Started" for getting-started
+	URL   string // list page URL path, e.g. "/tags/go/"
 	Pages []*Page
+
+	// Lang is the language of Pages when a multilingual site lists a
+	// section per language
+	Lang string
 }
 
+// NewSection creates a section with a title derived from its name.
+func NewSection(name string) *Section {
+	return &Section{
+		Name:  name,
+		Title: titleize(name),
+	}
+}
+
+// Page kinds. Content files are KindPage; the rest are the list pages a
+// build generates.
+const (
+	KindPage     = "page"
+	KindHome     = "home"
+	KindSection  = "section"  // a section index, /blog/
+	KindTaxonomy = "taxonomy" // a taxonomy's term index, /tags/
+	KindTerm     = "term"     // one term's pages, /tags/go/
+)
+
 // Page represents a single page in the site.
 type Page struct {
 	// Identity
@@ -40,12 +96,26 @@ type Page struct {
 	Description string
 	Body        string // rendered HTML
 	RawContent  string // original markdown (without front matter)
+	ContentLine int    // 1-based source line where RawContent begins
 	Summary     string // plain text excerpt
+	WordCount   int    // words in the rendered body
+	ReadingTime int    // minutes to read at Config.ReadingSpeed
 	TOC         []TOCEntry
 
+	// UsedShortcodes names the shortcodes in the body, sorted, so layouts
+	// can include an embed's script only where it's used
+	UsedShortcodes []string
+
+	// Assets are the stylesheets and scripts the used shortcodes declare
+	Assets PageAssets
+
 	// Classification
+	Kind    string // KindPage for content; list pages use the other kinds
 	Section string
+	Type    string // content type choosing layouts/<type>.html; defaults to Section
+	Layout  string // layout override from front matter, "" for the default
 	Tags    []string
+	Authors []string // author IDs, keys into Config.Authors
 	Draft   bool
 
 	// Timestamps
@@ -53,15 +123,85 @@ type Page struct {
 	LastMod time.Time
 	Aliases []string // redirect URLs
 
-	// Navigation (for docs)
+	// Multilingual
+	Lang           string
+	TranslationKey string  // pages sharing a key are translations of each other
+	Translations   []*Page // other-language versions, sorted by Lang
+
+	// Navigation (for docs): neighbours in the section's page order
 	Weight   int
 	PrevPage *Page
 	NextPage *Page
 
+	// Series membership, independent of section prev/next
+	Series      string
+	SeriesOrder int
+	SeriesPages []*Page // every page in the series, in order
+	SeriesPrev  *Page
+	SeriesNext  *Page
+
+	// Related pages scored by shared tags, best match first
+	Related []*Page
+
+	// Page bundle resources (non-Markdown files beside an index.md)
+	Resources []*Resource
+
 	// Arbitrary front matter fields for templates
 	Params map[string]any
 }
 
+// PageAssets are static paths, such as "js/tabs.js", for a layout to
+// link through assetURL.
+type PageAssets struct {
+	CSS []string
+	JS  []string
+}
+
+// Resource represents a file bundled with a page.
+type Resource struct {
+	Name       string // path relative to the bundle directory
+	SourcePath string // relative path to source file
+	URL        string // final URL path
+
+	// Images processes the resource for Resize; nil when unavailable
+	Images ImageProcessor
+}
+
+// ImageProcessor produces derived versions of image resources.
+type ImageProcessor interface {
+	Resize(res *Resource, spec string) (string, error)
+	Size(res *Resource) (width, height int, err error)
+}
+
+// Resize returns the URL of a copy of the image scaled to spec, "600x",
+// "x400", or "600x400" (fit within the box), keeping its aspect ratio:
+//
+//	<img src="{{.Resize "600x"}}">
+func (r *Resource) Resize(spec string) (string, error) {
+	if r.Images == nil {
+		return "", fmt.Errorf("resizing %s: image processing is unavailable", r.Name)
+	}
+	return r.Images.Resize(r, spec)
+}
+
+// Width returns the image's width in pixels.
+func (r *Resource) Width() (int, error) {
+	if r.Images == nil {
+		return 0, fmt.Errorf("measuring %s: image processing is unavailable", r.Name)
+	}
+	width, _, err := r.Images.Size(r)
+	return width, err
+}
+
+// Height returns the image's height in pixels.
+func (r *Resource) Height() (int, error) {
+	if r.Images == nil {
+		return 0, fmt.Errorf("measuring %s: image processing is unavailable", r.Name)
+	}
+	_, height, err := r.Images.Size(r)
+	return height, err
+}
+
 // TOCEntry represents a table of contents item.
 type TOCEntry struct {
 	Level int
@@ -69,7 +209,16 @@ type TOCEntry struct {
 	Title string
 }
 
-// Config holds site-wide configuration from site.json.
+// TOCNode is a TOC entry with the entries nested under it, as the
+// rendered TOC lists them.
+type TOCNode struct {
+	Level    int        `json:"level"`
+	ID       string     `json:"id"`
+	Title    string     `json:"title"`
+	Children []*TOCNode `json:"children,omitempty"`
+}
+
+// Config holds site-wide configuration from the site config file.
 type Config struct {
 	// Required
 	Name    string `json:"name"`
@@ -78,7 +227,11 @@ type Config struct {
 	// Optional with defaults
 	Title       string `json:"title"`
 	Description string `json:"description"`
-	Language    string `json:"language"`
+	Language    string `json:"language"` // default language code
+
+	// Languages the site is written in, keyed by code like "fr". Content
+	// files named about.fr.md or under content/fr/ take that language.
+	Languages map[string]LanguageConfig `json:"languages"`
 
 	// Directories (relative to site root)
 	ContentDir  string `json:"contentDir"`
@@ -86,25 +239,99 @@ type Config struct {
 	StaticDir   string `json:"staticDir"`
 	OutputDir   string `json:"outputDir"`
 
+	// CacheDir keeps generated files, such as resized images, between
+	// builds
+	CacheDir string `json:"cacheDir"`
+
+	// Theme names a directory under themes/ whose templates and static
+	// dirs back the site's own; site files with the same path win.
+	Theme string `json:"theme"`
+
 	// Build options
-	BuildDrafts bool `json:"buildDrafts"`
+	BuildDrafts bool        `json:"buildDrafts"`
+	Build       BuildConfig `json:"build"`
+
+	// ReadingSpeed is the words per minute behind Page.ReadingTime
+	ReadingSpeed int `json:"readingSpeed"`
+
+	// StructuredData adds schema.org Article JSON-LD to content pages
+	StructuredData bool `json:"structuredData"`
+
+	// AutoTOC exposes each page's TOC to layouts without a toc shortcode.
+	// Pages opt out with "toc": false in front matter.
+	AutoTOC bool `json:"autoTOC"`
+
+	// WarnAssetSize warns about static files larger than this many bytes (0 disables)
+	WarnAssetSize int64 `json:"warnAssetSize"`
+
+	// Fingerprint lists static files to publish with a content hash in
+	// their name for cache-busting, as glob patterns relative to the
+	// static directory like "css/*.css". Templates link them with assetURL.
+	Fingerprint []string `json:"fingerprint"`
 
 	// Search options
 	Search SearchConfig `json:"search"`
 
+	// Sitemap options
+	Sitemap SitemapConfig `json:"sitemap"`
+
+	// RSS feed options
+	Feeds FeedsConfig `json:"feeds"`
+
+	// robots.txt options
+	Robots RobotsConfig `json:"robots"`
+
+	// Related pages options
+	Related RelatedConfig `json:"related"`
+
+	// Markdown rendering options
+	Markdown MarkdownConfig `json:"markdown"`
+
+	// Author metadata keyed by the IDs used in front matter
+	Authors map[string]AuthorInfo `json:"authors"`
+
+	// Taxonomies in addition to the built-in tags. Declaring "tags" here
+	// customizes its naming.
+	Taxonomies []TaxonomyConfig `json:"taxonomies"`
+
 	// Permalink styles per section
 	Permalinks map[string]string `json:"permalinks"`
 
-	// Navigation structure
+	// Navigation structure, which is also the "main" menu
 	Nav []NavItem `json:"nav"`
 
+	// Menus are named link lists such as "footer"; pages add themselves
+	// with a front matter menu field
+	Menus map[string][]NavItem `json:"menus"`
+
 	// Section-specific front matter schemas
 	Sections map[string]SectionConfig `json:"sections"`
 
+	// OutputFormats are representations pages can be rendered to besides
+	// HTML, keyed by name like "json". Sections opt in with outputs.
+	OutputFormats map[string]OutputFormat `json:"outputFormats"`
+
 	// Arbitrary config for templates
 	Params map[string]any `json:"params"`
 }
 
+// LanguageConfig describes one of the site's languages.
+type LanguageConfig struct {
+	Name string `json:"name"` // display name, e.g. "Français"
+
+	// Prefix is the URL path the language's pages live under. It
+	// defaults to "/<code>" for all but the default language.
+	Prefix string `json:"prefix"`
+}
+
+// OutputFormat describes a page representation rendered alongside its
+// HTML with the formats/<name>/ templates.
+type OutputFormat struct {
+	// FileName is written in the page's directory in place of
+	// index.html, e.g. "index.json" for /blog/post/index.json
+	FileName string `json:"fileName"`
+}
+
 // NavItem represents a navigation entry.
 type NavItem struct {
 	Title    string    `json:"title"`
@@ -118,31 +345,225 @@ type SectionConfig struct {
 	// Default front matter values
 	Defaults map[string]any `json:"defaults"`
 
+	// Cascade holds front matter values for every page under this
+	// directory, including nested ones. Keys may be nested paths such as
+	// "docs/guides"; the nearest directory's cascade wins, and cascades
+	// win over Defaults.
+	Cascade map[string]any `json:"cascade"`
+
 	// Required fields (build fails if missing)
 	Required []string `json:"required"`
 
 	// Permalink pattern override
 	Permalink string `json:"permalink"`
+
+	// AutoTOC enables automatic TOCs for this section only
+	AutoTOC bool `json:"autoTOC"`
+
+	// SortBy orders the section's pages, list and prev/next alike: "date",
+	// "weight", "title", "filename", or a front matter param name. Empty
+	// keeps the site-wide order.
+	SortBy string `json:"sortBy"`
+
+	// SortOrder is "asc" or "desc". Defaults to "desc" for dates and
+	// "asc" otherwise.
+	SortOrder string `json:"sortOrder"`
+
+	// Outputs names the output formats the section's pages are rendered
+	// to in addition to HTML
+	Outputs []string `json:"outputs"`
+}
+
+// BuildConfig defines build pipeline behavior.
+type BuildConfig struct {
+	// Parallel renders pages concurrently. Disable for sequential,
+	// deterministic builds when debugging.
+	Parallel bool `json:"parallel"`
+
+	// FileModTime uses a source file's modification time as its LastMod
+	// when front matter has none. Disable for reproducible builds.
+	FileModTime bool `json:"fileModTime"`
+
+	// FailOnEmptySite fails the build when no published pages are found,
+	// which usually means contentDir is wrong. Disable for sites that are
+	// intentionally content-less.
+	FailOnEmptySite bool `json:"failOnEmptySite"`
+
+	// StrictSlugs fails the build on a slug or filename that isn't already
+	// URL-safe instead of slugifying it.
+	StrictSlugs bool `json:"strictSlugs"`
+}
+
+// RelatedConfig defines how related pages are chosen.
+type RelatedConfig struct {
+	// Limit is the maximum number of related pages per page (0 disables)
+	Limit int `json:"limit"`
+
+	// SameSection adds a point for pages in the same section
+	SameSection bool `json:"sameSection"`
+}
+
+// TaxonomyConfig declares a taxonomy and how it is named.
+type TaxonomyConfig struct {
+	// Plural is the front matter key and URL segment, e.g. "categories"
+	Plural string `json:"plural"`
+
+	// Singular names one term, e.g. "category". When set, term pages are
+	// titled "Category: <term>"; otherwise just the term.
+	Singular string `json:"singular"`
+
+	// Title is the index page title. Defaults to the titleized plural.
+	Title string `json:"title"`
+}
+
+// AuthorInfo describes a content author.
+type AuthorInfo struct {
+	ID     string `json:"-"`
+	Name   string `json:"name"`
+	Bio    string `json:"bio"`
+	Avatar string `json:"avatar"`
+	Email  string `json:"email"`
+	URL    string `json:"url"` // external profile or homepage
+}
+
+// MarkdownConfig maps site config onto markdown.RenderOptions.
+type MarkdownConfig struct {
+	// DefaultCodeLang is the language class for unlabeled code fences
+	DefaultCodeLang string `json:"defaultCodeLang"`
+
+	// Emoji replaces :name: tokens with emoji characters
+	Emoji bool `json:"emoji"`
+
+	// Typographer enables smart quotes, dashes, and ellipses
+	Typographer bool `json:"typographer"`
+
+	// HeadingAnchors adds a "#" link to each heading
+	HeadingAnchors bool `json:"headingAnchors"`
+
+	// HeadingOffset demotes body headings, 1 turning "#" into <h2>, for
+	// layouts that render the title as the page's <h1>
+	HeadingOffset int `json:"headingOffset"`
+
+	// DiagramLanguages are code fence languages kept as source in a
+	// <pre class="lang"> for client-side renderers; ["mermaid"] by default
+	DiagramLanguages []string `json:"diagramLanguages"`
+
+	// Math renders $...$ and $$...$$ LaTeX into math elements for a
+	// client-side renderer such as KaTeX
+	Math bool `json:"math"`
+
+	// MarkdownInHTML renders Markdown inside <div markdown> and similar
+	// block elements, keeping only safe attributes
+	MarkdownInHTML bool `json:"markdownInHTML"`
+
+	// LazyImages lazy-loads Markdown images and gives bundle images their
+	// width and height
+	LazyImages bool `json:"lazyImages"`
+
+	// ExternalLinkTarget, ExternalLinkRel, and ExternalLinkClass are set
+	// on links to hosts other than the base URL's and InternalHosts', such
+	// as "_blank", "noopener noreferrer", and "external-link"
+	ExternalLinkTarget string   `json:"externalLinkTarget"`
+	ExternalLinkRel    string   `json:"externalLinkRel"`
+	ExternalLinkClass  string   `json:"externalLinkClass"`
+	InternalHosts      []string `json:"internalHosts"`
+
+	// InsertTOC puts a nested TOC at the top of every page body, or at a
+	// [TOC] marker. Pages opt in with "toc": true and out with false.
+	InsertTOC bool `json:"insertTOC"`
+
+	// TOCMinLevel and TOCMaxLevel bound the headings collected into page
+	// TOCs, inserted or not, 1 and 6 when unset
+	TOCMinLevel int `json:"tocMinLevel"`
+	TOCMaxLevel int `json:"tocMaxLevel"`
+}
+
+// SitemapConfig defines sitemap generation.
+type SitemapConfig struct {
+	Enabled bool `json:"enabled"`
+
+	// MaxURLs is the most URLs per sitemap file. Larger sites get
+	// sitemap-1.xml, sitemap-2.xml, ... and a sitemap.xml index.
+	MaxURLs int `json:"maxURLs"`
+}
+
+// FeedsConfig defines RSS feed generation.
+type FeedsConfig struct {
+	Enabled bool `json:"enabled"`
+
+	// Path is the feed's output path, "rss.xml" by default
+	Path string `json:"path"`
+}
+
+// RobotsConfig defines robots.txt generation. A robots.txt in the static
+// dir replaces the generated one.
+type RobotsConfig struct {
+	Enabled bool `json:"enabled"`
+
+	// Rules are written in order; with none, every agent is allowed everywhere
+	Rules []RobotsRule `json:"rules"`
+}
+
+// RobotsRule is one User-agent group in robots.txt.
+type RobotsRule struct {
+	UserAgent  string   `json:"userAgent"` // "*" when empty
+	Allow      []string `json:"allow"`
+	Disallow   []string `json:"disallow"`
+	CrawlDelay int      `json:"crawlDelay"` // seconds; omitted when zero
 }
 
 // SearchConfig defines search behavior.
 type SearchConfig struct {
 	Enabled bool `json:"enabled"`
+
+	// Path is the search index's output path, "search.json" by default
+	Path string `json:"path"`
+
+	// Versioned wraps search.json entries in {"version": N, "entries": [...]}
+	Versioned bool `json:"versioned"`
 }
 
 // DefaultConfig returns a Config with sensible defaults.
 func DefaultConfig() Config {
 	return Config{
-		Language:    "en",
-		ContentDir:  "content",
-		TemplateDir: "templates",
-		StaticDir:   "static",
-		OutputDir:   "public",
+		Language:     "en",
+		ContentDir:   "content",
+		TemplateDir:  "templates",
+		StaticDir:    "static",
+		OutputDir:    "public",
+		CacheDir:     ".canopy-cache",
+		ReadingSpeed: 200,
+		Build: BuildConfig{
+			Parallel:        true,
+			FileModTime:     true,
+			FailOnEmptySite: true,
+		},
 		Search: SearchConfig{
 			Enabled: true,
+			Path:    "search.json",
+		},
+		Sitemap: SitemapConfig{
+			Enabled: true,
+			MaxURLs: 50000, // the sitemaps.org per-file limit
+		},
+		Feeds: FeedsConfig{
+			Enabled: true,
+			Path:    "rss.xml",
+		},
+		Robots: RobotsConfig{
+			Enabled: true,
+		},
+		Related: RelatedConfig{
+			Limit: 5,
+		},
+		Markdown: MarkdownConfig{
+			DiagramLanguages: []string{"mermaid"},
 		},
 		Permalinks: make(map[string]string),
 		Sections:   make(map[string]SectionConfig),
-		Params:     make(map[string]any),
+		OutputFormats: map[string]OutputFormat{
+			"json": {FileName: "index.json"},
+		},
+		Params: make(map[string]any),
 	}
 }
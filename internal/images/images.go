@@ -0,0 +1,213 @@
+// Package images resizes page bundle images for templates.
+package images
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/shanepadgett/canopy/internal/core"
+)
+
+// Processor resizes image resources. Results are cached in a directory
+// keyed by the source's content hash and the operation, so unchanged
+// images aren't reprocessed on later builds. It is safe for concurrent use.
+type Processor struct {
+	contentDir string
+	cacheDir   string
+	dryRun     bool
+
+	mu      sync.Mutex
+	outputs map[string]string // output URL -> cached file
+	unsaved map[string][]byte // output URL -> image a dry run didn't cache
+}
+
+// NewProcessor creates a processor reading resources from contentDir and
+// caching results in cacheDir.
+func NewProcessor(contentDir, cacheDir string) *Processor {
+	return &Processor{
+		contentDir: contentDir,
+		cacheDir:   cacheDir,
+		outputs:    make(map[string]string),
+		unsaved:    make(map[string][]byte),
+	}
+}
+
+// DryRun keeps newly resized images in memory, see Unsaved, instead of
+// writing them to the cache. Cached results are still read.
+func (p *Processor) DryRun() {
+	p.dryRun = true
+}
+
+// Attach makes the processor handle Resize for every resource of pages.
+func (p *Processor) Attach(pages []*core.Page) {
+	for _, page := range pages {
+		for _, res := range page.Resources {
+			res.Images = p
+		}
+	}
+}
+
+// Resize scales res to spec and returns the URL of the result, which sits
+// beside the resource with the spec and a content hash in its name:
+// /blog/trip/photo.jpg -> /blog/trip/photo_600x.1a2b3c4d.jpg. Images are
+// never enlarged: a spec at or past the original's size returns res.URL.
+func (p *Processor) Resize(res *core.Resource, spec string) (string, error) {
+	width, height, err := ParseSize(spec)
+	if err != nil {
+		return "", fmt.Errorf("resizing %s: %w", res.Name, err)
+	}
+	format, err := formatOf(res.Name)
+	if err != nil {
+		return "", fmt.Errorf("resizing %s: %w", res.Name, err)
+	}
+
+	src := filepath.Join(p.contentDir, filepath.FromSlash(res.SourcePath))
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return "", fmt.Errorf("resizing %s: %w", res.Name, err)
+	}
+
+	if config, _, err := image.DecodeConfig(bytes.NewReader(data)); err == nil {
+		if w, h := fitSize(config.Width, config.Height, width, height); w >= config.Width && h >= config.Height {
+			return res.URL, nil
+		}
+	}
+
+	sum := sha256.Sum256(data)
+	op := strconv.Itoa(width) + "x" + strconv.Itoa(height)
+	cached := filepath.Join(p.cacheDir, hex.EncodeToString(sum[:8])+"_"+op+path.Ext(res.Name))
+
+	out, err := os.ReadFile(cached)
+	fresh := err != nil
+	if fresh {
+		if out, err = resize(data, format, width, height); err != nil {
+			return "", fmt.Errorf("resizing %s: %w", res.Name, err)
+		}
+		if !p.dryRun {
+			if err := writeCache(cached, out); err != nil {
+				return "", fmt.Errorf("caching %s: %w", res.Name, err)
+			}
+		}
+	}
+
+	outSum := sha256.Sum256(out)
+	ext := path.Ext(res.URL)
+	url := strings.TrimSuffix(res.URL, ext) + "_" + spec + "." + hex.EncodeToString(outSum[:4]) + ext
+
+	p.mu.Lock()
+	if fresh && p.dryRun {
+		p.unsaved[url] = out
+	} else {
+		p.outputs[url] = cached
+	}
+	p.mu.Unlock()
+	return url, nil
+}
+
+// Size returns the image's dimensions, reading only its header.
+func (p *Processor) Size(res *core.Resource) (width, height int, err error) {
+	if _, err := formatOf(res.Name); err != nil {
+		return 0, 0, fmt.Errorf("measuring %s: %w", res.Name, err)
+	}
+
+	f, err := os.Open(filepath.Join(p.contentDir, filepath.FromSlash(res.SourcePath)))
+	if err != nil {
+		return 0, 0, fmt.Errorf("measuring %s: %w", res.Name, err)
+	}
+	defer f.Close()
+
+	config, _, err := image.DecodeConfig(f)
+	if err != nil {
+		return 0, 0, fmt.Errorf("measuring %s: %w", res.Name, err)
+	}
+	return config.Width, config.Height, nil
+}
+
+// Outputs returns the processed images to publish, mapping each output URL
+// to its cached file. Images a dry run left out of the cache are in
+// Unsaved instead.
+func (p *Processor) Outputs() map[string]string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	outputs := make(map[string]string, len(p.outputs))
+	for url, file := range p.outputs {
+		outputs[url] = file
+	}
+	return outputs
+}
+
+// Unsaved returns the images a dry run resized without caching, by
+// output URL.
+func (p *Processor) Unsaved() map[string][]byte {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	unsaved := make(map[string][]byte, len(p.unsaved))
+	for url, data := range p.unsaved {
+		unsaved[url] = data
+	}
+	return unsaved
+}
+
+// ParseSize reads a resize spec: "600x" sets the width, "x400" the
+// height, and "600x400" a box the image is fitted within. Zero means the
+// dimension follows the aspect ratio.
+func ParseSize(spec string) (width, height int, err error) {
+	w, h, ok := strings.Cut(spec, "x")
+	if !ok || (w == "" && h == "") {
+		return 0, 0, fmt.Errorf("invalid size %q: want WIDTHx, xHEIGHT, or WIDTHxHEIGHT", spec)
+	}
+	if w != "" {
+		if width, err = strconv.Atoi(w); err != nil || width <= 0 {
+			return 0, 0, fmt.Errorf("invalid size %q: bad width", spec)
+		}
+	}
+	if h != "" {
+		if height, err = strconv.Atoi(h); err != nil || height <= 0 {
+			return 0, 0, fmt.Errorf("invalid size %q: bad height", spec)
+		}
+	}
+	return width, height, nil
+}
+
+func formatOf(name string) (string, error) {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".jpg", ".jpeg":
+		return "jpeg", nil
+	case ".png":
+		return "png", nil
+	}
+	return "", fmt.Errorf("unsupported image format %q: want JPEG or PNG", path.Ext(name))
+}
+
+// writeCache writes through a temporary file so a concurrent reader never
+// sees a partial image.
+func writeCache(file string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(file), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), file)
+}
@@ -0,0 +1,145 @@
+package images
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/shanepadgett/canopy/internal/core"
+)
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		spec          string
+		width, height int
+		wantErr       bool
+	}{
+		{spec: "600x", width: 600},
+		{spec: "x400", height: 400},
+		{spec: "600x400", width: 600, height: 400},
+		{spec: "600", wantErr: true},
+		{spec: "x", wantErr: true},
+		{spec: "0x", wantErr: true},
+		{spec: "ax10", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		width, height, err := ParseSize(tt.spec)
+		if (err != nil) != tt.wantErr || width != tt.width || height != tt.height {
+			t.Errorf("ParseSize(%q) = %d, %d, %v; want %d, %d, error %v", tt.spec, width, height, err, tt.width, tt.height, tt.wantErr)
+		}
+	}
+}
+
+func TestFitSize(t *testing.T) {
+	tests := []struct {
+		srcW, srcH, width, height int
+		wantW, wantH              int
+	}{
+		{800, 600, 400, 0, 400, 300},
+		{800, 600, 0, 150, 200, 150},
+		{800, 600, 400, 400, 400, 300},
+		{600, 800, 400, 400, 300, 400},
+		{100, 50, 200, 0, 200, 100},
+		{1000, 1, 10, 0, 10, 1},
+	}
+
+	for _, tt := range tests {
+		w, h := fitSize(tt.srcW, tt.srcH, tt.width, tt.height)
+		if w != tt.wantW || h != tt.wantH {
+			t.Errorf("fitSize(%d, %d, %d, %d) = %dx%d, want %dx%d", tt.srcW, tt.srcH, tt.width, tt.height, w, h, tt.wantW, tt.wantH)
+		}
+	}
+}
+
+func TestResize(t *testing.T) {
+	contentDir := t.TempDir()
+	cacheDir := filepath.Join(t.TempDir(), "images")
+
+	// Left half red, right half blue
+	src := image.NewRGBA(image.Rect(0, 0, 8, 4))
+	for y := range 4 {
+		for x := range 8 {
+			c := color.RGBA{R: 255, A: 255}
+			if x >= 4 {
+				c = color.RGBA{B: 255, A: 255}
+			}
+			src.Set(x, y, c)
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, src); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(contentDir, "blog", "trip"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(contentDir, "blog", "trip", "photo.png"), buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	res := &core.Resource{Name: "photo.png", SourcePath: "blog/trip/photo.png", URL: "/blog/trip/photo.png"}
+	processor := NewProcessor(contentDir, cacheDir)
+	processor.Attach([]*core.Page{{Resources: []*core.Resource{res}}})
+
+	url, err := res.Resize("4x")
+	if err != nil {
+		t.Fatalf("Resize failed: %v", err)
+	}
+	if !strings.HasPrefix(url, "/blog/trip/photo_4x.") || !strings.HasSuffix(url, ".png") {
+		t.Errorf("url = %q, want /blog/trip/photo_4x.<hash>.png", url)
+	}
+
+	cached, ok := processor.Outputs()[url]
+	if !ok {
+		t.Fatalf("outputs = %v, want %s", processor.Outputs(), url)
+	}
+	data, err := os.ReadFile(cached)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size := img.Bounds().Size(); size != image.Pt(4, 2) {
+		t.Errorf("size = %v, want 4x2", size)
+	}
+	if r, _, b, _ := img.At(0, 0).RGBA(); r>>8 != 255 || b != 0 {
+		t.Errorf("left pixel = %v, want red", img.At(0, 0))
+	}
+	if r, _, b, _ := img.At(3, 1).RGBA(); r != 0 || b>>8 != 255 {
+		t.Errorf("right pixel = %v, want blue", img.At(3, 1))
+	}
+
+	// A later build reuses the cached result instead of reprocessing
+	info, err := os.Stat(cached)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := NewProcessor(contentDir, cacheDir).Resize(res, "4x")
+	if err != nil || again != url {
+		t.Errorf("second Resize = %q, %v; want %q", again, err, url)
+	}
+	if after, _ := os.Stat(cached); !after.ModTime().Equal(info.ModTime()) {
+		t.Error("expected the cached image to be reused")
+	}
+
+	// Images are never enlarged
+	if original, err := res.Resize("16x"); err != nil || original != res.URL {
+		t.Errorf("Resize(16x) = %q, %v; want the original %q", original, err, res.URL)
+	}
+
+	if _, err := res.Resize("wide"); err == nil {
+		t.Error("expected an error for an invalid size")
+	}
+	gif := &core.Resource{Name: "anim.gif", SourcePath: "blog/trip/anim.gif", URL: "/blog/trip/anim.gif"}
+	if _, err := processor.Resize(gif, "4x"); err == nil || !strings.Contains(err.Error(), "unsupported image format") {
+		t.Errorf("expected an unsupported format error, got %v", err)
+	}
+}
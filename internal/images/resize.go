@@ -0,0 +1,86 @@
+package images
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+)
+
+// jpegQuality is the quality resized JPEGs are encoded at.
+const jpegQuality = 85
+
+// resize decodes a JPEG or PNG, scales it to fit width and height (zero
+// follows the aspect ratio), and encodes it in the same format.
+func resize(data []byte, format string, width, height int) ([]byte, error) {
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	bounds := src.Bounds()
+	if bounds.Empty() {
+		return nil, errors.New("image is empty")
+	}
+	w, h := fitSize(bounds.Dx(), bounds.Dy(), width, height)
+	dst := scale(src, w, h)
+
+	var out bytes.Buffer
+	if format == "jpeg" {
+		err = jpeg.Encode(&out, dst, &jpeg.Options{Quality: jpegQuality})
+	} else {
+		err = png.Encode(&out, dst)
+	}
+	return out.Bytes(), err
+}
+
+// fitSize returns the largest size with the source's aspect ratio that
+// fits width and height, treating zero as unbounded.
+func fitSize(srcW, srcH, width, height int) (int, int) {
+	ratio := float64(width) / float64(srcW)
+	if hr := float64(height) / float64(srcH); width == 0 || (height > 0 && hr < ratio) {
+		ratio = hr
+	}
+	w := max(int(float64(srcW)*ratio+0.5), 1)
+	h := max(int(float64(srcH)*ratio+0.5), 1)
+	return w, h
+}
+
+// scale resamples src to w x h by averaging the source pixels each
+// destination pixel covers. Colors are averaged premultiplied so
+// transparent pixels don't darken edges.
+func scale(src image.Image, w, h int) *image.RGBA {
+	bounds := src.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), src, bounds.Min, draw.Src)
+	srcW, srcH := bounds.Dx(), bounds.Dy()
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := range h {
+		y0 := y * srcH / h
+		y1 := max((y+1)*srcH/h, y0+1)
+		for x := range w {
+			x0 := x * srcW / w
+			x1 := max((x+1)*srcW/w, x0+1)
+
+			var sum [4]int
+			for sy := y0; sy < y1; sy++ {
+				row := rgba.Pix[sy*rgba.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					for c := range sum {
+						sum[c] += int(row[sx*4+c])
+					}
+				}
+			}
+
+			n := (y1 - y0) * (x1 - x0)
+			i := y*dst.Stride + x*4
+			for c := range sum {
+				dst.Pix[i+c] = uint8((sum[c] + n/2) / n)
+			}
+		}
+	}
+	return dst
+}
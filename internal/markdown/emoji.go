@@ -0,0 +1,593 @@
+package markdown
+
+import "regexp"
+
+// emojiPattern matches :name: tokens. Names use the GitHub/Slack
+// vocabulary: lowercase letters, digits, underscores, "+", and "-".
+var emojiPattern = regexp.MustCompile(`:([a-z0-9_+\-]+):`)
+
+// replaceEmoji maps known :name: tokens to their Unicode characters,
+// passing each through hold so characters like the "*" in :asterisk:
+// aren't read as emphasis. Unknown tokens are left as written.
+func replaceEmoji(text string, hold func(string) string) string {
+	return emojiPattern.ReplaceAllStringFunc(text, func(token string) string {
+		if emoji, ok := emojiTable[token[1:len(token)-1]]; ok {
+			return hold(emoji)
+		}
+		return token
+	})
+}
+
+// emojiTable maps emoji names to their Unicode characters.
+var emojiTable = map[string]string{
+	"smile":                        "😄",
+	"smiley":                       "😃",
+	"grinning":                     "😀",
+	"grin":                         "😁",
+	"laughing":                     "😆",
+	"satisfied":                    "😆",
+	"sweat_smile":                  "😅",
+	"joy":                          "😂",
+	"rofl":                         "🤣",
+	"relaxed":                      "☺️",
+	"blush":                        "😊",
+	"innocent":                     "😇",
+	"slightly_smiling_face":        "🙂",
+	"upside_down_face":             "🙃",
+	"wink":                         "😉",
+	"relieved":                     "😌",
+	"heart_eyes":                   "😍",
+	"star_struck":                  "🤩",
+	"kissing_heart":                "😘",
+	"kissing":                      "😗",
+	"yum":                          "😋",
+	"stuck_out_tongue":             "😛",
+	"stuck_out_tongue_winking_eye": "😜",
+	"zany_face":                    "🤪",
+	"stuck_out_tongue_closed_eyes": "😝",
+	"money_mouth_face":             "🤑",
+	"hugs":                         "🤗",
+	"hand_over_mouth":              "🤭",
+	"shushing_face":                "🤫",
+	"thinking":                     "🤔",
+	"zipper_mouth_face":            "🤐",
+	"raised_eyebrow":               "🤨",
+	"neutral_face":                 "😐",
+	"expressionless":               "😑",
+	"no_mouth":                     "😶",
+	"smirk":                        "😏",
+	"unamused":                     "😒",
+	"roll_eyes":                    "🙄",
+	"grimacing":                    "😬",
+	"lying_face":                   "🤥",
+	"pensive":                      "😔",
+	"sleepy":                       "😪",
+	"drooling_face":                "🤤",
+	"sleeping":                     "😴",
+	"mask":                         "😷",
+	"face_with_thermometer":        "🤒",
+	"nauseated_face":               "🤢",
+	"vomiting_face":                "🤮",
+	"sneezing_face":                "🤧",
+	"hot_face":                     "🥵",
+	"cold_face":                    "🥶",
+	"woozy_face":                   "🥴",
+	"dizzy_face":                   "😵",
+	"exploding_head":               "🤯",
+	"cowboy_hat_face":              "🤠",
+	"partying_face":                "🥳",
+	"sunglasses":                   "😎",
+	"nerd_face":                    "🤓",
+	"monocle_face":                 "🧐",
+	"confused":                     "😕",
+	"worried":                      "😟",
+	"slightly_frowning_face":       "🙁",
+	"frowning_face":                "☹️",
+	"open_mouth":                   "😮",
+	"hushed":                       "😯",
+	"astonished":                   "😲",
+	"flushed":                      "😳",
+	"pleading_face":                "🥺",
+	"frowning":                     "😦",
+	"anguished":                    "😧",
+	"fearful":                      "😨",
+	"cold_sweat":                   "😰",
+	"disappointed_relieved":        "😥",
+	"cry":                          "😢",
+	"sob":                          "😭",
+	"scream":                       "😱",
+	"confounded":                   "😖",
+	"persevere":                    "😣",
+	"disappointed":                 "😞",
+	"sweat":                        "😓",
+	"weary":                        "😩",
+	"tired_face":                   "😫",
+	"yawning_face":                 "🥱",
+	"triumph":                      "😤",
+	"rage":                         "😡",
+	"angry":                        "😠",
+	"cursing_face":                 "🤬",
+	"smiling_imp":                  "😈",
+	"imp":                          "👿",
+	"skull":                        "💀",
+	"poop":                         "💩",
+	"clown_face":                   "🤡",
+	"ghost":                        "👻",
+	"alien":                        "👽",
+	"robot":                        "🤖",
+	"see_no_evil":                  "🙈",
+	"hear_no_evil":                 "🙉",
+	"speak_no_evil":                "🙊",
+	"heart":                        "❤️",
+	"orange_heart":                 "🧡",
+	"yellow_heart":                 "💛",
+	"green_heart":                  "💚",
+	"blue_heart":                   "💙",
+	"purple_heart":                 "💜",
+	"black_heart":                  "🖤",
+	"white_heart":                  "🤍",
+	"broken_heart":                 "💔",
+	"sparkling_heart":              "💖",
+	"two_hearts":                   "💕",
+	"100":                          "💯",
+	"anger":                        "💢",
+	"boom":                         "💥",
+	"collision":                    "💥",
+	"dizzy":                        "💫",
+	"sweat_drops":                  "💦",
+	"dash":                         "💨",
+	"speech_balloon":               "💬",
+	"thought_balloon":              "💭",
+	"zzz":                          "💤",
+	"wave":                         "👋",
+	"raised_hand":                  "✋",
+	"hand":                         "✋",
+	"vulcan_salute":                "🖖",
+	"ok_hand":                      "👌",
+	"pinched_fingers":              "🤌",
+	"v":                            "✌️",
+	"crossed_fingers":              "🤞",
+	"love_you_gesture":             "🤟",
+	"metal":                        "🤘",
+	"call_me_hand":                 "🤙",
+	"point_left":                   "👈",
+	"point_right":                  "👉",
+	"point_up":                     "☝️",
+	"point_up_2":                   "👆",
+	"point_down":                   "👇",
+	"middle_finger":                "🖕",
+	"+1":                           "👍",
+	"thumbsup":                     "👍",
+	"-1":                           "👎",
+	"thumbsdown":                   "👎",
+	"fist":                         "✊",
+	"facepunch":                    "👊",
+	"punch":                        "👊",
+	"clap":                         "👏",
+	"raised_hands":                 "🙌",
+	"open_hands":                   "👐",
+	"handshake":                    "🤝",
+	"pray":                         "🙏",
+	"writing_hand":                 "✍️",
+	"muscle":                       "💪",
+	"eyes":                         "👀",
+	"eye":                          "👁️",
+	"brain":                        "🧠",
+	"baby":                         "👶",
+	"man":                          "👨",
+	"woman":                        "👩",
+	"person_shrugging":             "🤷",
+	"shrug":                        "🤷",
+	"facepalm":                     "🤦",
+	"runner":                       "🏃",
+	"running":                      "🏃",
+	"dancer":                       "💃",
+	"dog":                          "🐶",
+	"cat":                          "🐱",
+	"mouse":                        "🐭",
+	"rabbit":                       "🐰",
+	"fox_face":                     "🦊",
+	"bear":                         "🐻",
+	"panda_face":                   "🐼",
+	"koala":                        "🐨",
+	"tiger":                        "🐯",
+	"lion":                         "🦁",
+	"cow":                          "🐮",
+	"pig":                          "🐷",
+	"frog":                         "🐸",
+	"monkey":                       "🐒",
+	"chicken":                      "🐔",
+	"penguin":                      "🐧",
+	"bird":                         "🐦",
+	"owl":                          "🦉",
+	"unicorn":                      "🦄",
+	"bee":                          "🐝",
+	"honeybee":                     "🐝",
+	"bug":                          "🐛",
+	"butterfly":                    "🦋",
+	"snail":                        "🐌",
+	"turtle":                       "🐢",
+	"snake":                        "🐍",
+	"octopus":                      "🐙",
+	"crab":                         "🦀",
+	"fish":                         "🐟",
+	"whale":                        "🐳",
+	"dolphin":                      "🐬",
+	"shark":                        "🦈",
+	"hamster":                      "🐹",
+	"crocodile":                    "🐊",
+	"elephant":                     "🐘",
+	"horse":                        "🐴",
+	"bouquet":                      "💐",
+	"cherry_blossom":               "🌸",
+	"rose":                         "🌹",
+	"sunflower":                    "🌻",
+	"tulip":                        "🌷",
+	"seedling":                     "🌱",
+	"evergreen_tree":               "🌲",
+	"deciduous_tree":               "🌳",
+	"palm_tree":                    "🌴",
+	"cactus":                       "🌵",
+	"herb":                         "🌿",
+	"four_leaf_clover":             "🍀",
+	"maple_leaf":                   "🍁",
+	"fallen_leaf":                  "🍂",
+	"leaves":                       "🍃",
+	"mushroom":                     "🍄",
+	"earth_americas":               "🌎",
+	"earth_africa":                 "🌍",
+	"earth_asia":                   "🌏",
+	"globe_with_meridians":         "🌐",
+	"sunny":                        "☀️",
+	"cloud":                        "☁️",
+	"partly_sunny":                 "⛅",
+	"cloud_with_rain":              "🌧️",
+	"zap":                          "⚡",
+	"snowflake":                    "❄️",
+	"snowman":                      "⛄",
+	"fire":                         "🔥",
+	"droplet":                      "💧",
+	"ocean":                        "🌊",
+	"rainbow":                      "🌈",
+	"star":                         "⭐",
+	"star2":                        "🌟",
+	"sparkles":                     "✨",
+	"crescent_moon":                "🌙",
+	"full_moon":                    "🌕",
+	"new_moon":                     "🌑",
+	"sun_with_face":                "🌞",
+	"comet":                        "☄️",
+	"tornado":                      "🌪️",
+	"apple":                        "🍎",
+	"green_apple":                  "🍏",
+	"pear":                         "🍐",
+	"tangerine":                    "🍊",
+	"lemon":                        "🍋",
+	"banana":                       "🍌",
+	"watermelon":                   "🍉",
+	"grapes":                       "🍇",
+	"strawberry":                   "🍓",
+	"cherries":                     "🍒",
+	"peach":                        "🍑",
+	"pineapple":                    "🍍",
+	"avocado":                      "🥑",
+	"tomato":                       "🍅",
+	"eggplant":                     "🍆",
+	"carrot":                       "🥕",
+	"corn":                         "🌽",
+	"hot_pepper":                   "🌶️",
+	"bread":                        "🍞",
+	"cheese":                       "🧀",
+	"egg":                          "🥚",
+	"bacon":                        "🥓",
+	"hamburger":                    "🍔",
+	"fries":                        "🍟",
+	"pizza":                        "🍕",
+	"hotdog":                       "🌭",
+	"taco":                         "🌮",
+	"burrito":                      "🌯",
+	"sushi":                        "🍣",
+	"ramen":                        "🍜",
+	"spaghetti":                    "🍝",
+	"rice":                         "🍚",
+	"cookie":                       "🍪",
+	"cake":                         "🍰",
+	"birthday":                     "🎂",
+	"doughnut":                     "🍩",
+	"chocolate_bar":                "🍫",
+	"candy":                        "🍬",
+	"lollipop":                     "🍭",
+	"icecream":                     "🍦",
+	"popcorn":                      "🍿",
+	"coffee":                       "☕",
+	"tea":                          "🍵",
+	"beer":                         "🍺",
+	"beers":                        "🍻",
+	"wine_glass":                   "🍷",
+	"cocktail":                     "🍸",
+	"tropical_drink":               "🍹",
+	"champagne":                    "🍾",
+	"clinking_glasses":             "🥂",
+	"milk_glass":                   "🥛",
+	"soccer":                       "⚽",
+	"basketball":                   "🏀",
+	"football":                     "🏈",
+	"baseball":                     "⚾",
+	"tennis":                       "🎾",
+	"volleyball":                   "🏐",
+	"8ball":                        "🎱",
+	"golf":                         "⛳",
+	"trophy":                       "🏆",
+	"medal_sports":                 "🏅",
+	"1st_place_medal":              "🥇",
+	"2nd_place_medal":              "🥈",
+	"3rd_place_medal":              "🥉",
+	"dart":                         "🎯",
+	"video_game":                   "🎮",
+	"game_die":                     "🎲",
+	"jigsaw":                       "🧩",
+	"chess_pawn":                   "♟️",
+	"art":                          "🎨",
+	"performing_arts":              "🎭",
+	"guitar":                       "🎸",
+	"musical_note":                 "🎵",
+	"notes":                        "🎶",
+	"microphone":                   "🎤",
+	"headphones":                   "🎧",
+	"tada":                         "🎉",
+	"confetti_ball":                "🎊",
+	"balloon":                      "🎈",
+	"gift":                         "🎁",
+	"ribbon":                       "🎀",
+	"christmas_tree":               "🎄",
+	"jack_o_lantern":               "🎃",
+	"fireworks":                    "🎆",
+	"rocket":                       "🚀",
+	"airplane":                     "✈️",
+	"car":                          "🚗",
+	"red_car":                      "🚗",
+	"bus":                          "🚌",
+	"bike":                         "🚲",
+	"train":                        "🚆",
+	"ship":                         "🚢",
+	"boat":                         "⛵",
+	"sailboat":                     "⛵",
+	"anchor":                       "⚓",
+	"construction":                 "🚧",
+	"vertical_traffic_light":       "🚦",
+	"house":                        "🏠",
+	"office":                       "🏢",
+	"hospital":                     "🏥",
+	"school":                       "🏫",
+	"tent":                         "⛺",
+	"mountain":                     "⛰️",
+	"desert_island":                "🏝️",
+	"world_map":                    "🗺️",
+	"watch":                        "⌚",
+	"iphone":                       "📱",
+	"computer":                     "💻",
+	"keyboard":                     "⌨️",
+	"desktop_computer":             "🖥️",
+	"printer":                      "🖨️",
+	"floppy_disk":                  "💾",
+	"cd":                           "💿",
+	"dvd":                          "📀",
+	"camera":                       "📷",
+	"video_camera":                 "📹",
+	"movie_camera":                 "🎥",
+	"tv":                           "📺",
+	"radio":                        "📻",
+	"telephone":                    "☎️",
+	"phone":                        "☎️",
+	"battery":                      "🔋",
+	"electric_plug":                "🔌",
+	"bulb":                         "💡",
+	"flashlight":                   "🔦",
+	"candle":                       "🕯️",
+	"money_with_wings":             "💸",
+	"dollar":                       "💵",
+	"moneybag":                     "💰",
+	"credit_card":                  "💳",
+	"gem":                          "💎",
+	"wrench":                       "🔧",
+	"hammer":                       "🔨",
+	"hammer_and_wrench":            "🛠️",
+	"nut_and_bolt":                 "🔩",
+	"gear":                         "⚙️",
+	"link":                         "🔗",
+	"chains":                       "⛓️",
+	"toolbox":                      "🧰",
+	"magnet":                       "🧲",
+	"test_tube":                    "🧪",
+	"microscope":                   "🔬",
+	"telescope":                    "🔭",
+	"satellite":                    "📡",
+	"syringe":                      "💉",
+	"pill":                         "💊",
+	"door":                         "🚪",
+	"bed":                          "🛏️",
+	"key":                          "🔑",
+	"old_key":                      "🗝️",
+	"lock":                         "🔒",
+	"unlock":                       "🔓",
+	"shield":                       "🛡️",
+	"dagger":                       "🗡️",
+	"crossed_swords":               "⚔️",
+	"bomb":                         "💣",
+	"hourglass":                    "⌛",
+	"hourglass_flowing_sand":       "⏳",
+	"stopwatch":                    "⏱️",
+	"alarm_clock":                  "⏰",
+	"calendar":                     "📆",
+	"date":                         "📅",
+	"package":                      "📦",
+	"mailbox":                      "📫",
+	"email":                        "📧",
+	"envelope":                     "✉️",
+	"inbox_tray":                   "📥",
+	"outbox_tray":                  "📤",
+	"memo":                         "📝",
+	"pencil":                       "📝",
+	"pencil2":                      "✏️",
+	"pen":                          "🖊️",
+	"paintbrush":                   "🖌️",
+	"crayon":                       "🖍️",
+	"briefcase":                    "💼",
+	"file_folder":                  "📁",
+	"open_file_folder":             "📂",
+	"page_facing_up":               "📄",
+	"page_with_curl":               "📃",
+	"bookmark_tabs":                "📑",
+	"clipboard":                    "📋",
+	"pushpin":                      "📌",
+	"round_pushpin":                "📍",
+	"paperclip":                    "📎",
+	"straight_ruler":               "📏",
+	"triangular_ruler":             "📐",
+	"scissors":                     "✂️",
+	"wastebasket":                  "🗑️",
+	"chart_with_upwards_trend":     "📈",
+	"chart_with_downwards_trend":   "📉",
+	"bar_chart":                    "📊",
+	"book":                         "📖",
+	"open_book":                    "📖",
+	"books":                        "📚",
+	"notebook":                     "📓",
+	"ledger":                       "📒",
+	"bookmark":                     "🔖",
+	"label":                        "🏷️",
+	"newspaper":                    "📰",
+	"mag":                          "🔍",
+	"mag_right":                    "🔎",
+	"bell":                         "🔔",
+	"no_bell":                      "🔕",
+	"loudspeaker":                  "📢",
+	"mega":                         "📣",
+	"speaker":                      "🔈",
+	"mute":                         "🔇",
+	"sound":                        "🔉",
+	"loud_sound":                   "🔊",
+	"crown":                        "👑",
+	"eyeglasses":                   "👓",
+	"necktie":                      "👔",
+	"shirt":                        "👕",
+	"jeans":                        "👖",
+	"dress":                        "👗",
+	"shoe":                         "👞",
+	"high_heel":                    "👠",
+	"tophat":                       "🎩",
+	"mortar_board":                 "🎓",
+	"lipstick":                     "💄",
+	"ring":                         "💍",
+	"handbag":                      "👜",
+	"purse":                        "👛",
+	"school_satchel":               "🎒",
+	"umbrella":                     "☂️",
+	"checkered_flag":               "🏁",
+	"triangular_flag_on_post":      "🚩",
+	"white_flag":                   "🏳️",
+	"rainbow_flag":                 "🏳️‍🌈",
+	"pirate_flag":                  "🏴‍☠️",
+	"white_check_mark":             "✅",
+	"heavy_check_mark":             "✔️",
+	"ballot_box_with_check":        "☑️",
+	"x":                            "❌",
+	"negative_squared_cross_mark":  "❎",
+	"heavy_plus_sign":              "➕",
+	"heavy_minus_sign":             "➖",
+	"heavy_multiplication_x":       "✖️",
+	"heavy_division_sign":          "➗",
+	"question":                     "❓",
+	"grey_question":                "❔",
+	"exclamation":                  "❗",
+	"heavy_exclamation_mark":       "❗",
+	"grey_exclamation":             "❕",
+	"bangbang":                     "‼️",
+	"interrobang":                  "⁉️",
+	"warning":                      "⚠️",
+	"no_entry":                     "⛔",
+	"no_entry_sign":                "🚫",
+	"stop_sign":                    "🛑",
+	"information_source":           "ℹ️",
+	"recycle":                      "♻️",
+	"beginner":                     "🔰",
+	"trident":                      "🔱",
+	"copyright":                    "©️",
+	"registered":                   "®️",
+	"tm":                           "™️",
+	"arrow_up":                     "⬆️",
+	"arrow_down":                   "⬇️",
+	"arrow_left":                   "⬅️",
+	"arrow_right":                  "➡️",
+	"arrow_upper_right":            "↗️",
+	"arrow_lower_right":            "↘️",
+	"arrow_lower_left":             "↙️",
+	"arrow_upper_left":             "↖️",
+	"arrow_up_down":                "↕️",
+	"left_right_arrow":             "↔️",
+	"arrows_counterclockwise":      "🔄",
+	"arrows_clockwise":             "🔃",
+	"leftwards_arrow_with_hook":    "↩️",
+	"arrow_right_hook":             "↪️",
+	"arrow_forward":                "▶️",
+	"arrow_backward":               "◀️",
+	"fast_forward":                 "⏩",
+	"rewind":                       "⏪",
+	"pause_button":                 "⏸️",
+	"stop_button":                  "⏹️",
+	"record_button":                "⏺️",
+	"repeat":                       "🔁",
+	"twisted_rightwards_arrows":    "🔀",
+	"new":                          "🆕",
+	"free":                         "🆓",
+	"up":                           "🆙",
+	"cool":                         "🆒",
+	"ok":                           "🆗",
+	"sos":                          "🆘",
+	"top":                          "🔝",
+	"soon":                         "🔜",
+	"back":                         "🔙",
+	"end":                          "🔚",
+	"on":                           "🔛",
+	"red_circle":                   "🔴",
+	"orange_circle":                "🟠",
+	"yellow_circle":                "🟡",
+	"green_circle":                 "🟢",
+	"large_blue_circle":            "🔵",
+	"purple_circle":                "🟣",
+	"black_circle":                 "⚫",
+	"white_circle":                 "⚪",
+	"red_square":                   "🟥",
+	"green_square":                 "🟩",
+	"blue_square":                  "🟦",
+	"black_large_square":           "⬛",
+	"white_large_square":           "⬜",
+	"large_orange_diamond":         "🔶",
+	"large_blue_diamond":           "🔷",
+	"small_orange_diamond":         "🔸",
+	"small_blue_diamond":           "🔹",
+	"hash":                         "#️⃣",
+	"asterisk":                     "*️⃣",
+	"zero":                         "0️⃣",
+	"one":                          "1️⃣",
+	"two":                          "2️⃣",
+	"three":                        "3️⃣",
+	"four":                         "4️⃣",
+	"five":                         "5️⃣",
+	"six":                          "6️⃣",
+	"seven":                        "7️⃣",
+	"eight":                        "8️⃣",
+	"nine":                         "9️⃣",
+	"keycap_ten":                   "🔟",
+	"infinity":                     "♾️",
+	"peace_symbol":                 "☮️",
+	"yin_yang":                     "☯️",
+	"atom_symbol":                  "⚛️",
+	"radioactive":                  "☢️",
+	"biohazard":                    "☣️",
+	"mobile_phone_off":             "📴",
+	"vibration_mode":               "📳",
+	"signal_strength":              "📶",
+	"clock1":                       "🕐",
+	"clock12":                      "🕛",
+}
@@ -0,0 +1,97 @@
+package markdown
+
+import (
+	"html"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// markdownBlockTags are the elements that can wrap Markdown with a
+// markdown attribute. Elements whose content is literal, like pre and
+// code, are deliberately absent.
+var markdownBlockTags = []string{"div", "section", "aside", "article", "figure"}
+
+var (
+	htmlBlockOpenPattern = regexp.MustCompile(`^<([a-z]+)(\s[^<>]*)?>$`)
+	htmlAttrPattern      = regexp.MustCompile(`([a-zA-Z_:][-a-zA-Z0-9_:.]*)(?:\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'=<>` + "`" + `]+)))?`)
+)
+
+// htmlBlockTag reads a line like <div class="note" markdown> that opens a
+// Markdown-wrapping element, returning the tag name and the opening tag
+// to emit. Only id, class, role, lang, dir, title, data-*, and aria-*
+// attributes are kept, escaped; the markdown attribute and any others,
+// such as event handlers, are dropped.
+func htmlBlockTag(line string) (name, open string, ok bool) {
+	m := htmlBlockOpenPattern.FindStringSubmatch(strings.TrimSpace(line))
+	if m == nil || !slices.Contains(markdownBlockTags, m[1]) {
+		return "", "", false
+	}
+
+	var b strings.Builder
+	b.WriteString("<" + m[1])
+	enabled := false
+	for _, attr := range htmlAttrPattern.FindAllStringSubmatch(m[2], -1) {
+		key := strings.ToLower(attr[1])
+		value := attr[2] + attr[3] + attr[4]
+		if key == "markdown" {
+			enabled = value != "0" && value != "false"
+			continue
+		}
+		if isSafeHTMLAttr(key) {
+			b.WriteString(" " + key + `="` + html.EscapeString(html.UnescapeString(value)) + `"`)
+		}
+	}
+	b.WriteString(">")
+	return m[1], b.String(), enabled
+}
+
+func isSafeHTMLAttr(key string) bool {
+	switch key {
+	case "id", "class", "role", "lang", "dir", "title":
+		return true
+	}
+	return strings.HasPrefix(key, "data-") || strings.HasPrefix(key, "aria-")
+}
+
+// htmlBlockLines returns how many lines the Markdown-wrapping element
+// opened by lines[0] spans, through its closing tag on a line of its own,
+// or 0 when lines[0] doesn't open one or it is never closed. Nested
+// elements of the same name are balanced.
+func htmlBlockLines(lines []string) int {
+	name, _, ok := htmlBlockTag(lines[0])
+	if !ok {
+		return 0
+	}
+
+	depth := 0
+	inFence := false
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") {
+			inFence = !inFence
+		}
+		if inFence {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(trimmed, "<"+name+">") || strings.HasPrefix(trimmed, "<"+name+" "):
+			depth++
+		case trimmed == "</"+name+">":
+			depth--
+			if depth == 0 {
+				return i + 1
+			}
+		}
+	}
+	return 0
+}
+
+// renderHTMLBlock renders a <div markdown> block: the sanitized opening
+// tag, its content rendered as Markdown blocks, and the closing tag.
+func (r *renderer) renderHTMLBlock(lines []string) (string, int) {
+	n := htmlBlockLines(lines)
+	name, open, _ := htmlBlockTag(lines[0])
+	inner := r.renderBlocks(lines[1 : n-1])
+	return open + "\n" + inner + "</" + name + ">\n", n
+}
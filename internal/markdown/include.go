@@ -0,0 +1,72 @@
+package markdown
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// renderInclude renders {{< include file="examples/main.go" lang="go" >}}
+// as a code block holding the file's contents, so samples stay in sync
+// with real code. The file may also be given positionally, and lang
+// defaults to the file extension. Missing or out-of-root files produce a
+// warning and no output rather than failing the build. A site's own
+// shortcodes/include.html replaces this built-in.
+func (r *renderer) renderInclude(tag shortcodeTag) string {
+	file := tag.params["file"]
+	if file == "" && len(tag.positional) > 0 {
+		file = tag.positional[0]
+	}
+	if file == "" {
+		r.warnShortcode("include shortcode is missing a file")
+		return ""
+	}
+
+	root, err := filepath.Abs(r.options.RootDir)
+	if err == nil {
+		root, err = filepath.EvalSymlinks(root)
+	}
+	if err != nil {
+		r.warnShortcode("include %q: %v", file, err)
+		return ""
+	}
+	path := filepath.Join(root, filepath.FromSlash(file))
+	if !insideDir(root, path) {
+		r.warnShortcode("include %q is outside the site root", file)
+		return ""
+	}
+
+	// Resolve symlinks so a link inside the root can't reach files
+	// outside it.
+	path, err = filepath.EvalSymlinks(path)
+	if os.IsNotExist(err) {
+		r.warnShortcode("include %q: file not found", file)
+		return ""
+	}
+	if err != nil {
+		r.warnShortcode("include %q: %v", file, err)
+		return ""
+	}
+	if !insideDir(root, path) {
+		r.warnShortcode("include %q is outside the site root", file)
+		return ""
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		r.warnShortcode("include %q: %v", file, err)
+		return ""
+	}
+
+	lang, ok := tag.params["lang"]
+	if !ok {
+		lang = strings.TrimPrefix(filepath.Ext(path), ".")
+	}
+	return r.codeBlock(lang, strings.TrimRight(string(data), "\n"))
+}
+
+// insideDir reports whether path is dir or lies beneath it.
+func insideDir(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
@@ -0,0 +1,55 @@
+package markdown
+
+import (
+	"html"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+var anchorPattern = regexp.MustCompile(`<a href="([^"]*)">`)
+
+// markExternalLinks adds the configured target, rel, and class to anchors
+// linking off the site. Links are external when they are http(s) or
+// protocol-relative and their host isn't one of InternalHosts; relative,
+// fragment, and mailto links are left alone.
+func (r *renderer) markExternalLinks(text string) string {
+	opts := r.options
+	if opts.ExternalLinkTarget == "" && opts.ExternalLinkRel == "" && opts.ExternalLinkClass == "" {
+		return text
+	}
+
+	return anchorPattern.ReplaceAllStringFunc(text, func(tag string) string {
+		href := anchorPattern.FindStringSubmatch(tag)[1]
+		if !r.isExternalLink(html.UnescapeString(href)) {
+			return tag
+		}
+		var b strings.Builder
+		b.WriteString(`<a href="` + href + `"`)
+		if opts.ExternalLinkTarget != "" {
+			b.WriteString(` target="` + html.EscapeString(opts.ExternalLinkTarget) + `"`)
+		}
+		if opts.ExternalLinkRel != "" {
+			b.WriteString(` rel="` + html.EscapeString(opts.ExternalLinkRel) + `"`)
+		}
+		if opts.ExternalLinkClass != "" {
+			b.WriteString(` class="` + html.EscapeString(opts.ExternalLinkClass) + `"`)
+		}
+		b.WriteString(">")
+		return b.String()
+	})
+}
+
+func (r *renderer) isExternalLink(href string) bool {
+	u, err := url.Parse(href)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	if u.Scheme != "" && u.Scheme != "http" && u.Scheme != "https" {
+		return false
+	}
+	return !slices.ContainsFunc(r.options.InternalHosts, func(host string) bool {
+		return strings.EqualFold(host, u.Hostname())
+	})
+}
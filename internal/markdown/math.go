@@ -0,0 +1,95 @@
+package markdown
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+// mathDelimiters are the opening and closing delimiters of display math
+// blocks.
+var mathDelimiters = [][2]string{{"$$", "$$"}, {`\[`, `\]`}}
+
+// Math never spans the \x00 placeholders of code spans and links, so a
+// dollar in a link's URL can't pair with one outside it.
+var (
+	displayMathPattern = regexp.MustCompile(`\$\$([^$\x00]+?)\$\$`)
+	parenMathPattern   = regexp.MustCompile(`\\\(([^\x00]+?)\\\)`)
+	// dollarMathPattern requires non-space characters just inside both
+	// dollars, so prices like "$5 and $10" stay text.
+	dollarMathPattern = regexp.MustCompile(`\$([^\s$\x00](?:[^$\x00]*[^\s$\x00])?)\$`)
+)
+
+// mathBlockLines returns how many lines the display math block opened by
+// lines[0] spans, with its delimiters. It returns 0 when lines[0] doesn't
+// open a block or the block is never closed.
+func mathBlockLines(lines []string) (int, [2]string) {
+	first := strings.TrimSpace(lines[0])
+	for _, delims := range mathDelimiters {
+		open, closing := delims[0], delims[1]
+		if !strings.HasPrefix(first, open) {
+			continue
+		}
+		if rest := first[len(open):]; len(rest) >= len(closing) && strings.HasSuffix(rest, closing) {
+			return 1, delims
+		}
+		for i := 1; i < len(lines); i++ {
+			if strings.HasSuffix(strings.TrimSpace(lines[i]), closing) {
+				return i + 1, delims
+			}
+		}
+	}
+	return 0, [2]string{}
+}
+
+// renderMathBlock renders a $$...$$ or \[...\] block as a display math
+// div. The LaTeX is only HTML-escaped, and written between \[ and \] for
+// a client-side renderer such as KaTeX or MathJax.
+func (r *renderer) renderMathBlock(lines []string) (string, int) {
+	n, delims := mathBlockLines(lines)
+	source := strings.TrimSpace(strings.Join(lines[:n], "\n"))
+	source = strings.TrimSuffix(strings.TrimPrefix(source, delims[0]), delims[1])
+	return `<div class="math display">\[` + html.EscapeString(strings.TrimSpace(source)) + `\]</div>` + "\n", n
+}
+
+// extractMath replaces the math spans in escaped inline text with
+// placeholders, appending their HTML to spans so later inline passes
+// leave the LaTeX alone. $$...$$ is display math; \(...\) and $...$ are
+// inline, normalized to \(...\) delimiters.
+func extractMath(text string, spans *[]string) string {
+	add := func(class, open, source, closing string) string {
+		*spans = append(*spans, `<span class="math `+class+`">`+open+source+closing+`</span>`)
+		return codePlaceholder(len(*spans) - 1)
+	}
+
+	text = displayMathPattern.ReplaceAllStringFunc(text, func(match string) string {
+		return add("display", `\[`, strings.TrimSpace(match[2:len(match)-2]), `\]`)
+	})
+	text = parenMathPattern.ReplaceAllStringFunc(text, func(match string) string {
+		return add("inline", `\(`, match[2:len(match)-2], `\)`)
+	})
+
+	matches := dollarMathPattern.FindAllStringSubmatchIndex(text, -1)
+	if len(matches) == 0 {
+		return text
+	}
+	var out strings.Builder
+	last := 0
+	for _, m := range matches {
+		start, end := m[0], m[1]
+		// "\$" is a literal dollar, and a closing dollar followed by a
+		// digit is more likely a price
+		if (start > 0 && text[start-1] == '\\') || (end < len(text) && isDigit(text[end])) {
+			continue
+		}
+		out.WriteString(text[last:start])
+		out.WriteString(add("inline", `\(`, text[m[2]:m[3]], `\)`))
+		last = end
+	}
+	out.WriteString(text[last:])
+	return out.String()
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
@@ -0,0 +1,49 @@
+package markdown
+
+import (
+	"fmt"
+	"html"
+	"strings"
+	"time"
+)
+
+// renderParam renders {{< param author >}} or {{< param "meta.reviewer" >}}
+// as the page's front matter value, HTML-escaped. Missing keys render
+// nothing and warn.
+func (r *renderer) renderParam(tag shortcodeTag) string {
+	key := tag.params["key"]
+	if key == "" && len(tag.positional) > 0 {
+		key = tag.positional[0]
+	}
+	if key == "" {
+		r.warnShortcode("param shortcode is missing a key")
+		return ""
+	}
+
+	value, ok := r.options.Page.Param(key)
+	if !ok {
+		r.warnShortcode("param %q is not set", key)
+		return ""
+	}
+	return html.EscapeString(formatParam(value))
+}
+
+// formatParam renders a front matter value as text: dates as YYYY-MM-DD
+// and lists joined with commas.
+func formatParam(value any) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case time.Time:
+		return v.Format("2006-01-02")
+	case []string:
+		return strings.Join(v, ", ")
+	case []any:
+		parts := make([]string, len(v))
+		for i, item := range v {
+			parts[i] = formatParam(item)
+		}
+		return strings.Join(parts, ", ")
+	}
+	return fmt.Sprint(value)
+}
@@ -0,0 +1,56 @@
+package markdown
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/shanepadgett/canopy/internal/core"
+)
+
+// renderRef resolves {{< ref "blog/hello.md" >}} and {{< relref ... >}}
+// to the target page's URL: absolute with the site's baseURL for ref,
+// root-relative for relref. Paths are content source paths, tried
+// relative to the current page's directory first. A "#fragment" suffix is
+// kept. Unknown targets are build errors, so links can't silently break.
+func (r *renderer) renderRef(tag shortcodeTag) string {
+	target := tag.params["path"]
+	if target == "" && len(tag.positional) > 0 {
+		target = tag.positional[0]
+	}
+	if target == "" {
+		r.failShortcode("%s shortcode is missing a path", tag.name)
+		return ""
+	}
+
+	sourcePath, fragment, _ := strings.Cut(target, "#")
+	page := r.lookupRef(sourcePath)
+	if page == nil {
+		r.failShortcode("%s %q: page not found", tag.name, target)
+		return ""
+	}
+
+	url := page.URL
+	if tag.name == "ref" {
+		url = strings.TrimRight(r.options.Site.Config.BaseURL, "/") + url
+	}
+	if fragment != "" {
+		url += "#" + fragment
+	}
+	return url
+}
+
+func (r *renderer) lookupRef(sourcePath string) *core.Page {
+	site := r.options.Site
+	if sourcePath == "" {
+		return r.options.Page
+	}
+
+	if current := r.options.Page; current != nil && !strings.HasPrefix(sourcePath, "/") {
+		relative := path.Join(path.Dir(filepath.ToSlash(current.SourcePath)), sourcePath)
+		if page := site.PageByPath(relative); page != nil {
+			return page
+		}
+	}
+	return site.PageByPath(sourcePath)
+}
@@ -4,7 +4,10 @@ package markdown
 import (
 	"html"
 	"regexp"
+	"slices"
+	"strconv"
 	"strings"
+	"unicode"
 
 	"github.com/shanepadgett/canopy/internal/core"
 )
@@ -12,32 +15,163 @@ import (
 // RenderResult contains the rendered HTML and extracted metadata.
 type RenderResult struct {
 	HTML    string
-	TOC     []core.TOCEntry
-	Summary string
+	TOC     []core.TOCEntry // headings in document order
+	Summary string          // plain text of the first paragraph, max 200 chars
+
+	// WordCount counts the words in the rendered text; see countWords
+	WordCount int
+
+	// Shortcodes names the shortcodes used, including nested ones,
+	// sorted, so pages can load embed scripts only where needed.
+	Shortcodes []string
+
+	// Errors are problems that should fail the build, such as a ref
+	// shortcode whose target page does not exist.
+	Errors []error
 }
 
-// ShortcodeRenderer renders shortcode templates.
+// ShortcodeRenderer renders shortcode templates. The template engine
+// implements it; callers may supply their own.
+//
+// params holds the tag's key="value" pairs and positional its bare quoted
+// values in order; a tag may mix both. inner is the content between
+// opening and closing tags; innerIsHTML reports whether it was already
+// rendered from Markdown ({{< >}}) or passed through raw ({{% %}}). page
+// and site are RenderOptions.Page and RenderOptions.Site and may be nil.
 type ShortcodeRenderer interface {
-	RenderShortcode(name string, params map[string]string, inner string, innerIsHTML bool, page *core.Page) (string, error)
+	RenderShortcode(name string, params map[string]string, positional []string, inner string, innerIsHTML bool, page *core.Page, site *core.Site) (string, error)
 }
 
-// RenderOptions configures Markdown rendering.
+// RenderOptions configures Markdown rendering. The zero value renders
+// plain Markdown with shortcodes left as text.
 type RenderOptions struct {
-	Page              *core.Page
+	// Page is the page being rendered. It is passed to shortcodes and
+	// used to prefix warnings with the source path.
+	Page *core.Page
+
+	// Site is the site being built, for features that look beyond the
+	// current page. It is passed to shortcodes so they can query other
+	// pages.
+	Site *core.Site
+
+	// ShortcodeRenderer enables shortcode processing when set.
 	ShortcodeRenderer ShortcodeRenderer
-	SkipPageTOC       bool
+
+	// SkipPageTOC stops RenderWithOptions from filling Page.TOC up front.
+	// With a ShortcodeRenderer and Page set, the page TOC is collected
+	// before rendering so a toc shortcode can see headings that follow
+	// it. Inner shortcode content is rendered with SkipPageTOC so nested
+	// renders do not overwrite the page's TOC.
+	SkipPageTOC bool
+
+	// ImageBase is prepended to relative image sources, so page bundles
+	// can reference their resources as ![alt](cover.jpg).
+	ImageBase string
+
+	// Warn receives non-fatal rendering problems. Warnings are dropped
+	// when nil; the markdown package never writes to stderr itself.
+	Warn func(message string)
+
+	// DefaultCodeLang is applied to fenced code blocks without a language
+	// hint. A fence explicitly labeled "text" or "none" never gets a language
+	// class.
+	DefaultCodeLang string
+
+	// DiagramLanguages are fence languages, such as "mermaid", whose
+	// source is emitted as <pre class="mermaid"> instead of highlighted
+	// code, for a client-side script to render.
+	DiagramLanguages []string
+
+	// EnableMath renders $...$ and \(...\) as inline math and $$...$$
+	// and \[...\] as display math, wrapped in math inline and math
+	// display elements for KaTeX or MathJax. An opening "$" must be
+	// followed by a non-space and a closing one preceded by one, so prices
+	// like "$5 and $10" stay text.
+	EnableMath bool
+
+	// MarkdownInHTML renders the content of block elements opened with a
+	// markdown attribute, like <div class="note" markdown> on a line of its
+	// own and closed by </div> on another, as Markdown. Only div, section,
+	// aside, article, and figure opt in, and only id, class, role, lang,
+	// dir, title, data-*, and aria-* attributes are kept. Other HTML stays
+	// escaped text.
+	MarkdownInHTML bool
+
+	// EnableEmoji replaces :name: tokens such as :smile: with emoji
+	// outside code. Unknown names are left as written.
+	EnableEmoji bool
+
+	// Typographer converts straight quotes to curly quotes, "--" and
+	// "---" to en and em dashes, and "..." to an ellipsis, outside code
+	// and HTML attributes.
+	Typographer bool
+
+	// HeadingIDPrefix is prepended to every heading ID, and so to TOC
+	// IDs, to keep fragments rendered onto the same page from colliding.
+	HeadingIDPrefix string
+
+	// HeadingOffset shifts every heading down that many levels, clamped at
+	// h6, so a body "#" renders as <h2> under a layout that already shows
+	// the title in an <h1>. TOC levels use the shifted values.
+	HeadingOffset int
+
+	// HeadingAnchors appends a "#" link to each heading's own ID inside
+	// the heading, styled through the heading-anchor class.
+	HeadingAnchors bool
+
+	// AutoTOC inserts a nested table of contents at the top of the body,
+	// or in place of the first [TOC] paragraph when there is one.
+	AutoTOC bool
+
+	// TOCMinLevel and TOCMaxLevel bound the heading levels collected into
+	// the TOC, such as 2 and 3 to leave out the title and minor headings.
+	// Zero means 1 and 6, so every heading is listed by default. Headings
+	// outside the range still get IDs.
+	TOCMinLevel int
+	TOCMaxLevel int
+
+	// LazyImages adds loading="lazy" to Markdown images, and the width
+	// and height of those naming a page resource, to avoid layout shift.
+	LazyImages bool
+
+	// ExternalLinkTarget, ExternalLinkRel, and ExternalLinkClass are
+	// added to links whose host isn't in InternalHosts, such as "_blank"
+	// and "noopener noreferrer". Empty values add nothing.
+	ExternalLinkTarget string
+	ExternalLinkRel    string
+	ExternalLinkClass  string
+
+	// InternalHosts are the hosts, like the one in the site's base URL,
+	// whose absolute links aren't external.
+	InternalHosts []string
+
+	// RootDir is the site root. It enables the built-in include shortcode,
+	// which reads files relative to it and refuses paths outside it.
+	RootDir string
+
+	// lineOffset is the number of source lines before the Markdown being
+	// rendered. It defaults to the page's front matter and is advanced for
+	// shortcode inner content, so warnings report file line numbers.
+	lineOffset int
 }
 
 // Render converts Markdown to HTML and extracts TOC and summary.
+// It is RenderWithOptions with zero options.
 func Render(markdown string) RenderResult {
 	return RenderWithOptions(markdown, RenderOptions{})
 }
 
 // RenderWithOptions converts Markdown to HTML using custom options.
+// When opts.ShortcodeRenderer and opts.Page are set and SkipPageTOC is
+// false, opts.Page.TOC is populated before rendering begins.
 func RenderWithOptions(markdown string, opts RenderOptions) RenderResult {
+	if opts.lineOffset == 0 && opts.Page != nil && opts.Page.ContentLine > 1 {
+		opts.lineOffset = opts.Page.ContentLine - 1
+	}
+
 	if opts.ShortcodeRenderer != nil && opts.Page != nil && !opts.SkipPageTOC {
 		stripped := stripShortcodes(markdown)
-		opts.Page.TOC = collectTOC(stripped)
+		opts.Page.TOC = collectTOC(stripped, opts)
 	}
 
 	r := &renderer{
@@ -54,6 +188,9 @@ type renderer struct {
 	options          RenderOptions
 	shortcodes       map[string]shortcodeReplacement
 	shortcodeCounter int
+	quoteDepth       int // blockquote nesting while rendering blocks
+	usedShortcodes   []string
+	errors           []error
 }
 
 func (r *renderer) render() RenderResult {
@@ -61,7 +198,29 @@ func (r *renderer) render() RenderResult {
 		r.input = r.processShortcodes(r.input)
 	}
 
-	lines := strings.Split(r.input, "\n")
+	html := r.renderBlocks(strings.Split(r.input, "\n"))
+	html = r.replaceShortcodes(html)
+
+	// The TOC repeats headings, so words are counted before it goes in
+	words := countWords(extractPlainText(html))
+	if r.options.AutoTOC {
+		html = insertTOC(html, r.toc)
+	}
+
+	return RenderResult{
+		HTML:       html,
+		TOC:        r.toc,
+		Summary:    r.summary,
+		WordCount:  words,
+		Shortcodes: r.usedShortcodes,
+		Errors:     r.errors,
+	}
+}
+
+// renderBlocks renders a sequence of block-level lines. Blockquotes call
+// it recursively on their stripped content; only top-level headings and
+// paragraphs feed the TOC and summary.
+func (r *renderer) renderBlocks(lines []string) string {
 	var out strings.Builder
 	var i int
 
@@ -83,13 +242,27 @@ func (r *renderer) render() RenderResult {
 			continue
 		}
 
+		// Display math block
+		if r.options.EnableMath {
+			if n, _ := mathBlockLines(lines[i:]); n > 0 {
+				html, consumed := r.renderMathBlock(lines[i:])
+				out.WriteString(html)
+				i += consumed
+				continue
+			}
+		}
+
+		// Block HTML wrapping Markdown
+		if r.options.MarkdownInHTML && htmlBlockLines(lines[i:]) > 0 {
+			html, consumed := r.renderHTMLBlock(lines[i:])
+			out.WriteString(html)
+			i += consumed
+			continue
+		}
+
 		// Heading
 		if strings.HasPrefix(line, "#") {
-			html, toc := r.renderHeading(line)
-			out.WriteString(html)
-			if toc != nil {
-				r.toc = append(r.toc, *toc)
-			}
+			out.WriteString(r.renderHeading(line))
 			i++
 			continue
 		}
@@ -131,12 +304,30 @@ func (r *renderer) render() RenderResult {
 			continue
 		}
 
+		// Setext heading: a text line underlined by === or ---. Checked
+		// before paragraphs so the underline isn't read as a rule.
+		if i+1 < len(lines) {
+			if level := setextLevel(lines[i+1]); level > 0 {
+				out.WriteString(r.heading(level, strings.TrimSpace(line)))
+				i += 2
+				continue
+			}
+		}
+
+		// Definition list: a term line followed by ": definition" lines
+		if startsDefinitionList(lines[i:]) {
+			html, consumed := r.renderDefinitionList(lines[i:])
+			out.WriteString(html)
+			i += consumed
+			continue
+		}
+
 		// Paragraph
 		html, consumed := r.renderParagraph(lines[i:])
 		out.WriteString(html)
 
 		// Extract first paragraph as summary
-		if r.summary == "" {
+		if r.summary == "" && r.quoteDepth == 0 {
 			summaryHTML := r.replaceShortcodes(html)
 			r.summary = extractPlainText(summaryHTML)
 			if len(r.summary) > 200 {
@@ -147,17 +338,16 @@ func (r *renderer) render() RenderResult {
 		i += consumed
 	}
 
-	html := out.String()
-	html = r.replaceShortcodes(html)
+	return out.String()
+}
 
-	return RenderResult{
-		HTML:    html,
-		TOC:     r.toc,
-		Summary: r.summary,
+func (r *renderer) warn(message string) {
+	if r.options.Warn != nil {
+		r.options.Warn(message)
 	}
 }
 
-func (r *renderer) renderHeading(line string) (string, *core.TOCEntry) {
+func (r *renderer) renderHeading(line string) string {
 	level := 0
 	for _, c := range line {
 		if c == '#' {
@@ -171,19 +361,45 @@ func (r *renderer) renderHeading(line string) (string, *core.TOCEntry) {
 		level = 6
 	}
 
-	text := strings.TrimSpace(line[level:])
-	id := slugify(text)
+	return r.heading(level, strings.TrimSpace(line[level:]))
+}
+
+// heading renders an ATX or setext heading and records its TOC entry.
+func (r *renderer) heading(level int, text string) string {
+	level = max(1, min(level+r.options.HeadingOffset, 6))
+	id := r.options.HeadingIDPrefix + core.Slugify(text)
 
 	// Apply inline formatting to heading text
-	formattedText := renderInline(text)
+	formattedText := r.renderInline(text)
+	if r.options.HeadingAnchors {
+		formattedText += ` <a class="heading-anchor" href="#` + id + `">#</a>`
+	}
 
-	toc := &core.TOCEntry{
-		Level: level,
-		ID:    id,
-		Title: text,
+	if r.quoteDepth == 0 && r.inTOCRange(level) {
+		r.toc = append(r.toc, core.TOCEntry{
+			Level: level,
+			ID:    id,
+			Title: text,
+		})
 	}
 
-	return "<h" + itoa(level) + " id=\"" + id + "\">" + formattedText + "</h" + itoa(level) + ">\n", toc
+	return "<h" + itoa(level) + " id=\"" + id + "\">" + formattedText + "</h" + itoa(level) + ">\n"
+}
+
+// setextLevel returns 1 for a === underline, 2 for a --- underline, and 0
+// for anything else. Spaced rules like "- - -" are not underlines.
+func setextLevel(line string) int {
+	trimmed := strings.TrimSpace(line)
+	if trimmed == "" || len(line)-len(strings.TrimLeft(line, " ")) > 3 {
+		return 0
+	}
+	switch {
+	case strings.Trim(trimmed, "=") == "":
+		return 1
+	case strings.Trim(trimmed, "-") == "":
+		return 2
+	}
+	return 0
 }
 
 func (r *renderer) renderCodeBlock(lines []string) (string, int) {
@@ -210,38 +426,125 @@ func (r *renderer) renderCodeBlock(lines []string) (string, int) {
 		code.WriteString(lines[i])
 	}
 
-	escapedCode := html.EscapeString(code.String())
+	return r.codeBlock(lang, code.String()), consumed
+}
+
+// codeBlock renders code in a pre block with its language class.
+// Diagram languages keep their source in a pre classed by the language.
+func (r *renderer) codeBlock(lang, code string) string {
+	escapedCode := html.EscapeString(code)
+
+	if lang != "" && slices.Contains(r.options.DiagramLanguages, lang) {
+		return "<pre class=\"" + html.EscapeString(lang) + "\">" + escapedCode + "</pre>\n"
+	}
+
+	switch lang {
+	case "":
+		lang = r.options.DefaultCodeLang
+	case "none", "text":
+		lang = ""
+	}
 
 	if lang != "" {
-		return "<pre><code class=\"language-" + lang + "\">" + escapedCode + "</code></pre>\n", consumed
+		return "<pre><code class=\"language-" + html.EscapeString(lang) + "\">" + escapedCode + "</code></pre>\n"
 	}
-	return "<pre><code>" + escapedCode + "</code></pre>\n", consumed
+	return "<pre><code>" + escapedCode + "</code></pre>\n"
 }
 
+// renderBlockquote strips one level of ">" and renders the rest as blocks,
+// so quotes can hold paragraphs, lists, code, and nested quotes. A ">"
+// line with no text separates paragraphs inside the quote. A quote opening
+// with a GitHub-style [!NOTE] marker renders as an admonition. A final line
+// starting with "-- " or "— " is an attribution: the quote is wrapped in
+// a figure with the line as its figcaption.
 func (r *renderer) renderBlockquote(lines []string) (string, int) {
-	var content strings.Builder
+	var inner []string
 	consumed := 0
 
+	// A blank line ends the quote, so consecutive quotes stay separate
+	// and whatever follows is left for the main loop.
 	for _, line := range lines {
-		trimmed := strings.TrimSpace(line)
-		if !strings.HasPrefix(trimmed, ">") && trimmed != "" {
+		trimmed := strings.TrimLeft(line, " ")
+		if !strings.HasPrefix(trimmed, ">") {
 			break
 		}
 		consumed++
 
-		if trimmed == "" {
-			continue
-		}
-
 		// Strip the > prefix
 		text := strings.TrimPrefix(trimmed, ">")
 		text = strings.TrimPrefix(text, " ")
-		content.WriteString(text)
-		content.WriteString("\n")
+		inner = append(inner, text)
+	}
+
+	if kind, title, ok := admonitionMarker(inner); ok {
+		r.quoteDepth++
+		html := r.renderBlocks(inner[1:])
+		r.quoteDepth--
+		return "<div class=\"admonition admonition-" + kind + "\">\n<p class=\"admonition-title\">" + r.renderInline(title) + "</p>\n" + html + "</div>\n", consumed
+	}
+
+	attribution, inner := splitAttribution(inner)
+
+	r.quoteDepth++
+	html := r.renderBlocks(inner)
+	r.quoteDepth--
+
+	quote := "<blockquote>" + strings.TrimSuffix(html, "\n") + "</blockquote>"
+	if attribution == "" {
+		return quote + "\n", consumed
+	}
+	return "<figure class=\"quote\">" + quote + "\n<figcaption>— " + r.renderInline(attribution) + "</figcaption></figure>\n", consumed
+}
+
+// admonitionTypes are the [!TYPE] markers that turn a quote into an
+// admonition, as GitHub supports them.
+var admonitionTypes = []string{"note", "tip", "important", "warning", "caution"}
+
+// admonitionMarker reads a "[!NOTE]" marker on the first quote line,
+// returning the lowercased type and the title: the text after the marker,
+// or the type itself, as in "Note".
+func admonitionMarker(lines []string) (kind, title string, ok bool) {
+	if len(lines) == 0 {
+		return "", "", false
+	}
+	line := strings.TrimSpace(lines[0])
+	if !strings.HasPrefix(line, "[!") {
+		return "", "", false
+	}
+	end := strings.IndexByte(line, ']')
+	if end == -1 {
+		return "", "", false
+	}
+	kind = strings.ToLower(line[2:end])
+	if !slices.Contains(admonitionTypes, kind) {
+		return "", "", false
+	}
+	title = strings.TrimSpace(line[end+1:])
+	if title == "" {
+		title = strings.ToUpper(kind[:1]) + kind[1:]
+	}
+	return kind, title, true
+}
+
+// splitAttribution removes a trailing "-- Author" or "— Author" line from
+// quote lines, returning the author text and the remaining lines. Quotes
+// with nothing before the line have no attribution.
+func splitAttribution(lines []string) (string, []string) {
+	last := len(lines) - 1
+	for last >= 0 && strings.TrimSpace(lines[last]) == "" {
+		last--
+	}
+	if last <= 0 {
+		return "", lines
 	}
 
-	inner := strings.TrimSpace(content.String())
-	return "<blockquote><p>" + renderInline(inner) + "</p></blockquote>\n", consumed
+	line := strings.TrimSpace(lines[last])
+	for _, dash := range []string{"-- ", "— "} {
+		if author, ok := strings.CutPrefix(line, dash); ok && strings.TrimSpace(author) != "" {
+			return strings.TrimSpace(author), lines[:last]
+		}
+	}
+	return "", lines
 }
 
 func (r *renderer) renderUnorderedList(lines []string) (string, int) {
@@ -250,7 +553,8 @@ func (r *renderer) renderUnorderedList(lines []string) (string, int) {
 
 	consumed := 0
 	for _, line := range lines {
-		if !isUnorderedListItem(line) {
+		// "* * *" and "- - -" look like items but are rules
+		if !isUnorderedListItem(line) || isHorizontalRule(line) {
 			break
 		}
 		consumed++
@@ -262,7 +566,7 @@ func (r *renderer) renderUnorderedList(lines []string) (string, int) {
 		text = strings.TrimPrefix(text, "+")
 		text = strings.TrimSpace(text)
 
-		out.WriteString("<li>" + renderInline(text) + "</li>\n")
+		out.WriteString("<li>" + r.renderInline(text) + "</li>\n")
 	}
 
 	out.WriteString("</ul>\n")
@@ -286,7 +590,7 @@ func (r *renderer) renderOrderedList(lines []string) (string, int) {
 			text = strings.TrimSpace(text[idx+1:])
 		}
 
-		out.WriteString("<li>" + renderInline(text) + "</li>\n")
+		out.WriteString("<li>" + r.renderInline(text) + "</li>\n")
 	}
 
 	out.WriteString("</ol>\n")
@@ -297,13 +601,18 @@ func (r *renderer) renderParagraph(lines []string) (string, int) {
 	var content strings.Builder
 	consumed := 0
 
-	for _, line := range lines {
+	for i, line := range lines {
 		trimmed := strings.TrimSpace(line)
 		if trimmed == "" {
 			consumed++ // consume the blank line
 			break
 		}
 
+		// Stop before a definition list term
+		if i > 0 && startsDefinitionList(lines[i:]) {
+			break
+		}
+
 		// Stop at block-level elements
 		if strings.HasPrefix(line, "#") ||
 			strings.HasPrefix(line, "```") ||
@@ -313,6 +622,14 @@ func (r *renderer) renderParagraph(lines []string) (string, int) {
 			isHorizontalRule(line) {
 			break
 		}
+		if r.options.EnableMath {
+			if n, _ := mathBlockLines(lines[i:]); n > 0 {
+				break
+			}
+		}
+		if r.options.MarkdownInHTML && htmlBlockLines(lines[i:]) > 0 {
+			break
+		}
 
 		consumed++
 		if content.Len() > 0 {
@@ -326,19 +643,98 @@ func (r *renderer) renderParagraph(lines []string) (string, int) {
 		return "", consumed
 	}
 
-	return "<p>" + renderInline(text) + "</p>\n", consumed
+	return "<p>" + r.renderInline(text) + "</p>\n", consumed
 }
 
-// renderInline handles inline formatting: bold, italic, code, links.
-func renderInline(text string) string {
+// renderDefinitionList renders terms and their ": " definitions. A term
+// may have several definitions, and groups separated by a single blank
+// line stay in the same list.
+func (r *renderer) renderDefinitionList(lines []string) (string, int) {
+	var out strings.Builder
+	out.WriteString("<dl>\n")
+
+	consumed := 0
+	for consumed < len(lines) {
+		rest := lines[consumed:]
+		if strings.TrimSpace(rest[0]) == "" {
+			if len(rest) > 1 && startsDefinitionList(rest[1:]) {
+				consumed++
+				continue
+			}
+			break
+		}
+		if !startsDefinitionList(rest) {
+			break
+		}
+
+		out.WriteString("<dt>" + r.renderInline(strings.TrimSpace(rest[0])) + "</dt>\n")
+		consumed++
+
+		for consumed < len(lines) && isDefinition(lines[consumed]) {
+			text := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(lines[consumed]), ":"))
+			out.WriteString("<dd>" + r.renderInline(text) + "</dd>\n")
+			consumed++
+		}
+	}
+
+	out.WriteString("</dl>\n")
+	return out.String(), consumed
+}
+
+// renderInline handles inline formatting: bold, italic, code, images,
+// links, and autolinks.
+func (r *renderer) renderInline(text string) string {
 	// Escape HTML entities first
 	text = html.EscapeString(text)
 
-	// Inline code (must come before bold/italic to avoid conflicts)
-	text = regexp.MustCompile("`([^`]+)`").ReplaceAllString(text, "<code>$1</code>")
+	// Inline code (must come before everything else so code is left alone)
+	var codeSpans []string
+	text = codeSpanPattern.ReplaceAllStringFunc(text, func(match string) string {
+		codeSpans = append(codeSpans, "<code>"+codeSpanPattern.FindStringSubmatch(match)[1]+"</code>")
+		return codePlaceholder(len(codeSpans) - 1)
+	})
+
+	// Images, link destinations, and autolinks are held back until
+	// emphasis has run, so math and emphasis can't rewrite their URLs
+	var held []string
+	hold := func(s string) string {
+		held = append(held, s)
+		return heldPlaceholder(len(held) - 1)
+	}
+
+	// Images: ![alt](src)
+	text = imagePattern.ReplaceAllStringFunc(text, func(match string) string {
+		parts := imagePattern.FindStringSubmatch(match)
+		return hold(`<img src="` + r.resolveImage(parts[2]) + `" alt="` + parts[1] + `"` + r.imageAttrs(parts[2]) + `>`)
+	})
 
 	// Links: [text](url)
-	text = regexp.MustCompile(`\[([^\]]+)\]\(([^)]+)\)`).ReplaceAllString(text, `<a href="$2">$1</a>`)
+	text = linkPattern.ReplaceAllStringFunc(text, func(match string) string {
+		parts := linkPattern.FindStringSubmatch(match)
+		return `<a href="` + hold(parts[2]) + `">` + parts[1] + `</a>`
+	})
+
+	// Autolinks: <https://example.com>, <someone@example.com>, someone@example.com
+	text = angleURLPattern.ReplaceAllStringFunc(text, func(match string) string {
+		url := angleURLPattern.FindStringSubmatch(match)[1]
+		return hold(`<a href="` + url + `">` + url + `</a>`)
+	})
+	text = angleEmailPattern.ReplaceAllStringFunc(text, func(match string) string {
+		email := angleEmailPattern.FindStringSubmatch(match)[1]
+		return hold(`<a href="mailto:` + email + `">` + email + `</a>`)
+	})
+	text = linkBareEmails(text, hold)
+
+	// Math, held back like code so emphasis can't touch the LaTeX
+	if r.options.EnableMath {
+		text = extractMath(text, &codeSpans)
+	}
+
+	// Emoji, before emphasis so names like :heavy_check_mark: stay whole,
+	// and held so the "*" in :asterisk: isn't a delimiter
+	if r.options.EnableEmoji {
+		text = replaceEmoji(text, hold)
+	}
 
 	// Bold: **text** or __text__
 	text = regexp.MustCompile(`\*\*([^*]+)\*\*`).ReplaceAllString(text, "<strong>$1</strong>")
@@ -348,9 +744,139 @@ func renderInline(text string) string {
 	text = regexp.MustCompile(`\*([^*]+)\*`).ReplaceAllString(text, "<em>$1</em>")
 	text = regexp.MustCompile(`_([^_]+)_`).ReplaceAllString(text, "<em>$1</em>")
 
+	// Restore links, images, and emoji
+	for i, s := range held {
+		text = strings.Replace(text, heldPlaceholder(i), s, 1)
+	}
+
+	// External link attributes, once every link is in place
+	text = r.markExternalLinks(text)
+
+	// Smart punctuation, once every tag is in place so attributes can be skipped
+	if r.options.Typographer {
+		text = typographer(text)
+	}
+
+	// Restore code spans
+	for i, code := range codeSpans {
+		text = strings.Replace(text, codePlaceholder(i), code, 1)
+	}
+
 	return text
 }
 
+var (
+	codeSpanPattern   = regexp.MustCompile("`([^`]+)`")
+	linkPattern       = regexp.MustCompile(`\[([^\]]+)\]\(([^)]+)\)`)
+	angleURLPattern   = regexp.MustCompile(`&lt;([a-zA-Z][a-zA-Z0-9+.\-]{1,31}:[^\s]*?)&gt;`)
+	angleEmailPattern = regexp.MustCompile(`&lt;(` + emailExpr + `)&gt;`)
+	bareEmailPattern  = regexp.MustCompile(emailExpr)
+)
+
+const emailExpr = `[a-zA-Z0-9.!#$%'*+/=?^_{|}~\-]+@[a-zA-Z0-9](?:[a-zA-Z0-9\-]*[a-zA-Z0-9])?(?:\.[a-zA-Z0-9](?:[a-zA-Z0-9\-]*[a-zA-Z0-9])?)*\.[a-zA-Z]{2,}`
+
+// codePlaceholder marks where a code span is restored after inline passes.
+func codePlaceholder(i int) string {
+	return "\x00code" + strconv.Itoa(i) + "\x00"
+}
+
+// heldPlaceholder marks where a link, image, or emoji held back from
+// emphasis is restored.
+func heldPlaceholder(i int) string {
+	return "\x00held" + strconv.Itoa(i) + "\x00"
+}
+
+// linkBareEmails links email addresses that aren't already part of a link,
+// passing each anchor through hold.
+func linkBareEmails(text string, hold func(string) string) string {
+	matches := bareEmailPattern.FindAllStringIndex(text, -1)
+	if len(matches) == 0 {
+		return text
+	}
+
+	var out strings.Builder
+	last := 0
+	for _, m := range matches {
+		start, end := m[0], m[1]
+		prefix := text[:start]
+		inLink := strings.Count(prefix, "<a ") > strings.Count(prefix, "</a>")
+		if inLink || (start > 0 && strings.ContainsRune(`:/"=>`, rune(text[start-1]))) {
+			continue
+		}
+		email := text[start:end]
+		out.WriteString(text[last:start])
+		out.WriteString(hold(`<a href="mailto:` + email + `">` + email + `</a>`))
+		last = end
+	}
+	out.WriteString(text[last:])
+	return out.String()
+}
+
+var imagePattern = regexp.MustCompile(`!\[([^\]]*)\]\(([^)\s]+)\)`)
+
+// resolveImage prefixes relative image sources with the configured image base.
+func (r *renderer) resolveImage(src string) string {
+	if r.options.ImageBase == "" || !isRelativeURL(src) {
+		return src
+	}
+	return strings.TrimSuffix(r.options.ImageBase, "/") + "/" + strings.TrimPrefix(src, "./")
+}
+
+// imageAttrs returns the LazyImages attributes for an image source as
+// written: loading="lazy", plus width and height when it names a page
+// resource whose size can be read.
+func (r *renderer) imageAttrs(src string) string {
+	if !r.options.LazyImages {
+		return ""
+	}
+	attrs := ` loading="lazy"`
+	if r.options.Page == nil || !isRelativeURL(src) {
+		return attrs
+	}
+	res := r.options.Page.Resource(strings.TrimPrefix(html.UnescapeString(src), "./"))
+	if res == nil || res.Images == nil {
+		return attrs
+	}
+	width, height, err := res.Images.Size(res)
+	if err != nil {
+		return attrs
+	}
+	return ` width="` + strconv.Itoa(width) + `" height="` + strconv.Itoa(height) + `"` + attrs
+}
+
+// isRelativeURL reports whether a URL is relative to the current document.
+func isRelativeURL(url string) bool {
+	if url == "" || strings.HasPrefix(url, "/") || strings.HasPrefix(url, "#") {
+		return false
+	}
+	if idx := strings.Index(url, ":"); idx != -1 && !strings.ContainsAny(url[:idx], "/?#") {
+		return false // has a scheme
+	}
+	return true
+}
+
+// startsDefinitionList reports whether lines begin with a plain term line
+// followed by a ": " definition.
+func startsDefinitionList(lines []string) bool {
+	if len(lines) < 2 || !isDefinition(lines[1]) {
+		return false
+	}
+	term := lines[0]
+	return strings.TrimSpace(term) != "" &&
+		!isDefinition(term) &&
+		!strings.HasPrefix(term, "#") &&
+		!strings.HasPrefix(term, "```") &&
+		!strings.HasPrefix(strings.TrimSpace(term), ">") &&
+		!isUnorderedListItem(term) &&
+		!isOrderedListItem(term) &&
+		!isHorizontalRule(term)
+}
+
+func isDefinition(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	return strings.HasPrefix(trimmed, ": ") || trimmed == ":"
+}
+
 func isUnorderedListItem(line string) bool {
 	trimmed := strings.TrimSpace(line)
 	return strings.HasPrefix(trimmed, "- ") ||
@@ -395,24 +921,6 @@ func isHorizontalRule(line string) bool {
 	return allSame
 }
 
-func slugify(text string) string {
-	// Lowercase
-	s := strings.ToLower(text)
-
-	// Replace spaces with hyphens
-	s = strings.ReplaceAll(s, " ", "-")
-
-	// Remove non-alphanumeric except hyphens
-	var result strings.Builder
-	for _, c := range s {
-		if (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') || c == '-' {
-			result.WriteRune(c)
-		}
-	}
-
-	return result.String()
-}
-
 func extractPlainText(html string) string {
 	// Strip HTML tags
 	re := regexp.MustCompile(`<[^>]+>`)
@@ -427,45 +935,52 @@ func extractPlainText(html string) string {
 	return strings.TrimSpace(text)
 }
 
-func collectTOC(markdown string) []core.TOCEntry {
-	lines := strings.Split(markdown, "\n")
-	var toc []core.TOCEntry
-	var inCode bool
-
-	for _, line := range lines {
-		if strings.HasPrefix(line, "```") {
-			inCode = !inCode
-			continue
-		}
-		if inCode || !strings.HasPrefix(line, "#") {
-			continue
+// countWords counts the words in plain text. Each whitespace-separated
+// run with a letter or digit counts once, except that every Chinese or
+// Japanese character counts as a word of its own, since those scripts
+// don't put spaces between words.
+func countWords(text string) int {
+	count := 0
+	inWord, hasAlnum := false, false
+	endWord := func() {
+		if inWord && hasAlnum {
+			count++
 		}
+		inWord, hasAlnum = false, false
+	}
 
-		level := 0
-		for _, c := range line {
-			if c == '#' {
-				level++
-				continue
+	for _, c := range text {
+		switch {
+		case unicode.IsSpace(c):
+			endWord()
+		case unicode.In(c, unicode.Han, unicode.Hiragana, unicode.Katakana):
+			endWord()
+			count++
+		default:
+			inWord = true
+			if unicode.IsLetter(c) || unicode.IsDigit(c) {
+				hasAlnum = true
 			}
-			break
 		}
-		if level > 6 {
-			level = 6
-		}
-
-		text := strings.TrimSpace(line[level:])
-		if text == "" {
-			continue
-		}
-
-		toc = append(toc, core.TOCEntry{
-			Level: level,
-			ID:    slugify(text),
-			Title: text,
-		})
 	}
+	endWord()
+	return count
+}
 
-	return toc
+// collectTOC renders markdown without shortcodes and returns its TOC, so
+// the entries match what the full render produces, including heading ID
+// options.
+func collectTOC(markdown string, opts RenderOptions) []core.TOCEntry {
+	r := &renderer{
+		input: markdown,
+		options: RenderOptions{
+			HeadingIDPrefix: opts.HeadingIDPrefix,
+			HeadingOffset:   opts.HeadingOffset,
+			TOCMinLevel:     opts.TOCMinLevel,
+			TOCMaxLevel:     opts.TOCMaxLevel,
+		},
+	}
+	return r.render().TOC
 }
 
 func itoa(i int) string {
@@ -1,8 +1,11 @@
 package markdown
 
 import (
+	"errors"
 	"strings"
 	"testing"
+
+	"github.com/shanepadgett/canopy/internal/core"
 )
 
 func TestRenderHeadings(t *testing.T) {
@@ -30,6 +33,30 @@ func TestRenderHeadings(t *testing.T) {
 			wantHTML: `<h1 id="title">Title</h1>`,
 			wantTOC:  3,
 		},
+		{
+			name:     "setext h1",
+			input:    "Hello *World*\n===",
+			wantHTML: `<h1 id="hello-world">Hello <em>World</em></h1>`,
+			wantTOC:  1,
+		},
+		{
+			name:     "setext h2",
+			input:    "Intro\n\nFeatures\n--------\n\nBody",
+			wantHTML: "<p>Intro</p>\n<h2 id=\"features\">Features</h2>\n<p>Body</p>",
+			wantTOC:  1,
+		},
+		{
+			name:     "rule after blank line",
+			input:    "Text\n\n---",
+			wantHTML: "<p>Text</p>\n<hr>",
+			wantTOC:  0,
+		},
+		{
+			name:     "spaced rule is not an underline",
+			input:    "Text\n- - -",
+			wantHTML: "<p>Text</p>\n<hr>",
+			wantTOC:  0,
+		},
 	}
 
 	for _, tt := range tests {
@@ -79,6 +106,35 @@ func TestRenderInlineFormatting(t *testing.T) {
 	}
 }
 
+func TestRenderAutolinks(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"angle url", "See <https://example.com/a?b=1>.", `<a href="https://example.com/a?b=1">https://example.com/a?b=1</a>`},
+		{"angle email", "Mail <someone@example.com>.", `<a href="mailto:someone@example.com">someone@example.com</a>`},
+		{"bare email", "Mail someone@example.com today.", `Mail <a href="mailto:someone@example.com">someone@example.com</a> today.`},
+		{"mailto link", "[Write](mailto:someone@example.com)", `<a href="mailto:someone@example.com">Write</a>`},
+		{"mention", "Thanks @shane and a @ b.", "<p>Thanks @shane and a @ b.</p>"},
+		{"no tld", "Run as root@localhost.", "<p>Run as root@localhost.</p>"},
+		{"code span", "Use `someone@example.com` literally.", "<code>someone@example.com</code>"},
+		{"underscores in url", "<https://a.com/foo_bar_baz>", `<a href="https://a.com/foo_bar_baz">https://a.com/foo_bar_baz</a>`},
+		{"stars in url", "<https://a.com/*x*/y>", `<a href="https://a.com/*x*/y">https://a.com/*x*/y</a>`},
+		{"underscores in email", "Mail a_b_c@x.com.", `<a href="mailto:a_b_c@x.com">a_b_c@x.com</a>`},
+		{"stars in email", "Mail <a*b*c@x.com>.", `<a href="mailto:a*b*c@x.com">a*b*c@x.com</a>`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := Render(tt.input)
+			if !strings.Contains(result.HTML, tt.want) {
+				t.Errorf("HTML = %q, want to contain %q", result.HTML, tt.want)
+			}
+		})
+	}
+}
+
 func TestRenderCodeBlock(t *testing.T) {
 	input := "```go\nfunc main() {}\n```"
 	result := Render(input)
@@ -91,6 +147,423 @@ func TestRenderCodeBlock(t *testing.T) {
 	}
 }
 
+func TestRenderCodeBlockDefaultLang(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"unlabeled", "```\nls -la\n```", `<pre><code class="language-bash">`},
+		{"labeled", "```go\nfunc main() {}\n```", `<pre><code class="language-go">`},
+		{"text", "```text\nplain\n```", `<pre><code>`},
+		{"none", "```none\nplain\n```", `<pre><code>`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := RenderWithOptions(tt.input, RenderOptions{DefaultCodeLang: "bash"})
+			if !strings.Contains(result.HTML, tt.want) {
+				t.Errorf("HTML = %q, want to contain %q", result.HTML, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderDiagramLanguages(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"mermaid", "```mermaid\ngraph TD\n  A-->B & C\n```", "<pre class=\"mermaid\">graph TD\n  A--&gt;B &amp; C</pre>"},
+		{"math", "```math\nE = mc^2\n```", `<pre class="math">E = mc^2</pre>`},
+		{"other language", "```go\nx := 1\n```", `<pre><code class="language-go">x := 1</code></pre>`},
+	}
+
+	opts := RenderOptions{DiagramLanguages: []string{"mermaid", "math"}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := RenderWithOptions(tt.input, opts)
+			if !strings.Contains(result.HTML, tt.want) {
+				t.Errorf("HTML = %q, want to contain %q", result.HTML, tt.want)
+			}
+		})
+	}
+
+	if result := Render("```mermaid\ngraph TD\n```"); !strings.Contains(result.HTML, `<code class="language-mermaid">`) {
+		t.Errorf("HTML = %q, want a plain code block without DiagramLanguages", result.HTML)
+	}
+}
+
+func TestRenderMath(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"inline dollars", "Euler: $e^{i\\pi} + 1 = 0$.", `Euler: <span class="math inline">\(e^{i\pi} + 1 = 0\)</span>.`},
+		{"inline parens", `Area \(\pi r^2\) here`, `Area <span class="math inline">\(\pi r^2\)</span> here`},
+		{"emphasis untouched", "$a_1 * b_2 * c$", `<span class="math inline">\(a_1 * b_2 * c\)</span>`},
+		{"escaped", "$a < b$", `<span class="math inline">\(a &lt; b\)</span>`},
+		{"currency", "It costs $5 and $10.", "<p>It costs $5 and $10.</p>"},
+		{"price after", "From $x$5 up", "<p>From $x$5 up</p>"},
+		{"code span", "`$x$`", "<code>$x$</code>"},
+		{"display line", "$$x^2$$", `<div class="math display">\[x^2\]</div>`},
+		{"display block", "Before\n$$\n\\sum_{i=1}^n i\n$$\nAfter", "<p>Before</p>\n<div class=\"math display\">\\[\\sum_{i=1}^n i\\]</div>\n<p>After</p>"},
+		{"bracket block", "\\[\na & b \\\\\nc & d\n\\]", `<div class="math display">\[a &amp; b \\` + "\n" + `c &amp; d\]</div>`},
+		{"unclosed block", "$$\nx", "<p>$$ x</p>"},
+		{"link url", "[x](http://a.com/$foo$)", `<a href="http://a.com/$foo$">x</a>`},
+		{"link text", "[see $x$](/a/)", `<a href="/a/">see <span class="math inline">\(x\)</span></a>`},
+		{"image alt", "![alt $a$](i.png)", `<img src="i.png" alt="alt $a$">`},
+		{"autolink", "<https://a.com/$a$>", `<a href="https://a.com/$a$">https://a.com/$a$</a>`},
+		{"across link", "$5 [x](/$y) and z$", `<p>$5 <a href="/$y">x</a> and z$</p>`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := RenderWithOptions(tt.input, RenderOptions{EnableMath: true})
+			if !strings.Contains(result.HTML, tt.want) {
+				t.Errorf("HTML = %q, want to contain %q", result.HTML, tt.want)
+			}
+		})
+	}
+
+	if result := Render("$a_1$ and $b_2$"); strings.Contains(result.HTML, "math") {
+		t.Errorf("HTML = %q, want no math without EnableMath", result.HTML)
+	}
+}
+
+func TestRenderMarkdownInHTML(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name:  "wrapping div",
+			input: "<div class=\"note\" markdown>\nSome **bold** text.\n\n- One\n</div>",
+			want:  "<div class=\"note\">\n<p>Some <strong>bold</strong> text.</p>\n<ul>\n<li>One</li>\n</ul>\n</div>\n",
+		},
+		{
+			name:  "nested",
+			input: "<section markdown=\"1\">\n<div markdown>\n*inner*\n</div>\n</section>",
+			want:  "<section>\n<div>\n<p><em>inner</em></p>\n</div>\n</section>\n",
+		},
+		{
+			name:  "unsafe attributes dropped",
+			input: "<div id=\"x\" onclick=\"alert(1)\" style=\"color: red\" data-kind='a&quot;b' markdown>\nHi\n</div>",
+			want:  "<div id=\"x\" data-kind=\"a&#34;b\">\n<p>Hi</p>\n</div>\n",
+		},
+		{
+			name:  "code stays code",
+			input: "<div markdown>\n```\n</div> **not bold**\n```\n</div>",
+			want:  "<div>\n<pre><code>&lt;/div&gt; **not bold**</code></pre>\n</div>\n",
+		},
+		{
+			name:  "without markdown attribute",
+			input: "<div class=\"note\">\n**bold**\n</div>",
+			want:  "<p>&lt;div class=&#34;note&#34;&gt; <strong>bold</strong> &lt;/div&gt;</p>\n",
+		},
+		{
+			name:  "pre does not opt in",
+			input: "<pre markdown>\nx\n</pre>",
+			want:  "<p>&lt;pre markdown&gt; x &lt;/pre&gt;</p>\n",
+		},
+		{
+			name:  "unclosed",
+			input: "<div markdown>\ntext",
+			want:  "<p>&lt;div markdown&gt; text</p>\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := RenderWithOptions(tt.input, RenderOptions{MarkdownInHTML: true})
+			if result.HTML != tt.want {
+				t.Errorf("HTML = %q, want %q", result.HTML, tt.want)
+			}
+		})
+	}
+
+	if result := Render("<div markdown>\n**bold**\n</div>"); strings.Contains(result.HTML, "<div>") {
+		t.Errorf("HTML = %q, want escaped HTML without MarkdownInHTML", result.HTML)
+	}
+}
+
+func TestRenderExternalLinks(t *testing.T) {
+	opts := RenderOptions{
+		ExternalLinkTarget: "_blank",
+		ExternalLinkRel:    "noopener noreferrer",
+		ExternalLinkClass:  "external-link",
+		InternalHosts:      []string{"example.com", "docs.example.com"},
+	}
+	external := ` target="_blank" rel="noopener noreferrer" class="external-link">`
+
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"external", "[Go](https://go.dev/doc/)", `<a href="https://go.dev/doc/"` + external + `Go</a>`},
+		{"protocol relative", "[CDN](//cdn.other.net/x.js)", `<a href="//cdn.other.net/x.js"` + external + `CDN</a>`},
+		{"autolink", "<https://go.dev>", `<a href="https://go.dev"` + external + `https://go.dev</a>`},
+		{"base host", "[Home](https://example.com/about/)", `<a href="https://example.com/about/">Home</a>`},
+		{"allowed host", "[Docs](https://Docs.Example.com/)", `<a href="https://Docs.Example.com/">Docs</a>`},
+		{"relative", "[About](/about/)", `<a href="/about/">About</a>`},
+		{"fragment", "[Top](#top)", `<a href="#top">Top</a>`},
+		{"mailto", "<me@example.org>", `<a href="mailto:me@example.org">me@example.org</a>`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := RenderWithOptions(tt.input, opts)
+			if !strings.Contains(result.HTML, tt.want) {
+				t.Errorf("HTML = %q, want to contain %q", result.HTML, tt.want)
+			}
+		})
+	}
+
+	if result := Render("[Go](https://go.dev/)"); !strings.Contains(result.HTML, `<a href="https://go.dev/">Go</a>`) {
+		t.Errorf("HTML = %q, want links unchanged by default", result.HTML)
+	}
+}
+
+type stubImages struct{}
+
+func (stubImages) Resize(res *core.Resource, spec string) (string, error) {
+	return "", errors.New("not implemented")
+}
+
+func (stubImages) Size(res *core.Resource) (int, int, error) {
+	if res.Name == "broken.png" {
+		return 0, 0, errors.New("bad image")
+	}
+	return 800, 600, nil
+}
+
+func TestRenderLazyImages(t *testing.T) {
+	page := &core.Page{URL: "/blog/trip/", Resources: []*core.Resource{
+		{Name: "cover.png", Images: stubImages{}},
+		{Name: "broken.png", Images: stubImages{}},
+	}}
+	opts := RenderOptions{Page: page, ImageBase: page.URL, LazyImages: true}
+
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"bundle image", "![Cover](./cover.png)", `<img src="/blog/trip/cover.png" alt="Cover" width="800" height="600" loading="lazy">`},
+		{"unreadable", "![Broken](broken.png)", `<img src="/blog/trip/broken.png" alt="Broken" loading="lazy">`},
+		{"not a resource", "![Other](other.png)", `<img src="/blog/trip/other.png" alt="Other" loading="lazy">`},
+		{"external", "![Remote](https://example.com/x.png)", `<img src="https://example.com/x.png" alt="Remote" loading="lazy">`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := RenderWithOptions(tt.input, opts)
+			if !strings.Contains(result.HTML, tt.want) {
+				t.Errorf("HTML = %q, want to contain %q", result.HTML, tt.want)
+			}
+		})
+	}
+
+	opts.LazyImages = false
+	if result := RenderWithOptions("![Cover](cover.png)", opts); !strings.Contains(result.HTML, `<img src="/blog/trip/cover.png" alt="Cover">`) {
+		t.Errorf("HTML = %q, want a plain img without LazyImages", result.HTML)
+	}
+}
+
+func TestRenderHeadingOptions(t *testing.T) {
+	opts := RenderOptions{HeadingIDPrefix: "intro-", HeadingAnchors: true}
+	result := RenderWithOptions("## Getting Started", opts)
+
+	want := `<h2 id="intro-getting-started">Getting Started <a class="heading-anchor" href="#intro-getting-started">#</a></h2>`
+	if !strings.Contains(result.HTML, want) {
+		t.Errorf("HTML = %q, want to contain %q", result.HTML, want)
+	}
+	if len(result.TOC) != 1 || result.TOC[0].ID != "intro-getting-started" || result.TOC[0].Title != "Getting Started" {
+		t.Errorf("TOC = %+v, want the prefixed ID and plain title", result.TOC)
+	}
+}
+
+func TestRenderHeadingIDHyphens(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{name: "spaced hyphen", input: "## Hello - World", want: "hello-world"},
+		{name: "multiple spaces", input: "## Hello    World", want: "hello-world"},
+		{name: "tabs and underscores", input: "## snake_case\tand __more__", want: "snakecase-and-more"},
+		{name: "leading symbols", input: "## #1 Rule", want: "1-rule"},
+		{name: "trailing punctuation", input: "## What's new?!", want: "whats-new"},
+		{name: "punctuation between words", input: "## Q&A: Tips / Tricks", want: "qa-tips-tricks"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := Render(tt.input)
+			if len(result.TOC) != 1 || result.TOC[0].ID != tt.want {
+				t.Errorf("TOC = %+v, want ID %q", result.TOC, tt.want)
+			}
+		})
+	}
+
+	if id := Render("## ?!").TOC[0].ID; id == "" || id != Render("## ?!").TOC[0].ID {
+		t.Errorf("punctuation-only ID = %q, want a stable non-empty fallback", id)
+	}
+}
+
+func TestRenderHeadingIDTransliteration(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{name: "accented", input: "## Café Déjà-Vu", want: "cafe-deja-vu"},
+		{name: "ligature", input: "## Straße & Œuvre", want: "strasse-oeuvre"},
+		{name: "cyrillic", input: "## Привет мир", want: "privet-mir"},
+		{name: "greek", input: "## Καλημέρα", want: "kalimera"},
+		{name: "collapsed hyphens", input: "## -- Start -- End --", want: "start-end"},
+		{name: "emoji with words", input: "## 🚀 Launch", want: "launch"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := Render(tt.input)
+			if len(result.TOC) != 1 || result.TOC[0].ID != tt.want {
+				t.Errorf("TOC = %+v, want ID %q", result.TOC, tt.want)
+			}
+		})
+	}
+
+	cjk := Render("## 日本語").TOC[0].ID
+	emoji := Render("## 🎉").TOC[0].ID
+	if len(cjk) != 8 || len(emoji) != 8 || cjk == emoji {
+		t.Errorf("hash IDs = %q, %q, want distinct 8-character hashes", cjk, emoji)
+	}
+	if again := Render("## 日本語").TOC[0].ID; again != cjk {
+		t.Errorf("hash ID = %q, then %q; want stable", cjk, again)
+	}
+}
+
+func TestRenderHeadingOffset(t *testing.T) {
+	input := "# Title\n\nSetext\n------\n\n###### Smallest"
+	result := RenderWithOptions(input, RenderOptions{HeadingOffset: 1, TOCMinLevel: 2, TOCMaxLevel: 2})
+
+	for _, want := range []string{`<h2 id="title">Title</h2>`, `<h3 id="setext">Setext</h3>`, `<h6 id="smallest">Smallest</h6>`} {
+		if !strings.Contains(result.HTML, want) {
+			t.Errorf("HTML = %q, want to contain %q", result.HTML, want)
+		}
+	}
+	if strings.Contains(result.HTML, "<h1") {
+		t.Errorf("HTML = %q, want no <h1>", result.HTML)
+	}
+	if len(result.TOC) != 1 || result.TOC[0].ID != "title" || result.TOC[0].Level != 2 {
+		t.Errorf("TOC = %+v, want only the shifted h2", result.TOC)
+	}
+}
+
+func TestRenderTOCLevels(t *testing.T) {
+	input := "# Title\n\n## Setup\n\n### Install\n\n##### Footnote\n\n## Usage"
+
+	result := RenderWithOptions(input, RenderOptions{TOCMinLevel: 2, TOCMaxLevel: 3})
+	var ids []string
+	for _, entry := range result.TOC {
+		ids = append(ids, entry.ID)
+	}
+	if got := strings.Join(ids, ","); got != "setup,install,usage" {
+		t.Errorf("TOC IDs = %s, want setup,install,usage", got)
+	}
+	for _, want := range []string{`<h1 id="title">`, `<h5 id="footnote">`} {
+		if !strings.Contains(result.HTML, want) {
+			t.Errorf("HTML = %q, want excluded headings to keep their IDs (%s)", result.HTML, want)
+		}
+	}
+
+	if all := Render(input); len(all.TOC) != 5 {
+		t.Errorf("TOC len = %d, want every level by default", len(all.TOC))
+	}
+}
+
+func TestRenderAutoTOC(t *testing.T) {
+	input := "Intro\n\n## One\n\n### One A\n\n#### Deep\n\n### One B\n\n## Two\n\n#### Skipped\n\n### Two A"
+	want := `<nav class="toc">
+<ul>
+<li><a href="#one">One</a>
+<ul>
+<li><a href="#one-a">One A</a>
+<ul>
+<li><a href="#deep">Deep</a></li>
+</ul>
+</li>
+<li><a href="#one-b">One B</a></li>
+</ul>
+</li>
+<li><a href="#two">Two</a>
+<ul>
+<li><a href="#skipped">Skipped</a></li>
+<li><a href="#two-a">Two A</a></li>
+</ul>
+</li>
+</ul>
+</nav>
+<p>Intro</p>`
+
+	result := RenderWithOptions(input, RenderOptions{AutoTOC: true})
+	if !strings.HasPrefix(result.HTML, want) {
+		t.Errorf("HTML = %q, want prefix %q", result.HTML, want)
+	}
+	if plain := Render(input); result.WordCount != plain.WordCount {
+		t.Errorf("WordCount = %d, want %d without the TOC", result.WordCount, plain.WordCount)
+	}
+
+	levels := RenderWithOptions(input, RenderOptions{AutoTOC: true, TOCMinLevel: 2, TOCMaxLevel: 2})
+	if !strings.HasPrefix(levels.HTML, "<nav class=\"toc\">\n<ul>\n<li><a href=\"#one\">One</a></li>\n<li><a href=\"#two\">Two</a></li>\n</ul>\n</nav>") {
+		t.Errorf("HTML = %q, want only h2 entries", levels.HTML)
+	}
+
+	marker := RenderWithOptions("Intro\n\n[TOC]\n\n## One", RenderOptions{AutoTOC: true})
+	if !strings.HasPrefix(marker.HTML, "<p>Intro</p>\n<nav class=\"toc\">") || strings.Contains(marker.HTML, "[TOC]") {
+		t.Errorf("HTML = %q, want the TOC in place of the marker", marker.HTML)
+	}
+
+	if none := RenderWithOptions("Just text.", RenderOptions{AutoTOC: true}); strings.Contains(none.HTML, "toc") {
+		t.Errorf("HTML = %q, want no TOC without headings", none.HTML)
+	}
+}
+
+func TestNestTOC(t *testing.T) {
+	var format func(nodes []*core.TOCNode) string
+	format = func(nodes []*core.TOCNode) string {
+		var parts []string
+		for _, node := range nodes {
+			part := node.ID
+			if len(node.Children) > 0 {
+				part += "(" + format(node.Children) + ")"
+			}
+			parts = append(parts, part)
+		}
+		return strings.Join(parts, " ")
+	}
+
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"## One\n\n### One A\n\n#### Deep\n\n### One B\n\n## Two\n\n#### Skipped\n\n### Two A", "one(one-a(deep) one-b) two(skipped two-a)"},
+		{"### Deep\n\n## Shallow\n\n### Under", "deep shallow(under)"},
+		{"Text only.", ""},
+	}
+
+	for _, tt := range tests {
+		if got := format(NestTOC(Render(tt.input).TOC)); got != tt.want {
+			t.Errorf("NestTOC(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
 func TestRenderLists(t *testing.T) {
 	t.Run("unordered", func(t *testing.T) {
 		input := "- Item 1\n- Item 2\n- Item 3"
@@ -124,6 +597,227 @@ func TestRenderBlockquote(t *testing.T) {
 	if !strings.Contains(result.HTML, "<blockquote>") {
 		t.Errorf("expected blockquote, got %q", result.HTML)
 	}
+
+	t.Run("nested", func(t *testing.T) {
+		result := Render("> Outer\n>\n> > Inner\n> > still inner\n>\n> Back out")
+		want := "<blockquote><p>Outer</p>\n<blockquote><p>Inner still inner</p></blockquote>\n<p>Back out</p></blockquote>\n"
+		if result.HTML != want {
+			t.Errorf("got %q, want %q", result.HTML, want)
+		}
+	})
+
+	t.Run("paragraphs and lists", func(t *testing.T) {
+		result := Render("> First\n>\n> Second\n>\n> - One\n> - Two")
+		want := "<blockquote><p>First</p>\n<p>Second</p>\n<ul>\n<li>One</li>\n<li>Two</li>\n</ul></blockquote>\n"
+		if result.HTML != want {
+			t.Errorf("got %q, want %q", result.HTML, want)
+		}
+	})
+
+	t.Run("attribution", func(t *testing.T) {
+		for _, input := range []string{"> Stay hungry.\n>\n> -- Steve Jobs, *Stanford*", "> Stay hungry.\n> — Steve Jobs, *Stanford*"} {
+			result := Render(input)
+			want := "<figure class=\"quote\"><blockquote><p>Stay hungry.</p></blockquote>\n<figcaption>— Steve Jobs, <em>Stanford</em></figcaption></figure>\n"
+			if result.HTML != want {
+				t.Errorf("Render(%q) = %q, want %q", input, result.HTML, want)
+			}
+		}
+
+		// A dash line alone is the quote itself
+		if result := Render("> -- just a dash"); result.HTML != "<blockquote><p>-- just a dash</p></blockquote>\n" {
+			t.Errorf("got %q, want a plain quote", result.HTML)
+		}
+	})
+
+	t.Run("admonition", func(t *testing.T) {
+		result := Render("> [!WARNING]\n> Back up **first**.\n>\n> - Then upgrade")
+		want := "<div class=\"admonition admonition-warning\">\n<p class=\"admonition-title\">Warning</p>\n<p>Back up <strong>first</strong>.</p>\n<ul>\n<li>Then upgrade</li>\n</ul>\n</div>\n"
+		if result.HTML != want {
+			t.Errorf("got %q, want %q", result.HTML, want)
+		}
+
+		result = Render("> [!tip] Shortcut\n> Press `?`.")
+		want = "<div class=\"admonition admonition-tip\">\n<p class=\"admonition-title\">Shortcut</p>\n<p>Press <code>?</code>.</p>\n</div>\n"
+		if result.HTML != want {
+			t.Errorf("got %q, want %q", result.HTML, want)
+		}
+
+		// Unknown types stay quotes
+		if result := Render("> [!TODO]\n> Later"); !strings.HasPrefix(result.HTML, "<blockquote>") {
+			t.Errorf("got %q, want a plain quote", result.HTML)
+		}
+	})
+
+	t.Run("not summary", func(t *testing.T) {
+		result := Render("> Quoted\n\nIntro paragraph.")
+		if result.Summary != "Intro paragraph." {
+			t.Errorf("Summary = %q, want the first top-level paragraph", result.Summary)
+		}
+	})
+}
+
+func TestRenderEmoji(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"common names", "Nice :smile: :+1: :tada: :heart:", "<p>Nice 😄 👍 🎉 ❤️</p>"},
+		{"underscored name", "Done :white_check_mark:", "<p>Done ✅</p>"},
+		{"adjacent tokens", ":rocket::fire:", "<p>🚀🔥</p>"},
+		{"asterisk not emphasis", "a :asterisk: b :asterisk: c", "<p>a *️⃣ b *️⃣ c</p>"},
+		{"unknown token", "Keep :not-an-emoji: as is", "<p>Keep :not-an-emoji: as is</p>"},
+		{"times untouched", "At 10:30:45 sharp", "<p>At 10:30:45 sharp</p>"},
+		{"code span", "Type `:smile:` for :smile:", "<p>Type <code>:smile:</code> for 😄</p>"},
+		{"code fence", "```\n:smile:\n```", "<pre><code>:smile:</code></pre>"},
+		{"heading", "## Launch :rocket:", `<h2 id="launch-rocket">Launch 🚀</h2>`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := RenderWithOptions(tt.input, RenderOptions{EnableEmoji: true})
+			if !strings.Contains(result.HTML, tt.want) {
+				t.Errorf("HTML = %q, want to contain %q", result.HTML, tt.want)
+			}
+		})
+	}
+
+	if result := Render("Nice :smile:"); !strings.Contains(result.HTML, ":smile:") {
+		t.Errorf("expected emoji disabled by default, got %q", result.HTML)
+	}
+}
+
+func TestRenderTypographer(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"double quotes", `She said "hello" to me`, "<p>She said “hello” to me</p>"},
+		{"contractions", "It's the dog's bone, isn't it", "<p>It’s the dog’s bone, isn’t it</p>"},
+		{"single quotes", "A 'quoted' word", "<p>A ‘quoted’ word</p>"},
+		{"dashes", "pages 10--20 --- or more", "<p>pages 10–20 — or more</p>"},
+		{"ellipsis", "Wait...", "<p>Wait…</p>"},
+		{"quotes around link", `See "[the docs](/docs/a--b/)" now`, `<p>See “<a href="/docs/a--b/">the docs</a>” now</p>`},
+		{"quoted link text", `[it's "here"](/x/)`, `<p><a href="/x/">it’s “here”</a></p>`},
+		{"image alt", `!["big" -- cat](cat.jpg)`, `<p><img src="cat.jpg" alt="&#34;big&#34; -- cat"></p>`},
+		{"code span", "Use `x -- \"y\"` here...", "<p>Use <code>x -- &#34;y&#34;</code> here…</p>"},
+		{"code fence", "```\nit's -- \"raw\"...\n```", "<pre><code>it&#39;s -- &#34;raw&#34;...</code></pre>"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := RenderWithOptions(tt.input, RenderOptions{Typographer: true})
+			if !strings.Contains(result.HTML, tt.want) {
+				t.Errorf("HTML = %q, want to contain %q", result.HTML, tt.want)
+			}
+		})
+	}
+
+	if result := Render(`It's "plain" -- text...`); !strings.Contains(result.HTML, "It&#39;s &#34;plain&#34; -- text...") {
+		t.Errorf("expected typographer off by default, got %q", result.HTML)
+	}
+}
+
+func TestRenderDefinitionList(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name:  "single term",
+			input: "Canopy\n: A static site generator",
+			want:  "<dl>\n<dt>Canopy</dt>\n<dd>A static site generator</dd>\n</dl>\n",
+		},
+		{
+			name:  "multiple definitions and terms",
+			input: "Go\n: A language\n: A board game\n\n**Markdown**\n: A *lightweight* markup",
+			want:  "<dl>\n<dt>Go</dt>\n<dd>A language</dd>\n<dd>A board game</dd>\n<dt><strong>Markdown</strong></dt>\n<dd>A <em>lightweight</em> markup</dd>\n</dl>\n",
+		},
+		{
+			name:  "after a paragraph",
+			input: "Glossary below.\nTerm\n: Meaning\n\nAfter.",
+			want:  "<p>Glossary below.</p>\n<dl>\n<dt>Term</dt>\n<dd>Meaning</dd>\n</dl>\n<p>After.</p>\n",
+		},
+		{
+			name:  "colon without definition marker",
+			input: "Note: this is a paragraph\nwith two lines",
+			want:  "<p>Note: this is a paragraph with two lines</p>\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := Render(tt.input)
+			if result.HTML != tt.want {
+				t.Errorf("HTML = %q, want %q", result.HTML, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderBlockAdjacency(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name:  "blockquote then rule",
+			input: "> Quote\n---",
+			want:  "<blockquote><p>Quote</p></blockquote>\n<hr>\n",
+		},
+		{
+			name:  "blockquote blank line rule",
+			input: "> Quote\n\n---",
+			want:  "<blockquote><p>Quote</p></blockquote>\n<hr>\n",
+		},
+		{
+			name:  "consecutive blockquotes",
+			input: "> First\n\n> Second",
+			want:  "<blockquote><p>First</p></blockquote>\n<blockquote><p>Second</p></blockquote>\n",
+		},
+		{
+			name:  "list then rule",
+			input: "- One\n- Two\n---",
+			want:  "<ul>\n<li>One</li>\n<li>Two</li>\n</ul>\n<hr>\n",
+		},
+		{
+			name:  "list then starred rule",
+			input: "- One\n* * *",
+			want:  "<ul>\n<li>One</li>\n</ul>\n<hr>\n",
+		},
+		{
+			name:  "list then dashed rule",
+			input: "- One\n- - -",
+			want:  "<ul>\n<li>One</li>\n</ul>\n<hr>\n",
+		},
+		{
+			name:  "ordered list then rule",
+			input: "1. One\n***",
+			want:  "<ol>\n<li>One</li>\n</ol>\n<hr>\n",
+		},
+		{
+			name:  "heading then rule",
+			input: "## Title\n---",
+			want:  "<h2 id=\"title\">Title</h2>\n<hr>\n",
+		},
+		{
+			name:  "rule then blockquote",
+			input: "---\n> Quote",
+			want:  "<hr>\n<blockquote><p>Quote</p></blockquote>\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := Render(tt.input)
+			if result.HTML != tt.want {
+				t.Errorf("HTML = %q, want %q", result.HTML, tt.want)
+			}
+		})
+	}
 }
 
 func TestRenderSummary(t *testing.T) {
@@ -134,3 +828,34 @@ func TestRenderSummary(t *testing.T) {
 		t.Errorf("expected summary from first paragraph, got %q", result.Summary)
 	}
 }
+
+func TestCountWords(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want int
+	}{
+		{"empty", "", 0},
+		{"english", "The quick brown fox jumps.", 5},
+		{"punctuation", "well-known — don't stop", 3},
+		{"chinese", "我们喜欢静态网站", 8},
+		{"japanese", "静的サイトを作る", 8},
+		{"mixed", "Canopy は速い static site", 6},
+		{"korean", "정적 사이트 생성기", 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := countWords(tt.text); got != tt.want {
+				t.Errorf("countWords(%q) = %d, want %d", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderWordCount(t *testing.T) {
+	result := Render("# Title\n\nSome *emphasized* text.\n\n- one\n- two\n\n```\ncode here\n```\n")
+	if result.WordCount != 8 {
+		t.Errorf("WordCount = %d, want 8", result.WordCount)
+	}
+}
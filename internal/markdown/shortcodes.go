@@ -2,7 +2,7 @@ package markdown
 
 import (
 	"fmt"
-	"os"
+	"slices"
 	"strings"
 )
 
@@ -12,13 +12,14 @@ type shortcodeReplacement struct {
 }
 
 type shortcodeTag struct {
-	name      string
-	params    map[string]string
-	delimiter byte
-	isClose   bool
-	start     int
-	end       int
-	raw       string
+	name       string
+	params     map[string]string
+	positional []string
+	delimiter  byte
+	isClose    bool
+	start      int
+	end        int
+	raw        string
 }
 
 func (r *renderer) processShortcodes(input string) string {
@@ -34,12 +35,13 @@ func (r *renderer) processShortcodes(input string) string {
 	var segment strings.Builder
 	lines := strings.Split(input, "\n")
 	inCode := false
+	segmentLine := 0
 
 	flushSegment := func() {
 		if segment.Len() == 0 {
 			return
 		}
-		out.WriteString(r.processShortcodesSegment(segment.String()))
+		out.WriteString(r.processShortcodesSegment(segment.String(), r.options.lineOffset+segmentLine))
 		segment.Reset()
 	}
 
@@ -66,6 +68,9 @@ func (r *renderer) processShortcodes(input string) string {
 			continue
 		}
 
+		if segment.Len() == 0 {
+			segmentLine = i
+		}
 		segment.WriteString(line)
 		if i < len(lines)-1 {
 			segment.WriteByte('\n')
@@ -76,21 +81,33 @@ func (r *renderer) processShortcodes(input string) string {
 	return out.String()
 }
 
-func (r *renderer) processShortcodesSegment(input string) string {
+// processShortcodesSegment replaces shortcodes in a run of lines outside
+// fenced code. lineOffset is the number of source lines before input, so
+// warnings can point at the original file.
+func (r *renderer) processShortcodesSegment(input string, lineOffset int) string {
 	var out strings.Builder
 	idx := 0
 
 	for idx < len(input) {
-		next := strings.Index(input[idx:], "{{")
+		next := nextShortcodeOpen(input, idx)
 		if next == -1 {
 			out.WriteString(input[idx:])
 			break
 		}
-		next += idx
 		out.WriteString(input[idx:next])
 
+		if literal, end, ok := parseEscapedShortcode(input, next); ok {
+			out.WriteString(literal)
+			idx = end
+			continue
+		}
+
 		tag, ok := parseShortcodeTag(input, next)
 		if !ok {
+			if problem := malformedShortcode(input, next); problem != "" {
+				line, column := linePosition(input, next)
+				r.warnShortcode("%s at line %d, column %d", problem, lineOffset+line, column)
+			}
 			out.WriteString(input[next : next+2])
 			idx = next + 2
 			continue
@@ -107,7 +124,8 @@ func (r *renderer) processShortcodesSegment(input string) string {
 		if standalone {
 			inner, end, closed := r.extractShortcodeInner(input, tag)
 			if closed {
-				renderedInner, innerIsHTML := r.renderShortcodeInner(tag, inner)
+				innerLine, _ := linePosition(input, tag.end)
+				renderedInner, innerIsHTML := r.renderShortcodeInner(tag, inner, lineOffset+innerLine-1)
 				html, ok := r.renderShortcode(tag, renderedInner, innerIsHTML)
 				if !ok {
 					out.WriteString(input[tag.start:end])
@@ -144,11 +162,10 @@ func (r *renderer) extractShortcodeInner(input string, tag shortcodeTag) (string
 	var mismatched []shortcodeTag
 
 	for idx < len(input) {
-		next := strings.Index(input[idx:], "{{")
+		next := nextShortcodeOpen(input, idx)
 		if next == -1 {
 			return "", 0, false
 		}
-		next += idx
 
 		nested, ok := parseShortcodeTag(input, next)
 		if !ok {
@@ -188,28 +205,37 @@ func (r *renderer) extractShortcodeInner(input string, tag shortcodeTag) (string
 	return "", 0, false
 }
 
-func (r *renderer) renderShortcodeInner(tag shortcodeTag, inner string) (string, bool) {
+// renderShortcodeInner renders the content between a tag pair. lineOffset
+// is the number of source lines before inner.
+func (r *renderer) renderShortcodeInner(tag shortcodeTag, inner string, lineOffset int) (string, bool) {
+	innerOptions := r.options
+	innerOptions.lineOffset = lineOffset
+	innerOptions.AutoTOC = false
+
 	if tag.delimiter == '<' {
-		innerOptions := r.options
 		innerOptions.SkipPageTOC = true
 		result := RenderWithOptions(inner, innerOptions)
+		r.errors = append(r.errors, result.Errors...)
+		r.useShortcodes(result.Shortcodes...)
 		return result.HTML, true
 	}
 
-	return r.renderRawShortcodes(inner), false
+	return r.renderRawShortcodes(inner, innerOptions), false
 }
 
-func (r *renderer) renderRawShortcodes(inner string) string {
-	if r.options.ShortcodeRenderer == nil {
+func (r *renderer) renderRawShortcodes(inner string, opts RenderOptions) string {
+	if opts.ShortcodeRenderer == nil {
 		return inner
 	}
 
 	nested := &renderer{
 		input:   inner,
-		options: r.options,
+		options: opts,
 	}
 
 	nested.input = nested.processShortcodes(inner)
+	r.errors = append(r.errors, nested.errors...)
+	r.useShortcodes(nested.usedShortcodes...)
 	return nested.replaceShortcodes(nested.input)
 }
 
@@ -217,8 +243,19 @@ func (r *renderer) renderShortcode(tag shortcodeTag, inner string, innerIsHTML b
 	if r.options.ShortcodeRenderer == nil {
 		return "", false
 	}
+	r.useShortcodes(tag.name)
 
-	html, err := r.options.ShortcodeRenderer.RenderShortcode(tag.name, tag.params, inner, innerIsHTML, r.options.Page)
+	if tag.name == "include" && r.options.RootDir != "" && !r.hasShortcodeTemplate(tag.name) {
+		return r.renderInclude(tag), true
+	}
+	if (tag.name == "ref" || tag.name == "relref") && r.options.Site != nil {
+		return r.renderRef(tag), true
+	}
+	if tag.name == "param" && r.options.Page != nil {
+		return r.renderParam(tag), true
+	}
+
+	html, err := r.options.ShortcodeRenderer.RenderShortcode(tag.name, tag.params, tag.positional, inner, innerIsHTML, r.options.Page, r.options.Site)
 	if err != nil {
 		r.warnShortcode("rendering shortcode %q failed: %v", tag.name, err)
 		return "", false
@@ -227,6 +264,29 @@ func (r *renderer) renderShortcode(tag shortcodeTag, inner string, innerIsHTML b
 	return html, true
 }
 
+// shortcodeLookup is implemented by shortcode renderers that can say
+// whether they have a template of their own for a shortcode.
+type shortcodeLookup interface {
+	HasShortcode(name string) bool
+}
+
+// hasShortcodeTemplate reports whether the shortcode renderer has its own
+// template for name, which then takes the place of a built-in shortcode.
+func (r *renderer) hasShortcodeTemplate(name string) bool {
+	lookup, ok := r.options.ShortcodeRenderer.(shortcodeLookup)
+	return ok && lookup.HasShortcode(name)
+}
+
+// useShortcodes records shortcode names for RenderResult.Shortcodes,
+// keeping the list sorted and free of duplicates.
+func (r *renderer) useShortcodes(names ...string) {
+	for _, name := range names {
+		if i, found := slices.BinarySearch(r.usedShortcodes, name); !found {
+			r.usedShortcodes = slices.Insert(r.usedShortcodes, i, name)
+		}
+	}
+}
+
 func (r *renderer) addShortcodePlaceholder(html string, block bool) string {
 	r.shortcodeCounter++
 	token := fmt.Sprintf("::canopy-shortcode-%d::", r.shortcodeCounter)
@@ -264,12 +324,19 @@ func (r *renderer) blockShortcodeToken(line string) (string, bool) {
 }
 
 func (r *renderer) warnShortcode(format string, args ...any) {
-	prefix := "shortcode"
+	r.warn(r.shortcodePrefix() + ": " + fmt.Sprintf(format, args...))
+}
+
+// failShortcode records an error that fails the build.
+func (r *renderer) failShortcode(format string, args ...any) {
+	r.errors = append(r.errors, fmt.Errorf(r.shortcodePrefix()+": "+format, args...))
+}
+
+func (r *renderer) shortcodePrefix() string {
 	if r.options.Page != nil && r.options.Page.SourcePath != "" {
-		prefix = r.options.Page.SourcePath
+		return r.options.Page.SourcePath
 	}
-	message := fmt.Sprintf(format, args...)
-	fmt.Fprintf(os.Stderr, "warning: %s: %s\n", prefix, message)
+	return "shortcode"
 }
 
 func isTagStandalone(input string, start, end int) bool {
@@ -331,6 +398,7 @@ func parseShortcodeTag(input string, start int) (shortcodeTag, bool) {
 	}
 
 	var params map[string]string
+	var positional []string
 	for {
 		idx = skipSpaces(input, idx)
 		if idx >= len(input) {
@@ -341,41 +409,36 @@ func parseShortcodeTag(input string, start int) (shortcodeTag, bool) {
 				params = map[string]string{}
 			}
 			raw := input[start:end]
-			return shortcodeTag{name: name, params: params, delimiter: delimiter, start: start, end: end, raw: raw}, true
+			return shortcodeTag{name: name, params: params, positional: positional, delimiter: delimiter, start: start, end: end, raw: raw}, true
 		}
 
-		if !isNameStart(input[idx]) {
-			return shortcodeTag{}, false
-		}
+		// A value without a key is a positional parameter
 		keyStart := idx
-		idx++
 		for idx < len(input) && isNameChar(input[idx]) {
 			idx++
 		}
 		key := input[keyStart:idx]
 		idx = skipSpaces(input, idx)
-		if idx >= len(input) || input[idx] != '=' {
-			return shortcodeTag{}, false
+		if key == "" || !isNameStart(key[0]) || idx >= len(input) || input[idx] != '=' {
+			value, next, ok := parseValue(input, keyStart, delimiter)
+			if !ok {
+				return shortcodeTag{}, false
+			}
+			positional = append(positional, value)
+			idx = next
+			continue
 		}
+
 		idx++
 		idx = skipSpaces(input, idx)
 		if idx >= len(input) {
 			return shortcodeTag{}, false
 		}
-		quote := input[idx]
-		if quote != '"' && quote != '\'' {
-			return shortcodeTag{}, false
-		}
-		idx++
-		valueStart := idx
-		for idx < len(input) && input[idx] != quote {
-			idx++
-		}
-		if idx >= len(input) {
+		value, next, ok := parseValue(input, idx, delimiter)
+		if !ok {
 			return shortcodeTag{}, false
 		}
-		value := input[valueStart:idx]
-		idx++
+		idx = next
 
 		if params == nil {
 			params = make(map[string]string)
@@ -384,19 +447,131 @@ func parseShortcodeTag(input string, start int) (shortcodeTag, bool) {
 	}
 }
 
+// parseEscapedShortcode recognizes the escaped forms {{</* name */>}} and
+// {{%/* name */%}} at start and returns the tag as literal text with the
+// comment markers removed, so sites can show shortcode syntax in prose.
+func parseEscapedShortcode(input string, start int) (string, int, bool) {
+	if start+4 >= len(input) || !strings.HasPrefix(input[start:], "{{") {
+		return "", 0, false
+	}
+
+	delimiter := input[start+2]
+	if delimiter != '<' && delimiter != '%' {
+		return "", 0, false
+	}
+	if !strings.HasPrefix(input[start+3:], "/*") {
+		return "", 0, false
+	}
+
+	closer := "%}}"
+	if delimiter == '<' {
+		closer = ">}}"
+	}
+
+	bodyStart := start + 5
+	end := strings.Index(input[bodyStart:], "*/"+closer)
+	if end == -1 {
+		return "", 0, false
+	}
+	body := input[bodyStart : bodyStart+end]
+
+	return input[start:start+3] + body + closer, bodyStart + end + 2 + len(closer), true
+}
+
+// parseValue reads a parameter value starting at idx and returns it with
+// the index just past it. Quoted values may contain spaces; unquoted ones
+// like 600, true, or wide end at whitespace or the closing delimiter.
+func parseValue(input string, idx int, delimiter byte) (string, int, bool) {
+	quote := input[idx]
+	if quote == '"' || quote == '\'' {
+		end := strings.IndexByte(input[idx+1:], quote)
+		if end == -1 {
+			return "", 0, false
+		}
+		return input[idx+1 : idx+1+end], idx + end + 2, true
+	}
+
+	start := idx
+	for idx < len(input) && consumeClosing(input, idx, delimiter) == -1 {
+		switch input[idx] {
+		case ' ', '\t', '\n', '\r':
+			return input[start:idx], idx, idx > start
+		case '"', '\'', '=':
+			return "", 0, false
+		}
+		idx++
+	}
+	return input[start:idx], idx, idx > start && idx < len(input)
+}
+
+// malformedShortcode describes why the shortcode-looking text at start
+// failed to parse, or returns "" when it doesn't open a shortcode at all,
+// like a literal "{{" in prose.
+func malformedShortcode(input string, start int) string {
+	if start+2 >= len(input) {
+		return ""
+	}
+	delimiter := input[start+2]
+	if delimiter != '<' && delimiter != '%' {
+		return ""
+	}
+
+	idx := skipSpaces(input, start+3)
+	if idx < len(input) && input[idx] == '/' {
+		idx = skipSpaces(input, idx+1)
+	}
+	if idx >= len(input) || !isNameStart(input[idx]) {
+		return ""
+	}
+
+	closer := "%}}"
+	if delimiter == '<' {
+		closer = ">}}"
+	}
+	end := strings.Index(input[start:], closer)
+	if end == -1 {
+		return "unterminated shortcode " + shortcodeSnippet(input[start:]) + " starting"
+	}
+	return "malformed shortcode " + shortcodeSnippet(input[start:start+end+len(closer)])
+}
+
+// shortcodeSnippet trims text to its first line and a readable length.
+func shortcodeSnippet(text string) string {
+	if newline := strings.IndexByte(text, '\n'); newline != -1 {
+		text = text[:newline]
+	}
+	text = strings.TrimSpace(text)
+	if len(text) > 60 {
+		text = text[:60] + "..."
+	}
+	return text
+}
+
+// linePosition returns the 1-based line and column of offset in input.
+func linePosition(input string, offset int) (int, int) {
+	line := strings.Count(input[:offset], "\n") + 1
+	column := offset - strings.LastIndex(input[:offset], "\n")
+	return line, column
+}
+
 func stripShortcodes(input string) string {
 	var out strings.Builder
 	idx := 0
 
 	for idx < len(input) {
-		next := strings.Index(input[idx:], "{{")
+		next := nextShortcodeOpen(input, idx)
 		if next == -1 {
 			out.WriteString(input[idx:])
 			break
 		}
-		next += idx
 		out.WriteString(input[idx:next])
 
+		if literal, end, ok := parseEscapedShortcode(input, next); ok {
+			out.WriteString(literal)
+			idx = end
+			continue
+		}
+
 		tag, ok := parseShortcodeTag(input, next)
 		if !ok {
 			out.WriteString(input[next : next+2])
@@ -433,11 +608,10 @@ func findShortcodeEnd(input string, tag shortcodeTag) (int, bool) {
 	idx := tag.end
 
 	for idx < len(input) {
-		next := strings.Index(input[idx:], "{{")
+		next := nextShortcodeOpen(input, idx)
 		if next == -1 {
 			return 0, false
 		}
-		next += idx
 
 		nested, ok := parseShortcodeTag(input, next)
 		if !ok {
@@ -464,6 +638,61 @@ func findShortcodeEnd(input string, tag shortcodeTag) (int, bool) {
 	return 0, false
 }
 
+// nextShortcodeOpen returns the index of the next "{{" at or after idx
+// that is outside an inline code span, or -1. Shortcodes written in
+// backticks are documentation, not calls.
+func nextShortcodeOpen(input string, idx int) int {
+	for idx < len(input) {
+		next := strings.IndexAny(input[idx:], "{`")
+		if next == -1 {
+			return -1
+		}
+		next += idx
+
+		if input[next] == '`' {
+			idx = skipCodeSpan(input, next)
+			continue
+		}
+		if strings.HasPrefix(input[next:], "{{") {
+			return next
+		}
+		idx = next + 1
+	}
+	return -1
+}
+
+// skipCodeSpan returns the index just past the code span opened by the
+// backtick run at start. A run with no closing run of the same length
+// before the end of the paragraph is not a code span, and only the run
+// itself is skipped.
+func skipCodeSpan(input string, start int) int {
+	open := start
+	for open < len(input) && input[open] == '`' {
+		open++
+	}
+	size := open - start
+
+	limit := len(input)
+	if blank := strings.Index(input[open:], "\n\n"); blank != -1 {
+		limit = open + blank
+	}
+
+	for i := open; i < limit; {
+		if input[i] != '`' {
+			i++
+			continue
+		}
+		run := i
+		for i < limit && input[i] == '`' {
+			i++
+		}
+		if i-run == size {
+			return i
+		}
+	}
+	return open
+}
+
 func skipSpaces(input string, idx int) int {
 	for idx < len(input) {
 		if input[idx] != ' ' && input[idx] != '\t' && input[idx] != '\n' && input[idx] != '\r' {
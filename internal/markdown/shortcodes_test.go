@@ -2,15 +2,19 @@ package markdown
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/shanepadgett/canopy/internal/core"
 )
 
 type stubShortcodeRenderer struct{}
 
-func (stubShortcodeRenderer) RenderShortcode(name string, params map[string]string, inner string, innerIsHTML bool, page *core.Page) (string, error) {
+func (stubShortcodeRenderer) RenderShortcode(name string, params map[string]string, positional []string, inner string, innerIsHTML bool, page *core.Page, site *core.Site) (string, error) {
 	if innerIsHTML {
 		return fmt.Sprintf("<sc name=%s html=%t>%s</sc>", name, innerIsHTML, inner), nil
 	}
@@ -52,3 +56,391 @@ func TestRenderBlockShortcodeRawInner(t *testing.T) {
 		t.Errorf("expected raw inner text, got %q", result.HTML)
 	}
 }
+
+func TestRenderUsedShortcodes(t *testing.T) {
+	input := "{{< youtube id=\"a\" >}}\n\n{{< callout >}}\n{{< tweet id=\"1\" >}}\n{{< /callout >}}\n\n{{% code-tabs %}}\n{{< youtube id=\"b\" >}}\n{{% /code-tabs %}}"
+	result := RenderWithOptions(input, RenderOptions{ShortcodeRenderer: stubShortcodeRenderer{}})
+
+	want := []string{"callout", "code-tabs", "tweet", "youtube"}
+	if !reflect.DeepEqual(result.Shortcodes, want) {
+		t.Errorf("Shortcodes = %q, want %q", result.Shortcodes, want)
+	}
+	if result := Render("No shortcodes {{< here >}}"); result.Shortcodes != nil {
+		t.Errorf("Shortcodes = %q without a renderer, want none", result.Shortcodes)
+	}
+}
+
+func TestRenderShortcodeWarnings(t *testing.T) {
+	var warnings []string
+	input := "Text {{< /callout >}} here"
+	RenderWithOptions(input, RenderOptions{
+		Page:              &core.Page{SourcePath: "guides/intro.md"},
+		ShortcodeRenderer: stubShortcodeRenderer{},
+		Warn: func(message string) {
+			warnings = append(warnings, message)
+		},
+	})
+
+	if len(warnings) != 1 {
+		t.Fatalf("expected 1 warning, got %v", warnings)
+	}
+	if !strings.HasPrefix(warnings[0], "guides/intro.md: mismatched closing shortcode") {
+		t.Errorf("unexpected warning %q", warnings[0])
+	}
+}
+
+func TestRenderShortcodeParseWarnings(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{
+			name:  "unterminated",
+			input: "Intro\n\n```\n{{< not-a-tag\n```\n\nSee {{< foo id=\"x\"\nmore",
+			want:  []string{`guides/intro.md: unterminated shortcode {{< foo id="x" starting at line 16, column 5`},
+		},
+		{
+			name:  "bad quoting",
+			input: `Text {{% figure src="a.jpg" alt="x"y" %}}`,
+			want:  []string{`guides/intro.md: malformed shortcode {{% figure src="a.jpg" alt="x"y" %}} at line 10, column 6`},
+		},
+		{
+			name:  "inside block shortcode",
+			input: "{{< callout >}}\nOne\n\nTwo {{< youtube id=\"a >}}\n{{< /callout >}}",
+			want:  []string{`guides/intro.md: malformed shortcode {{< youtube id="a >}} at line 13, column 5`},
+		},
+		{
+			name:  "not shortcodes",
+			input: "Template {{ .Title }} and {{< >}} and `{{< foo`",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var warnings []string
+			RenderWithOptions(tt.input, RenderOptions{
+				Page:              &core.Page{SourcePath: "guides/intro.md", ContentLine: 10},
+				ShortcodeRenderer: stubShortcodeRenderer{},
+				Warn: func(message string) {
+					warnings = append(warnings, message)
+				},
+			})
+
+			if !reflect.DeepEqual(warnings, tt.want) {
+				t.Errorf("warnings = %q, want %q", warnings, tt.want)
+			}
+		})
+	}
+}
+
+// pageRecorder records the page and TOC visible to each shortcode call.
+type pageRecorder struct {
+	pages []*core.Page
+	tocs  [][]core.TOCEntry
+}
+
+func (r *pageRecorder) RenderShortcode(name string, params map[string]string, positional []string, inner string, innerIsHTML bool, page *core.Page, site *core.Site) (string, error) {
+	r.pages = append(r.pages, page)
+	var toc []core.TOCEntry
+	if page != nil {
+		toc = append(toc, page.TOC...)
+	}
+	r.tocs = append(r.tocs, toc)
+	return "<sc>" + inner + "</sc>", nil
+}
+
+func TestRenderShortcodePage(t *testing.T) {
+	page := &core.Page{SourcePath: "guides/intro.md"}
+	recorder := &pageRecorder{}
+	input := "{{< toc >}}\n\n## First\n\n{{< callout >}}\n{{< youtube id=\"x\" >}}\n{{< /callout >}}\n\n## Second"
+
+	RenderWithOptions(input, RenderOptions{Page: page, ShortcodeRenderer: recorder})
+
+	if len(recorder.pages) != 3 {
+		t.Fatalf("expected 3 shortcode calls, got %d", len(recorder.pages))
+	}
+	for i, got := range recorder.pages {
+		if got != page {
+			t.Errorf("call %d: expected the render page to be passed to the shortcode, got %v", i, got)
+		}
+	}
+
+	// The leading toc shortcode already sees headings that follow it
+	if len(recorder.tocs[0]) != 2 || recorder.tocs[0][1].ID != "second" {
+		t.Errorf("expected page TOC collected before rendering, got %+v", recorder.tocs[0])
+	}
+}
+
+func TestRenderSkipPageTOC(t *testing.T) {
+	existing := []core.TOCEntry{{Level: 2, ID: "kept", Title: "Kept"}}
+
+	t.Run("inner render keeps page TOC", func(t *testing.T) {
+		page := &core.Page{TOC: existing}
+		RenderWithOptions("## Inner heading", RenderOptions{
+			Page:              page,
+			ShortcodeRenderer: stubShortcodeRenderer{},
+			SkipPageTOC:       true,
+		})
+		if len(page.TOC) != 1 || page.TOC[0].ID != "kept" {
+			t.Errorf("expected SkipPageTOC to leave Page.TOC untouched, got %+v", page.TOC)
+		}
+	})
+
+	t.Run("top-level render fills page TOC", func(t *testing.T) {
+		page := &core.Page{TOC: existing}
+		RenderWithOptions("## Outer\n\n{{< callout >}}\n## Inside\n{{< /callout >}}", RenderOptions{
+			Page:              page,
+			ShortcodeRenderer: stubShortcodeRenderer{},
+		})
+		if len(page.TOC) != 1 || page.TOC[0].ID != "outer" {
+			t.Errorf("expected Page.TOC from top-level headings only, got %+v", page.TOC)
+		}
+	})
+}
+
+func TestRenderShortcodeInCodeSpan(t *testing.T) {
+	input := "Embed with `{{< youtube >}}` or ``{{% note %}}``, but {{< youtube id=\"abc\" >}} renders."
+	result := RenderWithOptions(input, RenderOptions{ShortcodeRenderer: stubShortcodeRenderer{}})
+
+	for _, want := range []string{
+		"<code>{{&lt; youtube &gt;}}</code>",
+		"{{% note %}}",
+		"<sc name=youtube",
+	} {
+		if !strings.Contains(result.HTML, want) {
+			t.Errorf("expected %q in %q", want, result.HTML)
+		}
+	}
+	if n := strings.Count(result.HTML, "<sc "); n != 1 {
+		t.Errorf("expected only the shortcode outside code to render, got %d in %q", n, result.HTML)
+	}
+}
+
+func TestRenderEscapedShortcode(t *testing.T) {
+	input := "Write {{</* youtube id=\"abc\" */>}} to embed.\n\n{{%/* note */%}}\nText\n{{%/* /note */%}}"
+	result := RenderWithOptions(input, RenderOptions{ShortcodeRenderer: stubShortcodeRenderer{}})
+
+	for _, want := range []string{
+		"Write {{&lt; youtube id=&#34;abc&#34; &gt;}} to embed.",
+		"{{% note %}}",
+		"{{% /note %}}",
+	} {
+		if !strings.Contains(result.HTML, want) {
+			t.Errorf("expected %q in %q", want, result.HTML)
+		}
+	}
+	if strings.Contains(result.HTML, "<sc ") || strings.Contains(result.HTML, "/*") {
+		t.Errorf("expected escaped shortcodes as literal text, got %q", result.HTML)
+	}
+}
+
+type paramRecorder struct {
+	params     []map[string]string
+	positional [][]string
+}
+
+func (r *paramRecorder) RenderShortcode(name string, params map[string]string, positional []string, inner string, innerIsHTML bool, page *core.Page, site *core.Site) (string, error) {
+	r.params = append(r.params, params)
+	r.positional = append(r.positional, positional)
+	return "<sc>", nil
+}
+
+func TestRenderShortcodePositionalParams(t *testing.T) {
+	tests := []struct {
+		name           string
+		input          string
+		wantParams     map[string]string
+		wantPositional []string
+	}{
+		{"named", `{{< figure src="cat.jpg" >}}`, map[string]string{"src": "cat.jpg"}, nil},
+		{"positional", `{{< figure "cat.jpg" 'A cat' >}}`, map[string]string{}, []string{"cat.jpg", "A cat"}},
+		{"mixed", `{{< figure "cat.jpg" class="wide" "A cat" >}}`, map[string]string{"class": "wide"}, []string{"cat.jpg", "A cat"}},
+		{"unquoted named", `{{< figure width=600 lazy=true class = wide>}}`, map[string]string{"width": "600", "lazy": "true", "class": "wide"}, nil},
+		{"unquoted positional", `{{< param meta.reviewer 2 >}}`, map[string]string{}, []string{"meta.reviewer", "2"}},
+		{"raw delimiter", `{{% figure width=600%}}`, map[string]string{"width": "600"}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := &paramRecorder{}
+			RenderWithOptions(tt.input, RenderOptions{ShortcodeRenderer: recorder})
+
+			if len(recorder.params) != 1 {
+				t.Fatalf("expected 1 shortcode call, got %d", len(recorder.params))
+			}
+			if !reflect.DeepEqual(recorder.params[0], tt.wantParams) {
+				t.Errorf("params = %v, want %v", recorder.params[0], tt.wantParams)
+			}
+			if !reflect.DeepEqual(recorder.positional[0], tt.wantPositional) {
+				t.Errorf("positional = %q, want %q", recorder.positional[0], tt.wantPositional)
+			}
+		})
+	}
+}
+
+func TestRenderShortcodeInvalidParams(t *testing.T) {
+	for _, input := range []string{
+		`{{< figure width=6"00 >}}`,
+		`{{< figure title="unterminated >}}`,
+		`{{< figure width= >}}`,
+	} {
+		recorder := &paramRecorder{}
+		RenderWithOptions(input, RenderOptions{ShortcodeRenderer: recorder})
+		if len(recorder.params) != 0 {
+			t.Errorf("%s: expected the tag to be left as text, got params %v", input, recorder.params)
+		}
+	}
+}
+
+func TestRenderIncludeShortcode(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "examples"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "examples", "main.go"), []byte("package main\n\nfunc main() { println(\"<hi>\") }\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	outside := t.TempDir()
+	if err := os.WriteFile(filepath.Join(outside, "secret.txt"), []byte("secret\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(outside, "secret.txt"), filepath.Join(root, "examples", "secret.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		warning string
+	}{
+		{
+			name:  "lang from extension",
+			input: `{{< include file="examples/main.go" >}}`,
+			want:  "<pre><code class=\"language-go\">package main\n\nfunc main() { println(&#34;&lt;hi&gt;&#34;) }</code></pre>\n",
+		},
+		{
+			name:  "explicit lang",
+			input: `{{< include "examples/main.go" lang="sh" >}}`,
+			want:  `<pre><code class="language-sh">package main`,
+		},
+		{
+			name:  "quoted lang",
+			input: `{{< include "examples/main.go" lang='x" onclick="y' >}}`,
+			want:  `<pre><code class="language-x&#34; onclick=&#34;y">package main`,
+		},
+		{
+			name:    "missing file",
+			input:   `{{< include file="examples/nope.go" >}}`,
+			warning: `include "examples/nope.go": file not found`,
+		},
+		{
+			name:    "outside root",
+			input:   `{{< include file="../secret.txt" >}}`,
+			warning: `include "../secret.txt" is outside the site root`,
+		},
+		{
+			name:    "symlink outside root",
+			input:   `{{< include file="examples/secret.txt" >}}`,
+			warning: `include "examples/secret.txt" is outside the site root`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var warnings []string
+			result := RenderWithOptions(tt.input, RenderOptions{
+				RootDir:           root,
+				ShortcodeRenderer: stubShortcodeRenderer{},
+				Warn: func(message string) {
+					warnings = append(warnings, message)
+				},
+			})
+
+			if tt.want != "" && !strings.Contains(result.HTML, tt.want) {
+				t.Errorf("HTML = %q, want to contain %q", result.HTML, tt.want)
+			}
+			if tt.warning == "" && len(warnings) > 0 {
+				t.Errorf("unexpected warnings %q", warnings)
+			}
+			if tt.warning != "" {
+				if len(warnings) != 1 || !strings.HasSuffix(warnings[0], tt.warning) {
+					t.Errorf("warnings = %q, want %q", warnings, tt.warning)
+				}
+				if strings.Contains(result.HTML, "<pre>") || strings.Contains(result.HTML, "include") {
+					t.Errorf("expected no output for a failed include, got %q", result.HTML)
+				}
+			}
+		})
+	}
+}
+
+func TestRenderRefShortcode(t *testing.T) {
+	site := core.NewSite(core.Config{BaseURL: "https://example.com/"})
+	for _, page := range []*core.Page{
+		{SourcePath: "blog/hello.md", URL: "/blog/2026/hello/"},
+		{SourcePath: "blog/trip/index.md", URL: "/blog/trip/"},
+		{SourcePath: "guides/intro.md", URL: "/guides/intro/"},
+	} {
+		site.PagesBySource[page.SourcePath] = page
+	}
+	current := site.PagesBySource["blog/hello.md"]
+
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"ref", `See [intro]({{< ref "guides/intro.md" >}}).`, `<a href="https://example.com/guides/intro/">intro</a>`},
+		{"relref", `See [intro]({{< relref "/guides/intro.md#setup" >}}).`, `<a href="/guides/intro/#setup">intro</a>`},
+		{"relative to page", `{{< relref "trip" >}}`, `/blog/trip/`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := RenderWithOptions(tt.input, RenderOptions{Page: current, Site: site, ShortcodeRenderer: stubShortcodeRenderer{}})
+			if !strings.Contains(result.HTML, tt.want) {
+				t.Errorf("HTML = %q, want to contain %q", result.HTML, tt.want)
+			}
+			if len(result.Errors) > 0 {
+				t.Errorf("unexpected errors %v", result.Errors)
+			}
+		})
+	}
+
+	result := RenderWithOptions("{{< callout >}}\nSee {{< ref \"blog/missing.md\" >}}\n{{< /callout >}}", RenderOptions{Page: current, Site: site, ShortcodeRenderer: stubShortcodeRenderer{}})
+	if len(result.Errors) != 1 || result.Errors[0].Error() != `blog/hello.md: ref "blog/missing.md": page not found` {
+		t.Errorf("expected a missing page error from nested content, got %v", result.Errors)
+	}
+}
+
+func TestRenderParamShortcode(t *testing.T) {
+	page := &core.Page{
+		SourcePath: "blog/hello.md",
+		Title:      "Hello",
+		Date:       time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC),
+		Params: map[string]any{
+			"author": "Sam <sam@example.com>",
+			"meta":   map[string]any{"reviewer": "Alex", "tags": []any{"a", "b"}},
+		},
+	}
+
+	var warnings []string
+	input := "By {{< param author >}}, reviewed by {{< param \"meta.reviewer\" >}} on {{< param date >}} ({{< param meta.tags >}}) for {{< param title >}}.{{< param missing >}}"
+	result := RenderWithOptions(input, RenderOptions{
+		Page:              page,
+		ShortcodeRenderer: stubShortcodeRenderer{},
+		Warn: func(message string) {
+			warnings = append(warnings, message)
+		},
+	})
+
+	want := "<p>By Sam &lt;sam@example.com&gt;, reviewed by Alex on 2026-03-01 (a, b) for Hello.</p>\n"
+	if result.HTML != want {
+		t.Errorf("HTML = %q, want %q", result.HTML, want)
+	}
+	if len(warnings) != 1 || warnings[0] != `blog/hello.md: param "missing" is not set` {
+		t.Errorf("expected a missing param warning, got %q", warnings)
+	}
+}
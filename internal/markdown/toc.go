@@ -0,0 +1,106 @@
+package markdown
+
+import (
+	"html"
+	"strings"
+
+	"github.com/shanepadgett/canopy/internal/core"
+)
+
+// tocMarker is the rendered form of a [TOC] paragraph, which AutoTOC
+// replaces with the table of contents.
+const tocMarker = "<p>[TOC]</p>"
+
+// insertTOC places the rendered TOC at the first [TOC] paragraph, or at
+// the top of the body when there is none. Bodies without TOC entries are
+// returned unchanged.
+func insertTOC(body string, entries []core.TOCEntry) string {
+	toc := renderTOC(entries)
+	if toc == "" {
+		return body
+	}
+	if strings.Contains(body, tocMarker) {
+		return strings.Replace(body, tocMarker, toc, 1)
+	}
+	return toc + "\n" + body
+}
+
+// inTOCRange reports whether headings of level belong in the TOC.
+func (r *renderer) inTOCRange(level int) bool {
+	minLevel, maxLevel := r.options.TOCMinLevel, r.options.TOCMaxLevel
+	if minLevel == 0 {
+		minLevel = 1
+	}
+	if maxLevel == 0 {
+		maxLevel = 6
+	}
+	return level >= minLevel && level <= maxLevel
+}
+
+// NestTOC nests entries under the heading before them that they are
+// deeper than, the tree the rendered TOC lists. Skipped levels, like an
+// h4 straight after an h2, nest one step rather than leaving empty lists.
+func NestTOC(entries []core.TOCEntry) []*core.TOCNode {
+	type list struct {
+		level int
+		items *[]*core.TOCNode
+	}
+
+	var roots []*core.TOCNode
+	var lists []list // open lists, outermost first
+	for _, entry := range entries {
+		switch top := len(lists) - 1; {
+		case top < 0:
+			lists = append(lists, list{entry.Level, &roots})
+		case entry.Level > lists[top].level:
+			items := *lists[top].items
+			parent := items[len(items)-1]
+			lists = append(lists, list{entry.Level, &parent.Children})
+		default:
+			for top > 0 && entry.Level < lists[top].level {
+				if entry.Level > lists[top-1].level {
+					lists[top].level = entry.Level
+					break
+				}
+				lists = lists[:top]
+				top--
+			}
+			// A heading shallower than the first joins the outer list
+			// and takes its level, so deeper headings nest under it
+			if top == 0 && entry.Level < lists[0].level {
+				lists[0].level = entry.Level
+			}
+		}
+
+		items := lists[len(lists)-1].items
+		*items = append(*items, &core.TOCNode{Level: entry.Level, ID: entry.ID, Title: entry.Title})
+	}
+	return roots
+}
+
+// renderTOC renders entries as nested lists following NestTOC.
+func renderTOC(entries []core.TOCEntry) string {
+	nodes := NestTOC(entries)
+	if len(nodes) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString("<nav class=\"toc\">\n")
+	writeTOCList(&b, nodes)
+	b.WriteString("</nav>")
+	return b.String()
+}
+
+func writeTOCList(b *strings.Builder, nodes []*core.TOCNode) {
+	b.WriteString("<ul>\n")
+	for _, node := range nodes {
+		b.WriteString(`<li><a href="#` + html.EscapeString(node.ID) + `">` + html.EscapeString(node.Title) + `</a>`)
+		if len(node.Children) > 0 {
+			b.WriteString("\n")
+			writeTOCList(b, node.Children)
+		}
+		b.WriteString("</li>\n")
+	}
+	b.WriteString("</ul>\n")
+}
@@ -0,0 +1,79 @@
+package markdown
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Escaped quote forms produced by html.EscapeString
+const (
+	escapedDoubleQuote = "&#34;"
+	escapedSingleQuote = "&#39;"
+)
+
+// typographer converts straight quotes to curly quotes, "---" to an em
+// dash, "--" to an en dash, and "..." to an ellipsis. It runs on rendered
+// inline HTML and only touches text between tags, so attribute values
+// such as link URLs are left alone. Code spans are still placeholders
+// when it runs.
+func typographer(text string) string {
+	var out strings.Builder
+	prev := ' ' // last visible character, carried across tags
+
+	for i := 0; i < len(text); {
+		switch {
+		case text[i] == '<':
+			end := strings.IndexByte(text[i:], '>')
+			if end == -1 {
+				out.WriteString(text[i:])
+				return out.String()
+			}
+			out.WriteString(text[i : i+end+1])
+			i += end + 1
+		case strings.HasPrefix(text[i:], "---"):
+			out.WriteString("—")
+			prev = '—'
+			i += 3
+		case strings.HasPrefix(text[i:], "--"):
+			out.WriteString("–")
+			prev = '–'
+			i += 2
+		case strings.HasPrefix(text[i:], "..."):
+			out.WriteString("…")
+			prev = '…'
+			i += 3
+		case strings.HasPrefix(text[i:], escapedDoubleQuote):
+			if opensQuote(prev) {
+				out.WriteString("“")
+				prev = '“'
+			} else {
+				out.WriteString("”")
+				prev = '”'
+			}
+			i += len(escapedDoubleQuote)
+		case strings.HasPrefix(text[i:], escapedSingleQuote):
+			if opensQuote(prev) {
+				out.WriteString("‘")
+				prev = '‘'
+			} else {
+				out.WriteString("’")
+				prev = '’'
+			}
+			i += len(escapedSingleQuote)
+		default:
+			r, size := utf8.DecodeRuneInString(text[i:])
+			out.WriteString(text[i : i+size])
+			prev = r
+			i += size
+		}
+	}
+
+	return out.String()
+}
+
+// opensQuote reports whether a quote following prev opens a quotation.
+// Anything else, such as a letter in a contraction, closes it.
+func opensQuote(prev rune) bool {
+	return unicode.IsSpace(prev) || strings.ContainsRune("([{—–“‘", prev)
+}
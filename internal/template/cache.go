@@ -0,0 +1,147 @@
+package template
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"html/template"
+	"sort"
+	"sync"
+	"text/template/parse"
+
+	"github.com/shanepadgett/canopy/internal/core"
+)
+
+// shortcodeCache holds rendered shortcode HTML so identical shortcodes
+// render once. Shortcodes whose templates read the page or site are only
+// shared within one page. The engine replaces the cache whenever its
+// templates or asset map change.
+type shortcodeCache struct {
+	mu          sync.Mutex
+	html        map[string]string
+	pageContext map[string]bool // template name -> reads .Page, .Resource, or .Site
+}
+
+func newShortcodeCache() *shortcodeCache {
+	return &shortcodeCache{
+		html:        make(map[string]string),
+		pageContext: make(map[string]bool),
+	}
+}
+
+// key returns the cache key of a shortcode call, and false when it
+// shouldn't be cached: a page-dependent shortcode rendered without a page.
+func (c *shortcodeCache) key(tpl *template.Template, name string, params map[string]string, positional []string, inner string, innerIsHTML bool, page *core.Page) (string, bool) {
+	c.mu.Lock()
+	usesPage, ok := c.pageContext[tpl.Name()]
+	if !ok {
+		usesPage = tpl.Tree == nil || readsPageContext(tpl.Tree.Root, false)
+		c.pageContext[tpl.Name()] = usesPage
+	}
+	c.mu.Unlock()
+
+	h := sha256.New()
+	write := func(s string) {
+		h.Write([]byte(s))
+		h.Write([]byte{0})
+	}
+
+	write(name)
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		write(k)
+		write(params[k])
+	}
+	write("")
+	for _, arg := range positional {
+		write(arg)
+	}
+	write("")
+	write(inner)
+	if innerIsHTML {
+		write("html")
+	}
+	if usesPage {
+		if page == nil {
+			return "", false
+		}
+		write(page.Lang)
+		write(page.URL)
+	}
+	return hex.EncodeToString(h.Sum(nil)), true
+}
+
+func (c *shortcodeCache) get(key string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	html, ok := c.html[key]
+	return html, ok
+}
+
+func (c *shortcodeCache) put(key, html string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.html[key] = html
+}
+
+// readsPageContext reports whether a template may read the page, its
+// resources, or the site. It errs towards true: passing the whole data,
+// as in {{template "x" .}} or {{jsonify .}}, counts. rebound is set
+// inside with and range bodies, where dot is no longer the shortcode data.
+func readsPageContext(node parse.Node, rebound bool) bool {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return false
+		}
+		for _, child := range n.Nodes {
+			if readsPageContext(child, rebound) {
+				return true
+			}
+		}
+	case *parse.ActionNode:
+		return readsPageContext(n.Pipe, rebound)
+	case *parse.PipeNode:
+		if n == nil {
+			return false
+		}
+		for _, cmd := range n.Cmds {
+			if readsPageContext(cmd, rebound) {
+				return true
+			}
+		}
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			if readsPageContext(arg, rebound) {
+				return true
+			}
+		}
+	case *parse.ChainNode:
+		return readsPageContext(n.Node, rebound) || isContextField(n.Field[0])
+	case *parse.FieldNode:
+		return isContextField(n.Ident[0])
+	case *parse.VariableNode:
+		if n.Ident[0] != "$" {
+			return false
+		}
+		return len(n.Ident) == 1 || isContextField(n.Ident[1])
+	case *parse.DotNode:
+		return !rebound
+	case *parse.IfNode:
+		return readsPageContext(n.Pipe, rebound) || readsPageContext(n.List, rebound) || readsPageContext(n.ElseList, rebound)
+	case *parse.WithNode:
+		return readsPageContext(n.Pipe, rebound) || readsPageContext(n.List, true) || readsPageContext(n.ElseList, rebound)
+	case *parse.RangeNode:
+		return readsPageContext(n.Pipe, rebound) || readsPageContext(n.List, true) || readsPageContext(n.ElseList, rebound)
+	case *parse.TemplateNode:
+		return true
+	}
+	return false
+}
+
+func isContextField(name string) bool {
+	return name == "Page" || name == "Resource" || name == "Site"
+}
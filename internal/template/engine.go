@@ -8,7 +8,10 @@ import (
 	"io/fs"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
+	texttemplate "text/template"
 	"time"
 
 	"github.com/shanepadgett/canopy/internal/core"
@@ -16,8 +19,39 @@ import (
 
 // Engine loads and executes templates.
 type Engine struct {
-	templateDir string
-	templates   *template.Template
+	templateDirs []string // earlier dirs override later ones
+
+	// Warn receives warnings such as missing translations, each once
+	Warn   func(message string)
+	warned sync.Map
+
+	// mu guards the parsed templates, which Reload swaps out while
+	// renders may be running
+	mu        sync.RWMutex
+	templates *template.Template
+	baseURL   string
+	assets    map[string]string
+
+	// translations is the site whose i18n tables the i18n function
+	// reads; see SetTranslations
+	translations *core.Site
+
+	// blocks holds layouts that fill base.html's blocks with {{define}},
+	// each parsed into its own copy of the template set
+	blocks map[string]*template.Template
+
+	// formats holds the output format templates under formats/, named
+	// without their extension, like "formats/json/page". They're text
+	// templates, since their output isn't HTML.
+	formats *texttemplate.Template
+
+	// shortcodeAssets maps shortcode names to the static paths their
+	// templates declare; see ShortcodeAssets
+	shortcodeAssets map[string][]string
+
+	// shortcodes caches rendered shortcodes; it is replaced along with
+	// the templates and assets its output depends on
+	shortcodes *shortcodeCache
 }
 
 // Data is passed to templates during execution.
@@ -26,77 +60,418 @@ type Data struct {
 	Site    *core.Site
 	Section *core.Section
 	Pages   []*core.Page
+
+	// URL is the site-relative URL being rendered, for menu active states
+	URL string
+
+	// Lang is the language of a list page on a multilingual site
+	Lang string
+
+	// TOC is the page's table of contents when AutoTOC applies
+	TOC []core.TOCEntry
+
+	// Author is set when rendering an author's list page
+	Author *core.AuthorInfo
+
+	// Meta feeds the description, canonical, and Open Graph tags
+	Meta Meta
+
+	engine *Engine
+}
+
+// T translates key into the page's language, or the list's language on
+// list pages, falling back to the default language's table.
+// Missing keys render as the key itself with a warning:
+//
+//	<a href="{{.Page.URL}}">{{$.T "read_more"}}</a>
+//
+// Partials and shortcodes, whose dot is something else, use the i18n
+// function instead.
+func (d Data) T(key string) string {
+	lang := d.Site.Config.Language
+	if d.Page != nil && d.Page.Lang != "" {
+		lang = d.Page.Lang
+	} else if d.Lang != "" {
+		lang = d.Lang
+	}
+	return d.engine.translate(d.Site, lang, key)
+}
+
+// translate looks key up in site's tables for lang, warning once and
+// returning the key when it is missing.
+func (e *Engine) translate(site *core.Site, lang, key string) string {
+	if text, ok := site.Translate(lang, key); ok {
+		return text
+	}
+	if e != nil {
+		e.warnOnce(fmt.Sprintf("i18n: no translation of %q for language %q", key, lang))
+	}
+	return key
+}
+
+// param is the param template function. It looks up a front matter
+// value by dotted key, returning "" with a warning when it is missing
+// rather than failing the template:
+//
+//	{{param .Page "meta.reviewer"}}
+func (e *Engine) param(page *core.Page, key string) any {
+	if page == nil {
+		return ""
+	}
+	if value, ok := page.Param(key); ok {
+		return value
+	}
+	e.warnOnce(fmt.Sprintf("%s: param %q is not set", page.SourcePath, key))
+	return ""
+}
+
+// i18n is the i18n template function. It translates key into a language
+// code or a page's language, or the default language for anything else:
+//
+//	{{i18n "read_more" .Page}} {{i18n "read_more" "fr"}}
+func (e *Engine) i18n(key string, langOrPage any) string {
+	site := e.translations
+	if site == nil {
+		return key
+	}
+	lang := site.Config.Language
+	switch v := langOrPage.(type) {
+	case string:
+		if v != "" {
+			lang = v
+		}
+	case *core.Page:
+		if v != nil && v.Lang != "" {
+			lang = v.Lang
+		}
+	}
+	return e.translate(site, lang, key)
+}
+
+// Meta is the metadata behind a rendered page's SEO tags.
+type Meta struct {
+	Description string
+	Canonical   string // absolute URL of the rendered page
+	Type        string // og:type, "article" for pages and "website" for lists
+	Image       string // absolute URL from the "image" front matter param
+
+	// StructuredData is the page's JSON-LD when the structuredData config
+	// flag is set, nil otherwise
+	StructuredData *ArticleSchema
+}
+
+// ArticleSchema is schema.org Article structured data, marshaled with
+// jsonify into a <script type="application/ld+json"> element.
+type ArticleSchema struct {
+	Context          string         `json:"@context"`
+	Type             string         `json:"@type"`
+	Headline         string         `json:"headline"`
+	Description      string         `json:"description,omitempty"`
+	Image            string         `json:"image,omitempty"`
+	DatePublished    string         `json:"datePublished,omitempty"`
+	DateModified     string         `json:"dateModified,omitempty"`
+	Author           []SchemaPerson `json:"author,omitempty"`
+	MainEntityOfPage SchemaWebPage  `json:"mainEntityOfPage"`
+}
+
+// SchemaPerson is a schema.org Person.
+type SchemaPerson struct {
+	Type string `json:"@type"`
+	Name string `json:"name"`
+	URL  string `json:"url,omitempty"`
+}
+
+// SchemaWebPage is a schema.org WebPage, identified by its URL.
+type SchemaWebPage struct {
+	Type string `json:"@type"`
+	ID   string `json:"@id"`
+}
+
+// pageMeta builds Meta for a content page. The description falls back to
+// the page summary, then the site description.
+func pageMeta(page *core.Page, site *core.Site) Meta {
+	meta := Meta{
+		Description: page.Description,
+		Canonical:   absURL(site.Config.BaseURL, page.URL),
+		Type:        "article",
+	}
+	if meta.Description == "" {
+		meta.Description = page.Summary
+	}
+	if meta.Description == "" {
+		meta.Description = site.Config.Description
+	}
+	if image, ok := page.Params["image"].(string); ok && image != "" {
+		meta.Image = absURL(site.Config.BaseURL, image)
+	}
+	if site.Config.StructuredData {
+		meta.StructuredData = articleSchema(page, site, meta)
+	}
+	return meta
+}
+
+// articleSchema builds a page's Article JSON-LD from its metadata.
+func articleSchema(page *core.Page, site *core.Site, meta Meta) *ArticleSchema {
+	article := &ArticleSchema{
+		Context:          "https://schema.org",
+		Type:             "Article",
+		Headline:         page.Title,
+		Description:      meta.Description,
+		Image:            meta.Image,
+		MainEntityOfPage: SchemaWebPage{Type: "WebPage", ID: meta.Canonical},
+	}
+	if !page.Date.IsZero() {
+		article.DatePublished = page.Date.Format(time.RFC3339)
+	}
+	if modified := page.LastMod; !modified.IsZero() {
+		article.DateModified = modified.Format(time.RFC3339)
+	} else {
+		article.DateModified = article.DatePublished
+	}
+	for _, id := range page.Authors {
+		author := site.Author(id)
+		article.Author = append(article.Author, SchemaPerson{Type: "Person", Name: author.Name, URL: author.URL})
+	}
+	return article
+}
+
+// listMeta builds Meta for a list or home page at url.
+func listMeta(url string, site *core.Site) Meta {
+	return Meta{
+		Description: site.Config.Description,
+		Canonical:   absURL(site.Config.BaseURL, url),
+		Type:        "website",
+	}
+}
+
+// absURL joins a URL path onto baseURL. URLs with a scheme pass through.
+func absURL(baseURL, path string) string {
+	if strings.Contains(path, "://") {
+		return path
+	}
+	return strings.TrimRight(baseURL, "/") + "/" + strings.TrimLeft(path, "/")
 }
 
-// NewEngine creates a template engine with templates from the given directory.
-func NewEngine(templateDir string) (*Engine, error) {
+// NewEngine creates a template engine with templates from the given
+// directories. A template found in more than one comes from the first, so
+// a site's templates override its theme's.
+func NewEngine(templateDirs ...string) (*Engine, error) {
 	e := &Engine{
-		templateDir: templateDir,
+		templateDirs: templateDirs,
+		shortcodes:   newShortcodeCache(),
 	}
 
 	if err := e.load(); err != nil {
 		return nil, err
 	}
+	e.applyFuncs()
 
 	return e, nil
 }
 
+// SetAssets gives the assetURL template function the site's baseURL,
+// whose path prefixes asset URLs, and maps static file paths to their
+// fingerprinted names. Call it before rendering.
+func (e *Engine) SetAssets(baseURL string, assets map[string]string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.baseURL = baseURL
+	e.assets = assets
+	e.shortcodes = newShortcodeCache()
+	e.applyFuncs()
+}
+
+// SetTranslations makes the i18n template function translate with site's
+// i18n tables. Call it before rendering.
+func (e *Engine) SetTranslations(site *core.Site) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.translations = site
+	e.shortcodes = newShortcodeCache()
+}
+
+// applyFuncs binds the template functions that depend on the engine.
+func (e *Engine) applyFuncs() {
+	funcs := template.FuncMap{
+		"assetURL": assetURL(e.baseURL, e.assets),
+		"i18n":     e.i18n,
+		"param":    e.param,
+	}
+	e.templates.Funcs(funcs)
+	for _, layout := range e.blocks {
+		layout.Funcs(funcs)
+	}
+	e.formats.Funcs(texttemplate.FuncMap(funcs))
+}
+
+// Reload re-reads the template directories so edited templates take
+// effect without a new Engine. Renders in progress finish with the old
+// templates. On a parse error the engine keeps its current templates.
+func (e *Engine) Reload() error {
+	next := &Engine{templateDirs: e.templateDirs}
+	if err := next.load(); err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.templates, e.blocks, e.formats = next.templates, next.blocks, next.formats
+	e.shortcodeAssets = next.shortcodeAssets
+	e.shortcodes = newShortcodeCache()
+	e.applyFuncs()
+	return nil
+}
+
 func (e *Engine) load() error {
 	e.templates = template.New("").Funcs(templateFuncs())
+	e.formats = texttemplate.New("").Funcs(texttemplate.FuncMap(templateFuncs()))
+	e.shortcodeAssets = make(map[string][]string)
+	blockSources := make(map[string]string)
+
+	// Walk each template directory and parse all .html files. A template
+	// in an earlier directory hides the same name in later ones.
+	seen := make(map[string]bool)
+	for _, dir := range e.templateDirs {
+		if err := e.loadDir(dir, seen, blockSources); err != nil {
+			return err
+		}
+	}
 
-	// Walk template directory and parse all .html files
-	err := filepath.WalkDir(e.templateDir, func(path string, d fs.DirEntry, err error) error {
+	// Ensure we have at least a base template
+	if e.templates.Lookup("layouts/base.html") == nil {
+		if err := e.loadDefaults(); err != nil {
+			return err
+		}
+	}
+
+	if err := e.loadDefaultShortcodes(); err != nil {
+		return err
+	}
+	if err := e.loadDefaultFormats(); err != nil {
+		return err
+	}
+
+	return e.loadBlocks(blockSources)
+}
+
+// loadDir parses the templates under dir whose names aren't in seen.
+func (e *Engine) loadDir(dir string, seen map[string]bool, blockSources map[string]string) error {
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
 
-		if d.IsDir() || !strings.HasSuffix(path, ".html") {
+		if d.IsDir() {
 			return nil
 		}
 
+		// Compute template name relative to template dir
+		relPath, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+
+		// Normalize path separators for template names. Output format
+		// templates may have any extension, which their names drop.
+		name := filepath.ToSlash(relPath)
+		isFormat := strings.HasPrefix(name, "formats/")
+		if isFormat {
+			name = strings.TrimSuffix(name, filepath.Ext(name))
+		} else if !strings.HasSuffix(name, ".html") {
+			return nil
+		}
+		if seen[name] {
+			return nil
+		}
+		seen[name] = true
+
 		// Read template content
 		content, err := os.ReadFile(path)
 		if err != nil {
 			return fmt.Errorf("reading template %s: %w", path, err)
 		}
 
-		// Compute template name relative to template dir
-		relPath, err := filepath.Rel(e.templateDir, path)
-		if err != nil {
-			return err
+		if isFormat {
+			if _, err := e.formats.New(name).Parse(string(content)); err != nil {
+				return fmt.Errorf("parsing template %s: %w", path, err)
+			}
+			return nil
 		}
 
-		// Normalize path separators for template names
-		name := filepath.ToSlash(relPath)
+		// Keep block layouts out of the shared set so their defines
+		// don't collide; they're composed with base in load
+		if strings.HasPrefix(name, "layouts/") && name != "layouts/base.html" {
+			blocks, err := definesBlocks(name, string(content))
+			if err != nil {
+				return fmt.Errorf("parsing template %s: %w", path, err)
+			}
+			if blocks {
+				blockSources[name] = string(content)
+				return nil
+			}
+		}
 
 		// Parse template
 		_, err = e.templates.New(name).Parse(string(content))
 		if err != nil {
 			return fmt.Errorf("parsing template %s: %w", path, err)
 		}
+		e.declareShortcodeAssets(name, string(content))
 
 		return nil
 	})
 
-	if err != nil {
-		// If template directory doesn't exist, use embedded defaults
-		if os.IsNotExist(err) {
-			return e.loadDefaults()
-		}
+	// A missing template directory is fine: embedded defaults cover it
+	if err != nil && !os.IsNotExist(err) {
 		return err
 	}
+	return nil
+}
 
-	// Ensure we have at least a base template
-	if e.templates.Lookup("layouts/base.html") == nil {
-		if err := e.loadDefaults(); err != nil {
+// definesBlocks reports whether a layout defines named templates, which
+// makes it a block layout rather than content for base's .Content.
+func definesBlocks(name, text string) (bool, error) {
+	t, err := template.New(name).Funcs(templateFuncs()).Parse(text)
+	if err != nil {
+		return false, err
+	}
+	return len(t.Templates()) > 1, nil
+}
+
+// loadBlocks parses each block layout into a clone of the loaded templates,
+// so its defines override base.html's blocks only for that layout.
+func (e *Engine) loadBlocks(sources map[string]string) error {
+	names := make([]string, 0, len(sources))
+	for name := range sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	e.blocks = make(map[string]*template.Template, len(names))
+	for _, name := range names {
+		set, err := e.templates.Clone()
+		if err != nil {
 			return err
 		}
+		layout, err := set.New(name).Parse(sources[name])
+		if err != nil {
+			return fmt.Errorf("parsing template %s: %w", name, err)
+		}
+		e.blocks[name] = layout
 	}
+	return nil
+}
 
-	if err := e.loadDefaultShortcodes(); err != nil {
-		return err
+// layout returns the first of the named layouts that exists.
+func (e *Engine) layout(names ...string) *template.Template {
+	for _, name := range names {
+		if layout, ok := e.blocks[name]; ok {
+			return layout
+		}
+		if layout := e.templates.Lookup(name); layout != nil {
+			return layout
+		}
 	}
-
 	return nil
 }
 
@@ -128,13 +503,35 @@ func (e *Engine) loadDefaults() error {
 	return nil
 }
 
+// loadDefaultFormats adds the built-in output format templates the
+// template directories don't override.
+func (e *Engine) loadDefaultFormats() error {
+	for name, content := range defaultFormats {
+		if e.formats.Lookup(name) != nil {
+			continue
+		}
+		if _, err := e.formats.New(name).Parse(content); err != nil {
+			return fmt.Errorf("parsing default format %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
 // RenderPage renders a single page.
 func (e *Engine) RenderPage(page *core.Page, site *core.Site) (string, error) {
-	// Find section-specific layout or fall back to page layout
-	layoutName := "layouts/" + page.Section + ".html"
-	layout := e.templates.Lookup(layoutName)
-	if layout == nil {
-		layout = e.templates.Lookup("layouts/page.html")
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	// A front matter layout wins; otherwise use the type's layout, the
+	// section's layout, or fall back to the page layout
+	var layout *template.Template
+	if page.Layout != "" {
+		layout = e.layout("layouts/" + page.Layout + ".html")
+		if layout == nil {
+			return "", fmt.Errorf("layout %q not found: no layouts/%s.html", page.Layout, page.Layout)
+		}
+	} else {
+		layout = e.layout("layouts/"+page.Type+".html", "layouts/"+page.Section+".html", "layouts/page.html")
 	}
 	if layout == nil {
 		return "", fmt.Errorf("no layout found for section %q", page.Section)
@@ -143,78 +540,189 @@ func (e *Engine) RenderPage(page *core.Page, site *core.Site) (string, error) {
 	data := Data{
 		Page: page,
 		Site: site,
+		URL:  page.URL,
+		Meta: pageMeta(page, site),
+	}
+	if autoTOC(page, site.Config) {
+		data.TOC = page.TOC
 	}
 
-	// Execute content layout
-	var content bytes.Buffer
-	if err := layout.Execute(&content, data); err != nil {
-		return "", fmt.Errorf("executing layout: %w", err)
+	return e.render(layout, "layout", page.Title, data)
+}
+
+// RenderFormat renders a page to a configured output format with the
+// first of formats/<format>/<type>, formats/<format>/<section>, and
+// formats/<format>/page that exists. The result isn't wrapped in the base
+// layout or HTML-escaped.
+func (e *Engine) RenderFormat(format string, page *core.Page, site *core.Site) (string, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	dir := "formats/" + format + "/"
+	var tpl *texttemplate.Template
+	for _, name := range []string{page.Type, page.Section, "page"} {
+		if tpl = e.formats.Lookup(dir + name); tpl != nil {
+			break
+		}
+	}
+	if tpl == nil {
+		return "", fmt.Errorf("no %s template found: no %spage template", format, dir)
 	}
 
-	// Wrap in base layout
-	return e.wrapInBase(content.String(), page.Title, site)
+	data := Data{
+		Page:   page,
+		Site:   site,
+		URL:    page.URL,
+		Meta:   pageMeta(page, site),
+		engine: e,
+	}
+	if autoTOC(page, site.Config) {
+		data.TOC = page.TOC
+	}
+
+	var out bytes.Buffer
+	if err := tpl.Execute(&out, data); err != nil {
+		return "", fmt.Errorf("executing %s template: %w", format, err)
+	}
+	return out.String(), nil
+}
+
+// autoTOC reports whether a page's TOC should be handed to layouts.
+func autoTOC(page *core.Page, cfg core.Config) bool {
+	if toc, ok := page.Params["toc"]; ok && (toc == false || toc == "false") {
+		return false
+	}
+	return cfg.AutoTOC || cfg.Sections[page.Section].AutoTOC
 }
 
 // RenderList renders a section index page.
 func (e *Engine) RenderList(section *core.Section, site *core.Site) (string, error) {
-	layout := e.templates.Lookup("layouts/list.html")
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	layout := e.layout("layouts/list.html")
 	if layout == nil {
 		return "", fmt.Errorf("no list layout found")
 	}
 
+	url := section.URL
+	if url == "" {
+		url = "/" + section.Name + "/"
+	}
 	data := Data{
 		Site:    site,
 		Section: section,
 		Pages:   section.Pages,
+		URL:     url,
+		Lang:    section.Lang,
+		Meta:    listMeta(url, site),
 	}
 
-	var content bytes.Buffer
-	if err := layout.Execute(&content, data); err != nil {
-		return "", fmt.Errorf("executing list layout: %w", err)
+	title := section.Title
+	if title == "" {
+		title = strings.Title(section.Name)
 	}
-
-	title := strings.Title(section.Name)
-	return e.wrapInBase(content.String(), title, site)
+	return e.render(layout, "list layout", title, data)
 }
 
-// RenderHome renders the home page.
-func (e *Engine) RenderHome(site *core.Site) (string, error) {
-	layout := e.templates.Lookup("layouts/home.html")
+// RenderAuthor renders an author's page list. It uses layouts/author.html
+// when present and falls back to the list layout, with .Author set.
+func (e *Engine) RenderAuthor(author core.AuthorInfo, pages []*core.Page, site *core.Site) (string, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	layout := e.layout("layouts/author.html", "layouts/list.html")
 	if layout == nil {
-		layout = e.templates.Lookup("layouts/list.html")
+		return "", fmt.Errorf("no author layout found")
 	}
+
+	url := "/authors/" + author.ID + "/"
+	section := &core.Section{Name: author.ID, Title: author.Name, URL: url, Pages: pages}
+	data := Data{
+		Site:    site,
+		Section: section,
+		Pages:   pages,
+		URL:     url,
+		Author:  &author,
+		Meta:    listMeta(url, site),
+	}
+
+	return e.render(layout, "author layout", author.Name, data)
+}
+
+// RenderHome renders the home page. On multilingual sites each language
+// has its own, under its prefix and listing only its pages; lang is empty
+// otherwise.
+func (e *Engine) RenderHome(site *core.Site, lang string) (string, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	layout := e.layout("layouts/home.html", "layouts/list.html")
 	if layout == nil {
 		return "", fmt.Errorf("no home layout found")
 	}
 
+	pages := site.Pages
+	if lang != "" {
+		pages = nil
+		for _, page := range site.Pages {
+			if page.Lang == lang {
+				pages = append(pages, page)
+			}
+		}
+	}
+
+	url := site.Config.LangPrefix(lang) + "/"
 	data := Data{
 		Site:  site,
-		Pages: site.Pages,
+		Pages: pages,
+		URL:   url,
+		Lang:  lang,
+		Meta:  listMeta(url, site),
+	}
+
+	return e.render(layout, "home layout", site.Config.Title, data)
+}
+
+// render executes a layout and wraps the result in the base layout. Block
+// layouts run base.html from their own set instead, so their defines fill
+// its blocks; kind names the layout in errors.
+func (e *Engine) render(layout *template.Template, kind, title string, data Data) (string, error) {
+	data.engine = e
+	if e.blocks[layout.Name()] == layout {
+		return executeBase(layout.Lookup("layouts/base.html"), "", title, data)
 	}
 
 	var content bytes.Buffer
 	if err := layout.Execute(&content, data); err != nil {
-		return "", fmt.Errorf("executing home layout: %w", err)
+		return "", fmt.Errorf("executing %s: %w", kind, err)
 	}
 
-	return e.wrapInBase(content.String(), site.Config.Title, site)
-}
-
-func (e *Engine) wrapInBase(content, title string, site *core.Site) (string, error) {
 	base := e.templates.Lookup("layouts/base.html")
 	if base == nil {
 		// No base layout, return content as-is
-		return content, nil
+		return content.String(), nil
+	}
+	return executeBase(base, content.String(), title, data)
+}
+
+func (e *Engine) warnOnce(message string) {
+	if _, seen := e.warned.LoadOrStore(message, true); !seen && e.Warn != nil {
+		e.Warn(message)
 	}
+}
 
+// executeBase runs the base layout with the content layout's data plus
+// Title and the rendered Content.
+func executeBase(base *template.Template, content, title string, data Data) (string, error) {
 	baseData := struct {
+		Data
 		Title   string
 		Content template.HTML
-		Site    *core.Site
 	}{
+		Data:    data,
 		Title:   title,
 		Content: template.HTML(content),
-		Site:    site,
 	}
 
 	var out bytes.Buffer
@@ -225,23 +733,60 @@ func (e *Engine) wrapInBase(content, title string, site *core.Site) (string, err
 	return out.String(), nil
 }
 
+// templateFuncs returns the functions available to all templates.
+//
+// The safe* helpers bypass html/template's contextual escaping and must
+// only be used with trusted values such as site config.
 func templateFuncs() template.FuncMap {
 	return template.FuncMap{
 		"safeHTML": func(s string) template.HTML {
 			return template.HTML(s)
 		},
+		"safeURL": func(s string) template.URL {
+			return template.URL(s)
+		},
+		"safeCSS": func(s string) template.CSS {
+			return template.CSS(s)
+		},
+		"safeJS": func(s string) template.JS {
+			return template.JS(s)
+		},
 		"now": func() time.Time {
 			return time.Now()
 		},
 		"dateFormat": func(layout string, t time.Time) string {
 			return t.Format(layout)
 		},
+		"timeAgo": func(t time.Time) string {
+			return humanizeDuration(t, time.Now())
+		},
+		"humanizeTime": func(maxDays int, layout string, t time.Time) string {
+			return humanizeTime(maxDays, layout, t, time.Now())
+		},
 		"lower": strings.ToLower,
 		"upper": strings.ToUpper,
 		"title": strings.Title,
 		"slice": func(args ...any) []any {
 			return args
 		},
+		"dict":     dict,
+		"jsonify":  jsonify,
+		"isActive": isActive,
+		"srcset":   srcset,
+
+		"pluralize":   pluralize,
+		"singularize": singularize,
+		"plural":      plural,
+
+		"truncate":      truncate,
+		"truncateWords": truncateWords,
+		"chunk":         chunk,
+
+		"groupBy":      groupBy,
+		"groupByYear":  groupByYear,
+		"groupByMonth": groupByMonth,
+		"assetURL":     assetURL("", nil),
+		"i18n":         func(key string, langOrPage any) string { return key },
 		"first": func(n int, items []*core.Page) []*core.Page {
 			if n > len(items) {
 				n = len(items)
@@ -254,17 +799,37 @@ func templateFuncs() template.FuncMap {
 			}
 			return items[len(items)-n:]
 		},
+		// param is bound to Engine.param, which also warns about
+		// missing keys.
+		"param": func(page *core.Page, key string) any {
+			if page == nil {
+				return ""
+			}
+			if value, ok := page.Param(key); ok {
+				return value
+			}
+			return ""
+		},
 	}
 }
 
 // Default templates
 const defaultBaseLayout = `<!DOCTYPE html>
-<html lang="en">
+<html lang="{{with .Page}}{{.Lang}}{{else}}{{with $.Lang}}{{.}}{{else}}{{$.Site.Config.Language}}{{end}}{{end}}">
 <head>
   <meta charset="UTF-8">
   <meta name="viewport" content="width=device-width, initial-scale=1.0">
   <title>{{.Title}} - {{.Site.Config.Name}}</title>
-  <meta name="description" content="{{.Site.Config.Description}}">
+  <meta name="description" content="{{.Meta.Description}}">
+  <link rel="canonical" href="{{.Meta.Canonical}}">
+  <meta property="og:title" content="{{.Title}}">
+  <meta property="og:description" content="{{.Meta.Description}}">
+  <meta property="og:url" content="{{.Meta.Canonical}}">
+  <meta property="og:type" content="{{.Meta.Type}}">
+  {{with .Meta.Image}}<meta property="og:image" content="{{.}}">{{end}}
+  <meta name="twitter:card" content="{{if .Meta.Image}}summary_large_image{{else}}summary{{end}}">
+  {{with .Meta.StructuredData}}<script type="application/ld+json">{{jsonify .}}</script>{{end}}
+  {{with .Page}}{{range .Assets.CSS}}<link rel="stylesheet" href="{{assetURL .}}">{{end}}{{end}}
   {{if .Site.Config.Search.Enabled}}
   <style>
     .search-button {
@@ -363,13 +928,14 @@ const defaultBaseLayout = `<!DOCTYPE html>
     }
   </style>
   {{end}}
+  {{block "head" .}}{{end}}
 </head>
 <body>
   <header>
     <nav>
       <a href="/">{{.Site.Config.Name}}</a>
-      {{range .Site.Config.Nav}}
-      <a href="{{.URL}}">{{.Title}}</a>
+      {{range .Site.Menus.main}}
+      <a{{if .IsActive $.URL}} aria-current="page"{{end}} href="{{.URL}}">{{.Title}}</a>
       {{end}}
       {{if .Site.Config.Search.Enabled}}
       <button class="search-button" type="button" data-search-open>Search</button>
@@ -377,7 +943,16 @@ const defaultBaseLayout = `<!DOCTYPE html>
     </nav>
   </header>
   <main>
-    {{.Content}}
+    {{with .TOC}}
+    <nav class="page-toc" aria-label="Table of contents">
+      <ol>
+        {{range .}}
+        <li class="toc-level-{{.Level}}"><a href="#{{.ID}}">{{.Title}}</a></li>
+        {{end}}
+      </ol>
+    </nav>
+    {{end}}
+    {{block "main" .}}{{.Content}}{{end}}
   </main>
   <footer>
     <p>&copy; {{now.Year}} {{.Site.Config.Name}}</p>
@@ -427,7 +1002,7 @@ const defaultBaseLayout = `<!DOCTYPE html>
         if (searchData) {
           return;
         }
-        fetch('/search.json')
+        fetch('/{{.Site.Config.Search.Path}}')
           .then(function(response) {
             if (!response.ok) {
               throw new Error('search index failed');
@@ -435,7 +1010,13 @@ const defaultBaseLayout = `<!DOCTYPE html>
             return response.json();
           })
           .then(function(data) {
-            searchData = Array.isArray(data) ? data : [];
+            if (Array.isArray(data)) {
+              searchData = data;
+            } else if (data && Array.isArray(data.entries)) {
+              searchData = data.entries;
+            } else {
+              searchData = [];
+            }
             updateResults();
           })
           .catch(function() {
@@ -670,6 +1251,9 @@ const defaultBaseLayout = `<!DOCTYPE html>
     })();
   </script>
   {{end}}
+  {{with .Page}}{{range .Assets.JS}}<script src="{{assetURL .}}" defer></script>{{end}}{{end}}
+  {{with .Page}}{{if .HasShortcode "tweet"}}<script async src="https://platform.twitter.com/widgets.js" charset="utf-8"></script>{{end}}{{end}}
+  {{block "scripts" .}}{{end}}
 </body>
 </html>`
 
@@ -678,9 +1262,30 @@ const defaultPageLayout = `<article>
   {{if not .Page.Date.IsZero}}
   <time datetime="{{dateFormat "2006-01-02" .Page.Date}}">{{dateFormat "January 2, 2006" .Page.Date}}</time>
   {{end}}
+  {{if .Page.Authors}}
+  <p class="byline">
+    {{range .Page.Authors}}
+    <a href="/authors/{{.}}/" rel="author">{{($.Site.Author .).Name}}</a>
+    {{end}}
+  </p>
+  {{end}}
   <div class="content">
     {{safeHTML .Page.Body}}
   </div>
+  {{if .Page.SeriesPages}}
+  <nav class="series">
+    <p>Part {{.Page.SeriesPart}} of {{len .Page.SeriesPages}} in <a href="{{.Page.SeriesURL}}">{{.Page.Series}}</a></p>
+    {{with .Page.SeriesPrev}}<a href="{{.URL}}" rel="prev">&larr; {{.Title}}</a>{{end}}
+    {{with .Page.SeriesNext}}<a href="{{.URL}}" rel="next">{{.Title}} &rarr;</a>{{end}}
+  </nav>
+  {{end}}
+  {{if .Page.Translations}}
+  <nav class="translations">
+    {{range .Page.Translations}}
+    <a href="{{.URL}}" hreflang="{{.Lang}}" lang="{{.Lang}}">{{.Lang}}</a>
+    {{end}}
+  </nav>
+  {{end}}
   {{if .Page.Tags}}
   <div class="tags">
     {{range .Page.Tags}}
@@ -688,9 +1293,26 @@ const defaultPageLayout = `<article>
     {{end}}
   </div>
   {{end}}
+  {{if .Page.Related}}
+  <aside class="related">
+    <h2>Related</h2>
+    <ul>
+      {{range .Page.Related}}
+      <li><a href="{{.URL}}">{{.Title}}</a></li>
+      {{end}}
+    </ul>
+  </aside>
+  {{end}}
 </article>`
 
-const defaultListLayout = `<h1>{{.Section.Name}}</h1>
+const defaultListLayout = `<h1>{{.Section.Title}}</h1>
+{{with .Author}}
+<div class="author-bio">
+  {{if .Avatar}}<img src="{{.Avatar}}" alt="{{.Name}}" class="avatar">{{end}}
+  {{if .Bio}}<p>{{.Bio}}</p>{{end}}
+  {{if .URL}}<a href="{{.URL}}" rel="me">{{.URL}}</a>{{end}}
+</div>
+{{end}}
 <ul>
 {{range .Pages}}
   <li>
@@ -702,6 +1324,14 @@ const defaultListLayout = `<h1>{{.Section.Name}}</h1>
 {{end}}
 </ul>`
 
+// defaultFormats are the built-in output format templates.
+var defaultFormats = map[string]string{
+	"formats/json/page": defaultFormatJSON,
+}
+
+const defaultFormatJSON = `{{jsonify (dict "title" .Page.Title "url" .Page.URL "date" .Page.Date "summary" .Page.Summary "tags" .Page.Tags "content" .Page.Body)}}
+`
+
 const defaultHomeLayout = `<h1>{{.Site.Config.Title}}</h1>
 <p>{{.Site.Config.Description}}</p>
 {{if .Pages}}
@@ -0,0 +1,263 @@
+package template
+
+import (
+	"bytes"
+	"html/template"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/shanepadgett/canopy/internal/core"
+)
+
+func TestSafeHelpers(t *testing.T) {
+	tests := []struct {
+		name string
+		tpl  string
+		data string
+		want string
+	}{
+		{"url", `<a href="{{safeURL .}}">x</a>`, "tel:5551234", `<a href="tel:5551234">x</a>`},
+		{"url with query", `<a href="{{safeURL .}}">x</a>`, "https://example.com/?a=1&b=2", `<a href="https://example.com/?a=1&amp;b=2">x</a>`},
+		{"unsafe url", `<a href="{{.}}">x</a>`, "tel:5551234", `<a href="#ZgotmplZ">x</a>`},
+		{"css", `<div style="{{safeCSS .}}"></div>`, "color: red", `<div style="color: red"></div>`},
+		{"js", `<script>{{safeJS .}}</script>`, "track('home')", `<script>track('home')</script>`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := execute(t, tt.tpl, tt.data)
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReload(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, text string) {
+		t.Helper()
+		path := filepath.Join(dir, "layouts", name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(text), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("base.html", `{{.Content}}`)
+	write("page.html", `<p>v1 {{.Page.Title}}</p>`)
+	write("list.html", `<ul></ul>`)
+
+	e, err := NewEngine(dir)
+	if err != nil {
+		t.Fatalf("NewEngine() error = %v", err)
+	}
+	site := core.NewSite(core.DefaultConfig())
+	page := &core.Page{Title: "Hello", URL: "/hello/"}
+	render := func() string {
+		t.Helper()
+		html, err := e.RenderPage(page, site)
+		if err != nil {
+			t.Fatalf("RenderPage() error = %v", err)
+		}
+		return html
+	}
+
+	write("page.html", `<p>v2 {{.Page.Title}}</p>`)
+	if got := render(); got != "<p>v1 Hello</p>" {
+		t.Errorf("before Reload got %q", got)
+	}
+	if err := e.Reload(); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	if got := render(); got != "<p>v2 Hello</p>" {
+		t.Errorf("after Reload got %q", got)
+	}
+
+	write("page.html", `<p>{{.Page.Title</p>`)
+	if err := e.Reload(); err == nil || !strings.Contains(err.Error(), "page.html") {
+		t.Errorf("expected a parse error naming page.html, got %v", err)
+	}
+	if got := render(); got != "<p>v2 Hello</p>" {
+		t.Errorf("after a failed Reload got %q, want the previous templates", got)
+	}
+
+	// Renders and reloads may overlap; run with -race to check
+	write("page.html", `<p>v3 {{.Page.Title}}</p>`)
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if _, err := e.RenderPage(page, site); err != nil {
+				t.Errorf("RenderPage() error = %v", err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			if err := e.Reload(); err != nil {
+				t.Errorf("Reload() error = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestRenderFormat(t *testing.T) {
+	dir := t.TempDir()
+	for name, text := range map[string]string{
+		"formats/text/page.txt": `page {{.Page.Title}}`,
+		"formats/text/blog.txt": `blog {{.Page.Title}} <{{.Site.Config.Name}}>`,
+		"formats/text/note.md":  `note {{.Page.Title}}`,
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(text), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	e, err := NewEngine(dir)
+	if err != nil {
+		t.Fatalf("NewEngine() error = %v", err)
+	}
+	cfg := core.DefaultConfig()
+	cfg.Name = "A&B"
+	site := core.NewSite(cfg)
+
+	tests := []struct {
+		name string
+		page *core.Page
+		want string
+	}{
+		{"type wins", &core.Page{Title: "One", Section: "blog", Type: "note"}, "note One"},
+		{"section", &core.Page{Title: "Two", Section: "blog"}, "blog Two <A&B>"},
+		{"fallback", &core.Page{Title: "Three", Section: "docs"}, "page Three"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := e.RenderFormat("text", tt.page, site)
+			if err != nil {
+				t.Fatalf("RenderFormat() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("RenderFormat() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := e.RenderFormat("csv", &core.Page{Title: "One"}, site); err == nil || !strings.Contains(err.Error(), "formats/csv/page") {
+		t.Errorf("RenderFormat() error = %v, want a missing template error", err)
+	}
+}
+
+func execute(t *testing.T, text string, data any) string {
+	t.Helper()
+	tpl, err := template.New("test").Funcs(templateFuncs()).Parse(text)
+	if err != nil {
+		t.Fatalf("parsing template: %v", err)
+	}
+
+	var out bytes.Buffer
+	if err := tpl.Execute(&out, data); err != nil {
+		t.Fatalf("executing template: %v", err)
+	}
+	return out.String()
+}
+
+func TestShortcodeCache(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, text string) {
+		t.Helper()
+		path := filepath.Join(dir, "shortcodes", name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(text), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("note.html", `<aside>{{with index .Params "title"}}{{.}}{{end}} {{.Inner}}</aside>`)
+	write("byline.html", `<p>{{.Page.Title}}</p>`)
+
+	e, err := NewEngine(dir)
+	if err != nil {
+		t.Fatalf("NewEngine() error = %v", err)
+	}
+	site := core.NewSite(core.DefaultConfig())
+	a := &core.Page{Title: "A", URL: "/a/"}
+	b := &core.Page{Title: "B", URL: "/b/"}
+	render := func(name string, page *core.Page) string {
+		t.Helper()
+		html, err := e.RenderShortcode(name, map[string]string{"title": "Hi"}, nil, "body", false, page, site)
+		if err != nil {
+			t.Fatalf("RenderShortcode(%q) error = %v", name, err)
+		}
+		return html
+	}
+	cached := func() int {
+		e.shortcodes.mu.Lock()
+		defer e.shortcodes.mu.Unlock()
+		return len(e.shortcodes.html)
+	}
+
+	// A page-independent shortcode is shared across pages
+	if got := render("note", a); got != "<aside>Hi body</aside>" {
+		t.Errorf("note = %q", got)
+	}
+	render("note", b)
+	if n := cached(); n != 1 {
+		t.Errorf("cached %d results, want 1 shared by both pages", n)
+	}
+
+	// A shortcode reading the page is cached per page
+	if got := render("byline", a); got != "<p>A</p>" {
+		t.Errorf("byline on A = %q", got)
+	}
+	if got := render("byline", b); got != "<p>B</p>" {
+		t.Errorf("byline on B = %q", got)
+	}
+	if n := cached(); n != 3 {
+		t.Errorf("cached %d results, want 3", n)
+	}
+
+	write("note.html", `<aside class="v2">{{.Inner}}</aside>`)
+	if err := e.Reload(); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	if got := render("note", a); got != `<aside class="v2">body</aside>` {
+		t.Errorf("after Reload note = %q, want the new template", got)
+	}
+}
+
+func TestReadsPageContext(t *testing.T) {
+	tests := []struct {
+		tpl  string
+		want bool
+	}{
+		{`{{.Inner}} {{index .Params "x"}}`, false},
+		{`{{with index .Params "title"}}{{.}}{{end}}`, false},
+		{`{{range .Positional}}{{.}}{{end}}`, false},
+		{`{{.Require "id"}}{{$id := index .Params "id"}}{{$id}}`, false},
+		{`{{.Page.Title}}`, true},
+		{`{{with .Resource}}{{.URL}}{{end}}`, true},
+		{`{{range .Site.Pages}}{{.Title}}{{end}}`, true},
+		{`{{with index .Params "x"}}{{$.Page.URL}}{{end}}`, true},
+		{`{{jsonify .}}`, true},
+		{`{{with index .Params "x"}}{{else}}{{jsonify .}}{{end}}`, true},
+		{`{{template "other" .}}`, true},
+	}
+
+	for _, tt := range tests {
+		tpl := template.Must(template.New("t").Funcs(templateFuncs()).Parse(tt.tpl))
+		if got := readsPageContext(tpl.Tree.Root, false); got != tt.want {
+			t.Errorf("readsPageContext(%q) = %v, want %v", tt.tpl, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,399 @@
+package template
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/shanepadgett/canopy/internal/core"
+)
+
+// assetURL returns a template function resolving a static file path to
+// its published URL, substituting the fingerprinted name when there is
+// one and prefixing the path of a baseURL like "https://example.com/docs/":
+//
+//	<link rel="stylesheet" href="{{assetURL "css/site.css"}}">
+func assetURL(baseURL string, assets map[string]string) func(string) string {
+	base := ""
+	if u, err := url.Parse(baseURL); err == nil {
+		base = strings.TrimRight(u.Path, "/")
+	}
+	return func(path string) string {
+		path = strings.TrimPrefix(path, "/")
+		if hashed, ok := assets[path]; ok {
+			path = hashed
+		}
+		return base + "/" + path
+	}
+}
+
+// dict builds a map from alternating key/value arguments so several
+// values can be passed to a partial:
+//
+//	{{template "partials/card.html" (dict "page" . "featured" true)}}
+func dict(pairs ...any) (map[string]any, error) {
+	if len(pairs)%2 != 0 {
+		return nil, errors.New("dict: expected an even number of arguments (key/value pairs)")
+	}
+
+	m := make(map[string]any, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		key, ok := pairs[i].(string)
+		if !ok {
+			return nil, fmt.Errorf("dict: key at position %d must be a string, got %T", i, pairs[i])
+		}
+		m[key] = pairs[i+1]
+	}
+	return m, nil
+}
+
+// jsonify marshals v as JSON for a <script> element, JSON-LD included.
+// json.Marshal escapes <, >, and & so the value can't end the element:
+//
+//	<script type="application/ld+json">{{jsonify (dict "@type" "Article" "headline" .Page.Title)}}</script>
+func jsonify(v any) (template.JS, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", fmt.Errorf("jsonify: %w", err)
+	}
+	return template.JS(data), nil
+}
+
+// irregularPlurals maps singular nouns to plurals the suffix rules in
+// pluralize get wrong, or that singularize would, like "-ie" nouns.
+// Words that are the same in both forms map to themselves.
+var irregularPlurals = map[string]string{
+	"child":    "children",
+	"foot":     "feet",
+	"goose":    "geese",
+	"man":      "men",
+	"mouse":    "mice",
+	"news":     "news",
+	"person":   "people",
+	"series":   "series",
+	"species":  "species",
+	"tooth":    "teeth",
+	"woman":    "women",
+	"analysis": "analyses",
+	"index":    "indices",
+	"datum":    "data",
+	"movie":    "movies",
+	"cookie":   "cookies",
+	"pie":      "pies",
+	"tie":      "ties",
+}
+
+// pluralize returns the plural of an English noun: "category" ->
+// "categories", "box" -> "boxes", "person" -> "people".
+func pluralize(word string) string {
+	if word == "" {
+		return word
+	}
+	lower := strings.ToLower(word)
+	if plural, ok := irregularPlurals[lower]; ok {
+		return matchCase(word, plural)
+	}
+
+	switch {
+	case strings.HasSuffix(lower, "y") && len(lower) > 1 && !strings.ContainsRune("aeiou", rune(lower[len(lower)-2])):
+		return word[:len(word)-1] + matchCase(word[len(word)-1:], "ies")
+	case strings.HasSuffix(lower, "s"), strings.HasSuffix(lower, "x"), strings.HasSuffix(lower, "z"),
+		strings.HasSuffix(lower, "ch"), strings.HasSuffix(lower, "sh"):
+		return word + matchCase(word[len(word)-1:], "es")
+	}
+	return word + matchCase(word[len(word)-1:], "s")
+}
+
+// singularize reverses pluralize: "categories" -> "category", "boxes" ->
+// "box", "statuses" -> "status", "people" -> "person".
+func singularize(word string) string {
+	lower := strings.ToLower(word)
+	for singular, plural := range irregularPlurals {
+		if lower == plural {
+			return matchCase(word, singular)
+		}
+	}
+
+	switch {
+	case strings.HasSuffix(lower, "ies") && len(lower) > 3:
+		return word[:len(word)-3] + matchCase(word[len(word)-3:], "y")
+	case strings.HasSuffix(lower, "sses"), strings.HasSuffix(lower, "xes"), strings.HasSuffix(lower, "zes"),
+		strings.HasSuffix(lower, "ches"), strings.HasSuffix(lower, "shes"):
+		return word[:len(word)-2]
+	case strings.HasSuffix(lower, "uses") && len(lower) > 4 && !strings.ContainsRune("aeiou", rune(lower[len(lower)-5])):
+		// "statuses" and "buses", but not "houses" or "causes"
+		return word[:len(word)-2]
+	case strings.HasSuffix(lower, "ss"):
+		return word
+	case strings.HasSuffix(lower, "s"):
+		return word[:len(word)-1]
+	}
+	return word
+}
+
+// matchCase returns s in upper case when like is all upper case, and with
+// a capital first letter when only like's first letter is.
+func matchCase(like, s string) string {
+	switch {
+	case like == "" || s == "":
+		return s
+	case strings.ToUpper(like) == like && strings.ToLower(like) != like:
+		return strings.ToUpper(s)
+	case strings.ToUpper(like[:1]) == like[:1] && strings.ToLower(like[:1]) != like[:1]:
+		return strings.ToUpper(s[:1]) + s[1:]
+	}
+	return s
+}
+
+// plural formats a count with the matching noun form:
+//
+//	{{plural (len .Pages) "post" "posts"}}  -> "1 post", "5 posts"
+func plural(n int, singular, plural string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, singular)
+	}
+	return fmt.Sprintf("%d %s", n, plural)
+}
+
+// truncate shortens s to at most n runes, counting the "…" it ends with
+// when it cuts:
+//
+//	{{truncate 120 .Summary}}
+func truncate(n int, s string) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	if n < 1 {
+		return ""
+	}
+	return strings.TrimRightFunc(string(runes[:n-1]), unicode.IsSpace) + "…"
+}
+
+// truncateWords shortens s to its first n words, ending with "…" when it
+// cuts. Whitespace between the kept words is preserved.
+func truncateWords(n int, s string) string {
+	words := 0
+	inWord := false
+	for i, r := range s {
+		if unicode.IsSpace(r) {
+			inWord = false
+			continue
+		}
+		if !inWord {
+			if words == n {
+				return strings.TrimRightFunc(s[:i], unicode.IsSpace) + "…"
+			}
+			words++
+			inWord = true
+		}
+	}
+	return s
+}
+
+// chunk splits pages into rows of n for grid layouts; the last row holds
+// the remainder:
+//
+//	{{range chunk 3 .Pages}}<div class="row">{{range .}}...{{end}}</div>{{end}}
+func chunk(n int, pages []*core.Page) ([][]*core.Page, error) {
+	if n < 1 {
+		return nil, fmt.Errorf("chunk: size must be at least 1, got %d", n)
+	}
+	rows := make([][]*core.Page, 0, (len(pages)+n-1)/n)
+	for len(pages) > n {
+		rows = append(rows, pages[:n:n])
+		pages = pages[n:]
+	}
+	if len(pages) > 0 {
+		rows = append(rows, pages)
+	}
+	return rows, nil
+}
+
+// isActive reports whether a link URL is the current page's or one of its
+// ancestors, ignoring trailing slashes. current is a page or a URL, so
+// list layouts without a page can pass $.URL:
+//
+//	<a{{if isActive $.Page .URL}} class="active"{{end}} href="{{.URL}}">
+func isActive(current any, link string) bool {
+	switch c := current.(type) {
+	case *core.Page:
+		return c != nil && core.URLActive(c.URL, link)
+	case string:
+		return core.URLActive(c, link)
+	}
+	return false
+}
+
+// srcset builds a srcset attribute from a comma-separated list of widths,
+// resizing res to each. Widths at or past the image's own width are
+// replaced by the original, so images are never enlarged:
+//
+//	<img src="{{.URL}}" srcset="{{srcset "480,800,1200" .}}">
+func srcset(widths string, res *core.Resource) (string, error) {
+	original, err := res.Width()
+	if err != nil {
+		return "", err
+	}
+
+	var candidates []string
+	for _, field := range strings.Split(widths, ",") {
+		width, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil || width <= 0 {
+			return "", fmt.Errorf("srcset: invalid width %q", strings.TrimSpace(field))
+		}
+		if width >= original {
+			continue
+		}
+		url, err := res.Resize(strconv.Itoa(width) + "x")
+		if err != nil {
+			return "", err
+		}
+		candidates = append(candidates, fmt.Sprintf("%s %dw", url, width))
+	}
+	candidates = append(candidates, fmt.Sprintf("%s %dw", res.URL, original))
+	return strings.Join(candidates, ", "), nil
+}
+
+// PageGroup is one group from groupBy, groupByYear, or groupByMonth.
+type PageGroup struct {
+	Key   string
+	Pages []*core.Page
+}
+
+// groupBy groups pages by a front matter value, looked up like param, in
+// order of each value's first appearance:
+//
+//	{{range groupBy "section" .Pages}}<h2>{{.Key}}</h2>...{{end}}
+func groupBy(key string, pages []*core.Page) []PageGroup {
+	return groupPages(pages, func(page *core.Page) string {
+		value, _ := page.Param(key)
+		return groupKey(value)
+	})
+}
+
+// groupByYear groups pages by the year of their date, newest first.
+// Undated pages come last under an empty key.
+func groupByYear(pages []*core.Page) []PageGroup {
+	return groupByDate(pages, "2006")
+}
+
+// groupByMonth groups pages by year and month, "2026-01", newest first.
+// Undated pages come last under an empty key.
+func groupByMonth(pages []*core.Page) []PageGroup {
+	return groupByDate(pages, "2006-01")
+}
+
+func groupByDate(pages []*core.Page, layout string) []PageGroup {
+	groups := groupPages(pages, func(page *core.Page) string {
+		if page.Date.IsZero() {
+			return ""
+		}
+		return page.Date.Format(layout)
+	})
+	// The layouts sort lexically, so a descending key sort is newest
+	// first; "" sorts last
+	sort.SliceStable(groups, func(i, j int) bool {
+		return groups[i].Key > groups[j].Key
+	})
+	return groups
+}
+
+// groupPages buckets pages by key, keeping first-appearance order of keys
+// and page order within each group.
+func groupPages(pages []*core.Page, key func(*core.Page) string) []PageGroup {
+	var groups []PageGroup
+	index := make(map[string]int)
+	for _, page := range pages {
+		k := key(page)
+		i, ok := index[k]
+		if !ok {
+			i = len(groups)
+			index[k] = i
+			groups = append(groups, PageGroup{Key: k})
+		}
+		groups[i].Pages = append(groups[i].Pages, page)
+	}
+	return groups
+}
+
+// groupKey formats a front matter value as a group key.
+func groupKey(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case time.Time:
+		if v.IsZero() {
+			return ""
+		}
+		return v.Format("2006-01-02")
+	case []string:
+		return strings.Join(v, ", ")
+	}
+	return fmt.Sprint(value)
+}
+
+// humanizeDuration describes t relative to now, e.g. "3 days ago" or "in 2 hours".
+func humanizeDuration(t, now time.Time) string {
+	d := now.Sub(t)
+	future := d < 0
+	if future {
+		d = -d
+	}
+
+	if d < 45*time.Second {
+		return "just now"
+	}
+
+	units := []struct {
+		name string
+		size time.Duration
+	}{
+		{"year", 365 * 24 * time.Hour},
+		{"month", 30 * 24 * time.Hour},
+		{"week", 7 * 24 * time.Hour},
+		{"day", 24 * time.Hour},
+		{"hour", time.Hour},
+		{"minute", time.Minute},
+	}
+
+	// Use the largest unit d has reached, rounding within it; from 45
+	// seconds to a minute, that's the smallest
+	unit := units[len(units)-1]
+	for _, u := range units {
+		if d >= u.size {
+			unit = u
+			break
+		}
+	}
+	n := max(int((d+unit.size/2)/unit.size), 1)
+	phrase := fmt.Sprintf("%d %s", n, unit.name)
+	if n != 1 {
+		phrase += "s"
+	}
+
+	if future {
+		return "in " + phrase
+	}
+	return phrase + " ago"
+}
+
+// humanizeTime renders t relatively, falling back to an absolute date in
+// the given layout once t is more than maxDays away from now.
+func humanizeTime(maxDays int, layout string, t, now time.Time) string {
+	d := now.Sub(t)
+	if d < 0 {
+		d = -d
+	}
+	if d > time.Duration(maxDays)*24*time.Hour {
+		return t.Format(layout)
+	}
+	return humanizeDuration(t, now)
+}
@@ -0,0 +1,284 @@
+package template
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shanepadgett/canopy/internal/core"
+)
+
+func TestHumanizeDuration(t *testing.T) {
+	now := time.Date(2026, 3, 15, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		t    time.Time
+		want string
+	}{
+		{"just now", now.Add(-10 * time.Second), "just now"},
+		{"one minute", now.Add(-time.Minute), "1 minute ago"},
+		{"minutes", now.Add(-5 * time.Minute), "5 minutes ago"},
+		{"hours", now.Add(-3 * time.Hour), "3 hours ago"},
+		{"days", now.Add(-3 * 24 * time.Hour), "3 days ago"},
+		{"weeks", now.Add(-14 * 24 * time.Hour), "2 weeks ago"},
+		{"months", now.Add(-65 * 24 * time.Hour), "2 months ago"},
+		{"years", now.Add(-800 * 24 * time.Hour), "2 years ago"},
+		{"future", now.Add(2 * time.Hour), "in 2 hours"},
+		{"under a minute", now.Add(-50 * time.Second), "1 minute ago"},
+		{"half hour", now.Add(-30 * time.Minute), "30 minutes ago"},
+		{"half day", now.Add(-12 * time.Hour), "12 hours ago"},
+		{"half week", now.Add(-84 * time.Hour), "4 days ago"},
+		{"16 days", now.Add(-16 * 24 * time.Hour), "2 weeks ago"},
+		{"half year", now.Add(-183 * 24 * time.Hour), "6 months ago"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := humanizeDuration(tt.t, now); got != tt.want {
+				t.Errorf("humanizeDuration() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHumanizeTimeThreshold(t *testing.T) {
+	now := time.Date(2026, 3, 15, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		t    time.Time
+		want string
+	}{
+		{"within threshold", now.Add(-2 * 24 * time.Hour), "2 days ago"},
+		{"beyond threshold", now.Add(-40 * 24 * time.Hour), "Feb 3, 2026"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := humanizeTime(30, "Jan 2, 2006", tt.t, now); got != tt.want {
+				t.Errorf("humanizeTime() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDict(t *testing.T) {
+	m, err := dict("page", "home", "featured", true)
+	if err != nil {
+		t.Fatalf("dict() error = %v", err)
+	}
+	if m["page"] != "home" || m["featured"] != true {
+		t.Errorf("dict() = %v", m)
+	}
+
+	if _, err := dict("page"); err == nil {
+		t.Error("expected error for odd number of arguments")
+	}
+	if _, err := dict(1, "x"); err == nil {
+		t.Error("expected error for non-string key")
+	}
+}
+
+func TestDictPartial(t *testing.T) {
+	got := execute(t, `{{define "card"}}{{.title}}:{{.featured}}{{end}}{{template "card" (dict "title" . "featured" true)}}`, "Hello")
+	if got != "Hello:true" {
+		t.Errorf("got %q, want %q", got, "Hello:true")
+	}
+}
+
+func TestParam(t *testing.T) {
+	page := &core.Page{
+		Title:  "Hello",
+		Params: map[string]any{"meta": map[string]any{"reviewer": "Sam"}},
+	}
+
+	tests := []struct {
+		tpl  string
+		want string
+	}{
+		{`{{param . "meta.reviewer"}}`, "Sam"},
+		{`{{param . "title"}}`, "Hello"},
+		{`[{{param . "meta.missing"}}]`, "[]"},
+		{`[{{param . "meta.reviewer.name"}}]`, "[]"},
+	}
+	for _, tt := range tests {
+		if got := execute(t, tt.tpl, page); got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.tpl, got, tt.want)
+		}
+	}
+}
+
+func TestJsonify(t *testing.T) {
+	tests := []struct {
+		name string
+		tpl  string
+		want string
+	}{
+		{"json-ld", `<script type="application/ld+json">{{jsonify .}}</script>`, `<script type="application/ld+json">{"headline":"\u003c/script\u003eHi \u0026 bye","tags":["a","b"]}</script>`},
+		{"script", `<script>var config = {{jsonify .}};</script>`, `<script>var config = {"headline":"\u003c/script\u003eHi \u0026 bye","tags":["a","b"]};</script>`},
+	}
+	data := map[string]any{"headline": "</script>Hi & bye", "tags": []string{"a", "b"}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := execute(t, tt.tpl, data); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := jsonify(func() {}); err == nil {
+		t.Error("expected an error for a value JSON can't encode")
+	}
+}
+
+func TestIsActive(t *testing.T) {
+	tests := []struct {
+		current string
+		link    string
+		want    bool
+	}{
+		{"/blog/", "/blog/", true},
+		{"/blog/", "/blog", true},
+		{"/blog", "/blog/", true},
+		{"/blog/post/", "/blog/", true},
+		{"/blogroll/", "/blog/", false},
+		{"/blog/", "/blog/post/", false},
+		{"/about/", "/", false},
+		{"/", "/", true},
+		{"", "/", false},
+	}
+
+	for _, tt := range tests {
+		if got := isActive(tt.current, tt.link); got != tt.want {
+			t.Errorf("isActive(%q, %q) = %v, want %v", tt.current, tt.link, got, tt.want)
+		}
+	}
+
+	data := map[string]any{"Page": &core.Page{URL: "/docs/install/"}, "Missing": (*core.Page)(nil)}
+	tpl := `{{if isActive .Page "/docs"}}docs{{end}} {{if isActive .Page "/blog/"}}blog{{end}} {{if isActive .Missing "/"}}home{{end}}`
+	if got := execute(t, tpl, data); got != "docs  " {
+		t.Errorf("got %q, want %q", got, "docs  ")
+	}
+}
+
+func TestInflection(t *testing.T) {
+	tests := []struct {
+		singular, plural string
+	}{
+		{"post", "posts"},
+		{"category", "categories"},
+		{"movie", "movies"},
+		{"cookie", "cookies"},
+		{"pie", "pies"},
+		{"tie", "ties"},
+		{"day", "days"},
+		{"box", "boxes"},
+		{"class", "classes"},
+		{"match", "matches"},
+		{"dish", "dishes"},
+		{"status", "statuses"},
+		{"bus", "buses"},
+		{"house", "houses"},
+		{"release", "releases"},
+		{"person", "people"},
+		{"series", "series"},
+		{"Category", "Categories"},
+		{"TAG", "TAGS"},
+	}
+
+	for _, tt := range tests {
+		if got := pluralize(tt.singular); got != tt.plural {
+			t.Errorf("pluralize(%q) = %q, want %q", tt.singular, got, tt.plural)
+		}
+		if got := singularize(tt.plural); got != tt.singular {
+			t.Errorf("singularize(%q) = %q, want %q", tt.plural, got, tt.singular)
+		}
+	}
+}
+
+func TestPlural(t *testing.T) {
+	got := execute(t, `{{plural 1 "post" "posts"}}, {{plural 0 "post" "posts"}}, {{plural 5 "post" "posts"}}`, nil)
+	if want := "1 post, 0 posts, 5 posts"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		name string
+		fn   func(int, string) string
+		n    int
+		in   string
+		want string
+	}{
+		{"short", truncate, 10, "hello", "hello"},
+		{"exact", truncate, 5, "hello", "hello"},
+		{"cut", truncate, 5, "hello world", "hell…"},
+		{"trailing space", truncate, 7, "hello world", "hello…"},
+		{"multibyte", truncate, 3, "héllo", "hé…"},
+		{"cjk", truncate, 3, "日本語です", "日本…"},
+		{"zero", truncate, 0, "hello", ""},
+		{"words short", truncateWords, 5, "one two three", "one two three"},
+		{"words cut", truncateWords, 2, "one two three", "one two…"},
+		{"words spacing", truncateWords, 2, "one  two\nthree four", "one  two…"},
+		{"words trailing space", truncateWords, 3, "one two three  ", "one two three  "},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.fn(tt.n, tt.in); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChunk(t *testing.T) {
+	pages := make([]*core.Page, 7)
+	for i := range pages {
+		pages[i] = &core.Page{Title: string(rune('a' + i))}
+	}
+
+	got := execute(t, `{{range chunk 3 .}}[{{range .}}{{.Title}}{{end}}]{{end}}`, pages)
+	if want := "[abc][def][g]"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got := execute(t, `{{range chunk 3 .}}x{{end}}`, []*core.Page{}); got != "" {
+		t.Errorf("expected no rows for no pages, got %q", got)
+	}
+	if _, err := chunk(0, pages); err == nil {
+		t.Error("expected an error for a zero chunk size")
+	}
+}
+
+func TestGroupBy(t *testing.T) {
+	date := func(year int, month time.Month) time.Time {
+		return time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+	}
+	pages := []*core.Page{
+		{Title: "a", Section: "blog", Date: date(2025, 3)},
+		{Title: "b", Section: "docs", Date: date(2026, 1)},
+		{Title: "c", Section: "blog", Date: date(2025, 3)},
+		{Title: "d", Section: "blog"},
+		{Title: "e", Section: "docs", Date: date(2026, 2), Params: map[string]any{"level": "intro"}},
+	}
+
+	tests := []struct {
+		name string
+		tpl  string
+		want string
+	}{
+		{"section", `{{range groupBy "section" .}}{{.Key}}:{{range .Pages}}{{.Title}}{{end}} {{end}}`, "blog:acd docs:be "},
+		{"param", `{{range groupBy "level" .}}[{{.Key}}]{{len .Pages}} {{end}}`, "[]4 [intro]1 "},
+		{"year", `{{range groupByYear .}}[{{.Key}}]{{range .Pages}}{{.Title}}{{end}} {{end}}`, "[2026]be [2025]ac []d "},
+		{"month", `{{range groupByMonth .}}[{{.Key}}]{{range .Pages}}{{.Title}}{{end}} {{end}}`, "[2026-02]e [2026-01]b [2025-03]ac []d "},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := execute(t, tt.tpl, pages); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -4,19 +4,53 @@ import (
 	"bytes"
 	"fmt"
 	"html/template"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
 
 	"github.com/shanepadgett/canopy/internal/core"
 )
 
 type shortcodeData struct {
-	Name   string
-	Params map[string]string
-	Inner  any
-	Page   *core.Page
+	Name       string
+	Params     map[string]string
+	Positional []string
+	Inner      any
+	Page       *core.Page
+	Site       *core.Site
 }
 
-// RenderShortcode executes a shortcode template with context.
-func (e *Engine) RenderShortcode(name string, params map[string]string, inner string, innerIsHTML bool, page *core.Page) (string, error) {
+// Resource returns the page resource named by the src param, so
+// shortcodes can process bundled images, or nil when there is none.
+func (d shortcodeData) Resource() *core.Resource {
+	if d.Page == nil {
+		return nil
+	}
+	return d.Page.Resource(strings.TrimPrefix(d.Params["src"], "./"))
+}
+
+// Require fails the shortcode when any of the named params is missing,
+// so it's reported as a warning instead of rendering a broken embed:
+//
+//	{{.Require "user" "id"}}
+func (d shortcodeData) Require(names ...string) (string, error) {
+	for _, name := range names {
+		if d.Params[name] == "" {
+			return "", fmt.Errorf("missing required param %q", name)
+		}
+	}
+	return "", nil
+}
+
+// RenderShortcode executes a shortcode template with context. Results are
+// cached by name, params, and inner content, plus the page for templates
+// that read .Page, .Resource, or .Site, so repeated shortcodes render once.
+func (e *Engine) RenderShortcode(name string, params map[string]string, positional []string, inner string, innerIsHTML bool, page *core.Page, site *core.Site) (string, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
 	tplName := "shortcodes/" + name + ".html"
 	tpl := e.templates.Lookup(tplName)
 	if tpl == nil {
@@ -27,16 +61,25 @@ func (e *Engine) RenderShortcode(name string, params map[string]string, inner st
 		params = map[string]string{}
 	}
 
+	key, cacheable := e.shortcodes.key(tpl, name, params, positional, inner, innerIsHTML, page)
+	if cacheable {
+		if html, ok := e.shortcodes.get(key); ok {
+			return html, nil
+		}
+	}
+
 	var innerValue any = inner
 	if innerIsHTML {
 		innerValue = template.HTML(inner)
 	}
 
 	data := shortcodeData{
-		Name:   name,
-		Params: params,
-		Inner:  innerValue,
-		Page:   page,
+		Name:       name,
+		Params:     params,
+		Positional: positional,
+		Inner:      innerValue,
+		Page:       page,
+		Site:       site,
 	}
 
 	var out bytes.Buffer
@@ -44,9 +87,20 @@ func (e *Engine) RenderShortcode(name string, params map[string]string, inner st
 		return "", fmt.Errorf("executing shortcode %q: %w", name, err)
 	}
 
+	if cacheable {
+		e.shortcodes.put(key, out.String())
+	}
 	return out.String(), nil
 }
 
+// HasShortcode reports whether a shortcode template named name exists, so
+// the Markdown renderer lets site templates override built-in shortcodes.
+func (e *Engine) HasShortcode(name string) bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.templates.Lookup("shortcodes/"+name+".html") != nil
+}
+
 func (e *Engine) loadDefaultShortcodes() error {
 	for name, content := range defaultShortcodes {
 		if e.templates.Lookup(name) != nil {
@@ -55,11 +109,58 @@ func (e *Engine) loadDefaultShortcodes() error {
 		if _, err := e.templates.New(name).Parse(content); err != nil {
 			return fmt.Errorf("parsing default shortcode %s: %w", name, err)
 		}
+		e.declareShortcodeAssets(name, content)
 	}
 
 	return nil
 }
 
+// assetsComment matches the comment opening a shortcode template that
+// declares the stylesheets and scripts it needs:
+//
+//	{{/* assets: css/tabs.css js/tabs.js */}}
+var assetsComment = regexp.MustCompile(`^\s*\{\{-?\s*/\*\s*assets:([^*]*)\*/\s*-?\}\}`)
+
+// declareShortcodeAssets records the assets a shortcode template declares.
+func (e *Engine) declareShortcodeAssets(name, content string) {
+	shortcode, ok := strings.CutPrefix(name, "shortcodes/")
+	if !ok {
+		return
+	}
+	m := assetsComment.FindStringSubmatch(content)
+	if m == nil {
+		return
+	}
+	e.shortcodeAssets[strings.TrimSuffix(shortcode, ".html")] = strings.FieldsFunc(m[1], func(r rune) bool {
+		return r == ',' || unicode.IsSpace(r)
+	})
+}
+
+// ShortcodeAssets collects the assets declared by the named shortcodes,
+// split into stylesheets (.css) and scripts (.js) in first-use order
+// without duplicates. Other files are ignored.
+func (e *Engine) ShortcodeAssets(names []string) core.PageAssets {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	var assets core.PageAssets
+	for _, name := range names {
+		for _, asset := range e.shortcodeAssets[name] {
+			switch strings.ToLower(path.Ext(asset)) {
+			case ".css":
+				if !slices.Contains(assets.CSS, asset) {
+					assets.CSS = append(assets.CSS, asset)
+				}
+			case ".js":
+				if !slices.Contains(assets.JS, asset) {
+					assets.JS = append(assets.JS, asset)
+				}
+			}
+		}
+	}
+	return assets
+}
+
 var defaultShortcodes = map[string]string{
 	"shortcodes/callout.html":       defaultShortcodeCallout,
 	"shortcodes/figure.html":        defaultShortcodeFigure,
@@ -68,6 +169,10 @@ var defaultShortcodes = map[string]string{
 	"shortcodes/key-takeaways.html": defaultShortcodeKeyTakeaways,
 	"shortcodes/prereqs.html":       defaultShortcodePrereqs,
 	"shortcodes/code-tabs.html":     defaultShortcodeCodeTabs,
+	"shortcodes/details.html":       defaultShortcodeDetails,
+	"shortcodes/gist.html":          defaultShortcodeGist,
+	"shortcodes/codepen.html":       defaultShortcodeCodePen,
+	"shortcodes/tweet.html":         defaultShortcodeTweet,
 }
 
 const defaultShortcodeCallout = `<div class="shortcode-callout{{with index .Params "type"}} shortcode-callout-{{.}}{{end}}">
@@ -76,8 +181,15 @@ const defaultShortcodeCallout = `<div class="shortcode-callout{{with index .Para
 </div>
 `
 
+// defaultShortcodeFigure serves bundled images responsively: a srcset of
+// the widths param (default 480, 800, and 1200 pixels, never enlarged)
+// with sizes from the sizes param, or the width param's display width.
 const defaultShortcodeFigure = `<figure class="shortcode-figure">
+  {{with .Resource}}{{$width := index $.Params "width"}}
+  <img src="{{.URL}}" srcset="{{srcset (or (index $.Params "widths") "480,800,1200") .}}" sizes="{{with index $.Params "sizes"}}{{.}}{{else}}{{with $width}}(max-width: {{.}}px) 100vw, {{.}}px{{else}}100vw{{end}}{{end}}"{{with $width}} width="{{.}}"{{end}} alt="{{index $.Params "alt"}}">
+  {{else}}
   <img src="{{index .Params "src"}}" alt="{{index .Params "alt"}}">
+  {{end}}
   {{with index .Params "caption"}}<figcaption>{{.}}</figcaption>{{end}}
 </figure>
 `
@@ -87,6 +199,27 @@ const defaultShortcodeYouTube = `<div class="shortcode-youtube">
 </div>
 `
 
+// defaultShortcodeGist embeds a GitHub gist, or only its file param.
+const defaultShortcodeGist = `{{.Require "user" "id"}}<div class="shortcode-gist">
+  <script src="https://gist.github.com/{{index .Params "user"}}/{{index .Params "id"}}.js{{with index .Params "file"}}?file={{.}}{{end}}"></script>
+</div>
+`
+
+// defaultShortcodeCodePen embeds a pen showing the tab param (default
+// result) at the height param (default 300 pixels).
+const defaultShortcodeCodePen = `{{.Require "id"}}<div class="shortcode-codepen">
+  <iframe src="https://codepen.io/{{with index .Params "user"}}{{.}}{{else}}anon{{end}}/embed/{{index .Params "id"}}?default-tab={{with index .Params "tab"}}{{.}}{{else}}result{{end}}" height="{{with index .Params "height"}}{{.}}{{else}}300{{end}}" style="width: 100%;" title="{{with index .Params "title"}}{{.}}{{else}}CodePen embed{{end}}" loading="lazy" allowfullscreen></iframe>
+</div>
+`
+
+// defaultShortcodeTweet renders the blockquote the X widget script turns
+// into an embed, linking the url param or the post of user and id. The
+// base layout loads the script on pages that use it.
+const defaultShortcodeTweet = `{{$url := index .Params "url"}}{{if not $url}}{{.Require "user" "id"}}{{$url = printf "https://twitter.com/%s/status/%s" (index .Params "user") (index .Params "id")}}{{end}}<blockquote class="twitter-tweet">
+  <a href="{{$url}}">{{with index .Params "text"}}{{.}}{{else}}View post on X{{end}}</a>
+</blockquote>
+`
+
 const defaultShortcodeTOC = `<nav class="shortcode-toc">
   {{if .Page}}
   <ol>
@@ -114,3 +247,11 @@ const defaultShortcodeCodeTabs = `<div class="shortcode-code-tabs">
   {{safeHTML .Inner}}
 </div>
 `
+
+// defaultShortcodeDetails collapses its inner content under the summary
+// param, starting expanded when open="true".
+const defaultShortcodeDetails = `<details class="shortcode-details"{{if eq (index .Params "open") "true"}} open{{end}}>
+  <summary>{{with index .Params "summary"}}{{.}}{{else}}Details{{end}}</summary>
+  <div class="shortcode-details-body">{{.Inner}}</div>
+</details>
+`
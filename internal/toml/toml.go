@@ -0,0 +1,562 @@
+// Package toml decodes TOML documents into generic maps. It covers the
+// parts of TOML used for site configuration: key/value pairs with bare,
+// quoted, and dotted keys, tables, arrays of tables, basic and literal
+// strings (including multi-line forms), integers, floats, booleans,
+// arrays, and inline tables. Date-time values are returned as strings.
+package toml
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Parse decodes a TOML document.
+func Parse(data []byte) (map[string]any, error) {
+	p := &parser{src: strings.ReplaceAll(string(data), "\r\n", "\n"), line: 1}
+	root := map[string]any{}
+	current := root
+
+	for {
+		p.skipSpaceAndComments()
+		if p.eof() {
+			return root, nil
+		}
+
+		var err error
+		if p.peek() == '[' {
+			current, err = p.parseTableHeader(root)
+		} else {
+			err = p.parseKeyValue(current)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", p.line, err)
+		}
+
+		if err := p.expectLineEnd(); err != nil {
+			return nil, fmt.Errorf("line %d: %w", p.line, err)
+		}
+	}
+}
+
+type parser struct {
+	src  string
+	pos  int
+	line int
+}
+
+func (p *parser) eof() bool {
+	return p.pos >= len(p.src)
+}
+
+func (p *parser) peek() byte {
+	if p.eof() {
+		return 0
+	}
+	return p.src[p.pos]
+}
+
+func (p *parser) hasPrefix(s string) bool {
+	return strings.HasPrefix(p.src[p.pos:], s)
+}
+
+func (p *parser) advance(n int) {
+	for i := 0; i < n && !p.eof(); i++ {
+		if p.src[p.pos] == '\n' {
+			p.line++
+		}
+		p.pos++
+	}
+}
+
+// skipSpace skips spaces and tabs on the current line.
+func (p *parser) skipSpace() {
+	for !p.eof() && (p.peek() == ' ' || p.peek() == '\t') {
+		p.pos++
+	}
+}
+
+// skipSpaceAndComments skips whitespace, newlines, and comments.
+func (p *parser) skipSpaceAndComments() {
+	for !p.eof() {
+		switch p.peek() {
+		case ' ', '\t', '\n', '\r':
+			p.advance(1)
+		case '#':
+			for !p.eof() && p.peek() != '\n' {
+				p.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+// expectLineEnd consumes trailing whitespace and an optional comment and
+// requires a newline or end of input.
+func (p *parser) expectLineEnd() error {
+	p.skipSpace()
+	if p.peek() == '#' {
+		for !p.eof() && p.peek() != '\n' {
+			p.pos++
+		}
+	}
+	if p.peek() == '\r' {
+		p.pos++
+	}
+	if p.eof() {
+		return nil
+	}
+	if p.peek() != '\n' {
+		return fmt.Errorf("unexpected %q after value", p.rest())
+	}
+	p.advance(1)
+	return nil
+}
+
+// rest returns the remainder of the current line for error messages.
+func (p *parser) rest() string {
+	end := strings.IndexByte(p.src[p.pos:], '\n')
+	if end == -1 {
+		return p.src[p.pos:]
+	}
+	return p.src[p.pos : p.pos+end]
+}
+
+func (p *parser) parseTableHeader(root map[string]any) (map[string]any, error) {
+	array := p.hasPrefix("[[")
+	if array {
+		p.advance(2)
+	} else {
+		p.advance(1)
+	}
+
+	p.skipSpace()
+	keys, err := p.parseKey()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+
+	closing := "]"
+	if array {
+		closing = "]]"
+	}
+	if !p.hasPrefix(closing) {
+		return nil, fmt.Errorf("expected %s after table name", closing)
+	}
+	p.advance(len(closing))
+
+	parent, err := descend(root, keys[:len(keys)-1])
+	if err != nil {
+		return nil, err
+	}
+	last := keys[len(keys)-1]
+
+	if array {
+		table := map[string]any{}
+		switch existing := parent[last].(type) {
+		case nil:
+			parent[last] = []any{table}
+		case []any:
+			parent[last] = append(existing, table)
+		default:
+			return nil, fmt.Errorf("key %q is already defined", strings.Join(keys, "."))
+		}
+		return table, nil
+	}
+
+	switch existing := parent[last].(type) {
+	case nil:
+		table := map[string]any{}
+		parent[last] = table
+		return table, nil
+	case map[string]any:
+		return existing, nil
+	default:
+		return nil, fmt.Errorf("key %q is already defined", strings.Join(keys, "."))
+	}
+}
+
+// descend walks dotted keys from table, creating intermediate tables. An
+// array of tables resolves to its most recent element.
+func descend(table map[string]any, keys []string) (map[string]any, error) {
+	for i, key := range keys {
+		switch next := table[key].(type) {
+		case nil:
+			child := map[string]any{}
+			table[key] = child
+			table = child
+		case map[string]any:
+			table = next
+		case []any:
+			last, ok := lastTable(next)
+			if !ok {
+				return nil, fmt.Errorf("key %q is not a table", strings.Join(keys[:i+1], "."))
+			}
+			table = last
+		default:
+			return nil, fmt.Errorf("key %q is not a table", strings.Join(keys[:i+1], "."))
+		}
+	}
+	return table, nil
+}
+
+func lastTable(items []any) (map[string]any, bool) {
+	if len(items) == 0 {
+		return nil, false
+	}
+	table, ok := items[len(items)-1].(map[string]any)
+	return table, ok
+}
+
+func (p *parser) parseKeyValue(table map[string]any) error {
+	keys, err := p.parseKey()
+	if err != nil {
+		return err
+	}
+
+	p.skipSpace()
+	if p.peek() != '=' {
+		return fmt.Errorf("expected = after key %q", strings.Join(keys, "."))
+	}
+	p.advance(1)
+	p.skipSpace()
+
+	value, err := p.parseValue()
+	if err != nil {
+		return err
+	}
+
+	parent, err := descend(table, keys[:len(keys)-1])
+	if err != nil {
+		return err
+	}
+	last := keys[len(keys)-1]
+	if _, exists := parent[last]; exists {
+		return fmt.Errorf("duplicate key %q", strings.Join(keys, "."))
+	}
+	parent[last] = value
+	return nil
+}
+
+// parseKey parses a bare, quoted, or dotted key.
+func (p *parser) parseKey() ([]string, error) {
+	var keys []string
+	for {
+		p.skipSpace()
+		var key string
+		switch c := p.peek(); {
+		case c == '"':
+			s, err := p.parseBasicString()
+			if err != nil {
+				return nil, err
+			}
+			key = s
+		case c == '\'':
+			s, err := p.parseLiteralString()
+			if err != nil {
+				return nil, err
+			}
+			key = s
+		default:
+			start := p.pos
+			for !p.eof() && isBareKeyChar(p.peek()) {
+				p.pos++
+			}
+			if start == p.pos {
+				return nil, fmt.Errorf("expected key, got %q", p.rest())
+			}
+			key = p.src[start:p.pos]
+		}
+		keys = append(keys, key)
+
+		p.skipSpace()
+		if p.peek() != '.' {
+			return keys, nil
+		}
+		p.advance(1)
+	}
+}
+
+func isBareKeyChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-'
+}
+
+func (p *parser) parseValue() (any, error) {
+	switch c := p.peek(); {
+	case p.hasPrefix(`"""`):
+		return p.parseMultilineBasicString()
+	case p.hasPrefix("'''"):
+		return p.parseMultilineLiteralString()
+	case c == '"':
+		return p.parseBasicString()
+	case c == '\'':
+		return p.parseLiteralString()
+	case c == '[':
+		return p.parseArray()
+	case c == '{':
+		return p.parseInlineTable()
+	case p.hasPrefix("true"):
+		p.advance(4)
+		return true, nil
+	case p.hasPrefix("false"):
+		p.advance(5)
+		return false, nil
+	case c == 0:
+		return nil, fmt.Errorf("missing value")
+	default:
+		return p.parseScalar()
+	}
+}
+
+// parseScalar parses numbers and date-times.
+func (p *parser) parseScalar() (any, error) {
+	start := p.pos
+	for !p.eof() {
+		c := p.peek()
+		if c == ',' || c == ']' || c == '}' || c == '#' || c == '\n' || c == '\r' {
+			break
+		}
+		// A space is allowed between a date and time
+		if c == ' ' && !(isDateTime(p.src[start:p.pos]) && p.pos+1 < len(p.src) && isDigit(p.src[p.pos+1])) {
+			break
+		}
+		p.pos++
+	}
+
+	raw := strings.TrimSpace(p.src[start:p.pos])
+	switch {
+	case raw == "":
+		return nil, fmt.Errorf("missing value")
+	case isDateTime(raw):
+		return raw, nil
+	case raw == "inf" || raw == "+inf" || raw == "-inf" || raw == "nan" || raw == "+nan" || raw == "-nan":
+		return nil, fmt.Errorf("unsupported float %q", raw)
+	}
+
+	clean := strings.ReplaceAll(raw, "_", "")
+	if strings.HasPrefix(clean, "0x") || strings.HasPrefix(clean, "0o") || strings.HasPrefix(clean, "0b") {
+		if i, err := strconv.ParseInt(clean, 0, 64); err == nil {
+			return i, nil
+		}
+	}
+	if i, err := strconv.ParseInt(clean, 10, 64); err == nil {
+		return i, nil
+	}
+	if f, err := strconv.ParseFloat(clean, 64); err == nil {
+		return f, nil
+	}
+	return nil, fmt.Errorf("invalid value %q", raw)
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// isDateTime reports whether s looks like a TOML date or time.
+func isDateTime(s string) bool {
+	if len(s) >= 10 && isDigit(s[0]) && s[4] == '-' && s[7] == '-' {
+		return true
+	}
+	return len(s) >= 8 && isDigit(s[0]) && s[2] == ':' && s[5] == ':'
+}
+
+func (p *parser) parseBasicString() (string, error) {
+	p.advance(1)
+	var b strings.Builder
+	for {
+		if p.eof() || p.peek() == '\n' {
+			return "", fmt.Errorf("unterminated string")
+		}
+		c := p.peek()
+		switch c {
+		case '"':
+			p.advance(1)
+			return b.String(), nil
+		case '\\':
+			if err := p.parseEscape(&b); err != nil {
+				return "", err
+			}
+		default:
+			b.WriteByte(c)
+			p.advance(1)
+		}
+	}
+}
+
+func (p *parser) parseMultilineBasicString() (string, error) {
+	p.advance(3)
+	if p.peek() == '\n' {
+		p.advance(1)
+	}
+
+	var b strings.Builder
+	for {
+		if p.eof() {
+			return "", fmt.Errorf("unterminated multi-line string")
+		}
+		if p.hasPrefix(`"""`) {
+			// Up to two quotes may directly precede the closing delimiter
+			for p.hasPrefix(`""""`) {
+				b.WriteByte('"')
+				p.advance(1)
+			}
+			p.advance(3)
+			return b.String(), nil
+		}
+
+		c := p.peek()
+		if c != '\\' {
+			b.WriteByte(c)
+			p.advance(1)
+			continue
+		}
+
+		// A backslash at the end of a line trims the newline and any
+		// whitespace that follows.
+		rest := strings.TrimLeft(p.src[p.pos+1:], " \t")
+		if strings.HasPrefix(rest, "\n") {
+			p.advance(1)
+			for !p.eof() && strings.ContainsRune(" \t\n\r", rune(p.peek())) {
+				p.advance(1)
+			}
+			continue
+		}
+		if err := p.parseEscape(&b); err != nil {
+			return "", err
+		}
+	}
+}
+
+func (p *parser) parseEscape(b *strings.Builder) error {
+	p.advance(1)
+	if p.eof() {
+		return fmt.Errorf("unterminated escape sequence")
+	}
+	c := p.peek()
+	p.advance(1)
+
+	switch c {
+	case 'b':
+		b.WriteByte('\b')
+	case 't':
+		b.WriteByte('\t')
+	case 'n':
+		b.WriteByte('\n')
+	case 'f':
+		b.WriteByte('\f')
+	case 'r':
+		b.WriteByte('\r')
+	case 'e':
+		b.WriteByte(0x1b)
+	case '"':
+		b.WriteByte('"')
+	case '\\':
+		b.WriteByte('\\')
+	case 'u', 'U':
+		size := 4
+		if c == 'U' {
+			size = 8
+		}
+		if p.pos+size > len(p.src) {
+			return fmt.Errorf("invalid unicode escape")
+		}
+		code, err := strconv.ParseUint(p.src[p.pos:p.pos+size], 16, 32)
+		if err != nil || !utf8.ValidRune(rune(code)) {
+			return fmt.Errorf("invalid unicode escape \\%c%s", c, p.src[p.pos:p.pos+size])
+		}
+		b.WriteRune(rune(code))
+		p.advance(size)
+	default:
+		return fmt.Errorf("invalid escape sequence \\%c", c)
+	}
+	return nil
+}
+
+func (p *parser) parseLiteralString() (string, error) {
+	p.advance(1)
+	end := strings.IndexAny(p.src[p.pos:], "'\n")
+	if end == -1 || p.src[p.pos+end] != '\'' {
+		return "", fmt.Errorf("unterminated string")
+	}
+	s := p.src[p.pos : p.pos+end]
+	p.advance(end + 1)
+	return s, nil
+}
+
+func (p *parser) parseMultilineLiteralString() (string, error) {
+	p.advance(3)
+	if p.peek() == '\n' {
+		p.advance(1)
+	}
+
+	end := strings.Index(p.src[p.pos:], "'''")
+	if end == -1 {
+		return "", fmt.Errorf("unterminated multi-line string")
+	}
+	// Up to two quotes may directly precede the closing delimiter
+	for p.pos+end+3 < len(p.src) && p.src[p.pos+end+3] == '\'' {
+		end++
+	}
+	s := p.src[p.pos : p.pos+end]
+	p.advance(end + 3)
+	return s, nil
+}
+
+func (p *parser) parseArray() ([]any, error) {
+	p.advance(1)
+	items := []any{}
+	for {
+		p.skipSpaceAndComments()
+		if p.peek() == ']' {
+			p.advance(1)
+			return items, nil
+		}
+
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, value)
+
+		p.skipSpaceAndComments()
+		switch p.peek() {
+		case ',':
+			p.advance(1)
+		case ']':
+		default:
+			return nil, fmt.Errorf("expected , or ] in array")
+		}
+	}
+}
+
+func (p *parser) parseInlineTable() (map[string]any, error) {
+	p.advance(1)
+	table := map[string]any{}
+
+	p.skipSpace()
+	if p.peek() == '}' {
+		p.advance(1)
+		return table, nil
+	}
+
+	for {
+		p.skipSpace()
+		if err := p.parseKeyValue(table); err != nil {
+			return nil, err
+		}
+
+		p.skipSpace()
+		switch p.peek() {
+		case ',':
+			p.advance(1)
+		case '}':
+			p.advance(1)
+			return table, nil
+		default:
+			return nil, fmt.Errorf("expected , or } in inline table")
+		}
+	}
+}
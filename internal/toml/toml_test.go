@@ -0,0 +1,98 @@
+package toml
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  map[string]any
+	}{
+		{
+			name:  "scalars",
+			input: "name = \"My Site\" # comment\ncount = 1_000\nratio = 1.5\nenabled = true\npath = 'C:\\dir'\ndate = 2026-01-02T10:00:00Z\n",
+			want: map[string]any{
+				"name": "My Site", "count": int64(1000), "ratio": 1.5, "enabled": true,
+				"path": `C:\dir`, "date": "2026-01-02T10:00:00Z",
+			},
+		},
+		{
+			name:  "escapes",
+			input: `text = "tab\there \"quoted\" \u00e9"` + "\n",
+			want:  map[string]any{"text": "tab\there \"quoted\" é"},
+		},
+		{
+			name:  "multi-line strings",
+			input: "basic = \"\"\"\nline one\nline two\"\"\"\nfolded = \"\"\"\\\n  one \\\n  two\"\"\"\nliteral = '''\nraw \\n'''\n",
+			want:  map[string]any{"basic": "line one\nline two", "folded": "one two", "literal": `raw \n`},
+		},
+		{
+			name:  "tables and dotted keys",
+			input: "name = \"Site\"\nsearch.enabled = true\n\n[sections.blog]\nsortBy = \"date\"\n\n[sections.docs]\nsortBy = \"weight\"\n",
+			want: map[string]any{
+				"name":   "Site",
+				"search": map[string]any{"enabled": true},
+				"sections": map[string]any{
+					"blog": map[string]any{"sortBy": "date"},
+					"docs": map[string]any{"sortBy": "weight"},
+				},
+			},
+		},
+		{
+			name:  "arrays and inline tables",
+			input: "tags = [\n  \"go\", # first\n  \"web\",\n]\nnums = [1, 2]\nmeta = { a = 1, b = { c = \"d\" } }\n",
+			want: map[string]any{
+				"tags": []any{"go", "web"},
+				"nums": []any{int64(1), int64(2)},
+				"meta": map[string]any{"a": int64(1), "b": map[string]any{"c": "d"}},
+			},
+		},
+		{
+			name:  "array of tables",
+			input: "[[nav]]\ntitle = \"Home\"\n[[nav]]\ntitle = \"Blog\"\n[nav.extra]\nicon = \"rss\"\n",
+			want: map[string]any{
+				"nav": []any{
+					map[string]any{"title": "Home"},
+					map[string]any{"title": "Blog", "extra": map[string]any{"icon": "rss"}},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse([]byte(tt.input))
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"missing equals", "name \"x\"\n"},
+		{"missing value", "name =\n"},
+		{"duplicate key", "a = 1\na = 2\n"},
+		{"unterminated string", "a = \"open\n"},
+		{"trailing content", "a = 1 2\n"},
+		{"table over value", "a = 1\n[a]\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Parse([]byte(tt.input)); err == nil {
+				t.Errorf("expected error for %q", tt.input)
+			}
+		})
+	}
+}
@@ -0,0 +1,532 @@
+// Package yaml decodes the subset of YAML used for site configuration and
+// front matter: nested mappings, block and flow sequences, flow mappings,
+// quoted and plain scalars, block scalars (| and >), and comments.
+// Anchors, aliases, tags, and multi-document streams are not supported.
+package yaml
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Parse decodes a YAML document whose root is a mapping.
+func Parse(data []byte) (map[string]any, error) {
+	p := &parser{}
+	for i, raw := range strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n") {
+		p.lines = append(p.lines, newLine(raw, i+1))
+	}
+
+	p.skipBlank()
+	if p.pos < len(p.lines) && p.lines[p.pos].text == "---" {
+		p.pos++
+	}
+
+	p.skipBlank()
+	if p.pos >= len(p.lines) {
+		return map[string]any{}, nil
+	}
+
+	first := p.lines[p.pos]
+	if isSequenceItem(first.text) {
+		return nil, fmt.Errorf("line %d: document root must be a mapping", first.num)
+	}
+
+	root, err := p.parseMapping(first.indent)
+	if err != nil {
+		return nil, err
+	}
+
+	p.skipBlank()
+	if p.pos < len(p.lines) && p.lines[p.pos].text != "..." {
+		return nil, fmt.Errorf("line %d: unexpected content %q", p.lines[p.pos].num, p.lines[p.pos].text)
+	}
+
+	return root, nil
+}
+
+type line struct {
+	raw    string
+	text   string // content after indentation, right-trimmed
+	indent int
+	num    int
+}
+
+func newLine(raw string, num int) line {
+	text := strings.TrimLeft(raw, " ")
+	return line{
+		raw:    raw,
+		text:   strings.TrimRight(text, " \t"),
+		indent: len(raw) - len(text),
+		num:    num,
+	}
+}
+
+func (l line) blank() bool {
+	return l.text == "" || strings.HasPrefix(l.text, "#")
+}
+
+type parser struct {
+	lines []line
+	pos   int
+}
+
+func (p *parser) skipBlank() {
+	for p.pos < len(p.lines) && p.lines[p.pos].blank() {
+		p.pos++
+	}
+}
+
+// next returns the next non-blank line without consuming it.
+func (p *parser) next() (line, bool) {
+	p.skipBlank()
+	if p.pos >= len(p.lines) {
+		return line{}, false
+	}
+	return p.lines[p.pos], true
+}
+
+func (p *parser) parseBlock(indent int) (any, error) {
+	l, ok := p.next()
+	if !ok {
+		return nil, nil
+	}
+	if isSequenceItem(l.text) {
+		return p.parseSequence(indent)
+	}
+	return p.parseMapping(indent)
+}
+
+func (p *parser) parseMapping(indent int) (map[string]any, error) {
+	result := make(map[string]any)
+
+	for {
+		l, ok := p.next()
+		if !ok || l.indent < indent {
+			return result, nil
+		}
+		if l.indent > indent {
+			return nil, fmt.Errorf("line %d: unexpected indentation", l.num)
+		}
+		if isSequenceItem(l.text) {
+			return result, nil
+		}
+
+		key, rest, err := splitKey(l)
+		if err != nil {
+			return nil, err
+		}
+		if _, exists := result[key]; exists {
+			return nil, fmt.Errorf("line %d: duplicate key %q", l.num, key)
+		}
+		p.pos++
+
+		value, err := p.parseValue(rest, indent, l.num, true)
+		if err != nil {
+			return nil, err
+		}
+		result[key] = value
+	}
+}
+
+func (p *parser) parseSequence(indent int) ([]any, error) {
+	var result []any
+
+	for {
+		l, ok := p.next()
+		if !ok || l.indent != indent || !isSequenceItem(l.text) {
+			if ok && l.indent > indent {
+				return nil, fmt.Errorf("line %d: unexpected indentation", l.num)
+			}
+			if result == nil {
+				result = []any{}
+			}
+			return result, nil
+		}
+
+		rest := strings.TrimLeft(l.text[1:], " ")
+		if rest != "" && !isFlowStart(rest) && !isQuoted(rest) && hasMappingKey(rest) {
+			// "- key: value" starts a mapping nested at the item's column
+			column := indent + len(l.text) - len(rest)
+			p.lines[p.pos] = line{raw: l.raw, text: rest, indent: column, num: l.num}
+			item, err := p.parseMapping(column)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, item)
+			continue
+		}
+
+		p.pos++
+		item, err := p.parseValue(rest, indent, l.num, false)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, item)
+	}
+}
+
+// parseValue parses the value following a key or sequence marker. An
+// empty value introduces a nested block; for mapping keys a sequence may
+// sit at the same indentation as the key.
+func (p *parser) parseValue(rest string, indent, num int, allowSameIndentSeq bool) (any, error) {
+	rest = stripComment(rest)
+
+	switch {
+	case rest == "":
+		l, ok := p.next()
+		if !ok {
+			return nil, nil
+		}
+		if l.indent > indent {
+			return p.parseBlock(l.indent)
+		}
+		if allowSameIndentSeq && l.indent == indent && isSequenceItem(l.text) {
+			return p.parseSequence(indent)
+		}
+		return nil, nil
+	case rest[0] == '|' || rest[0] == '>':
+		return p.parseBlockScalar(rest, indent), nil
+	case isFlowStart(rest):
+		for !balanced(rest) && p.pos < len(p.lines) {
+			rest += " " + stripComment(strings.TrimSpace(p.lines[p.pos].text))
+			p.pos++
+		}
+		value, remaining, err := parseFlow(rest)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", num, err)
+		}
+		if strings.TrimSpace(remaining) != "" {
+			return nil, fmt.Errorf("line %d: unexpected %q after flow value", num, remaining)
+		}
+		return value, nil
+	default:
+		value, err := parseScalar(rest)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", num, err)
+		}
+		return value, nil
+	}
+}
+
+// parseBlockScalar reads a literal (|) or folded (>) block scalar.
+func (p *parser) parseBlockScalar(header string, indent int) string {
+	folded := header[0] == '>'
+	chomp := "clip"
+	if strings.Contains(header, "-") {
+		chomp = "strip"
+	} else if strings.Contains(header, "+") {
+		chomp = "keep"
+	}
+
+	var lines []string
+	blockIndent := -1
+	for p.pos < len(p.lines) {
+		l := p.lines[p.pos]
+		if l.text == "" {
+			lines = append(lines, "")
+			p.pos++
+			continue
+		}
+		if l.indent <= indent {
+			break
+		}
+		if blockIndent == -1 {
+			blockIndent = l.indent
+		}
+		if l.indent < blockIndent {
+			break
+		}
+		lines = append(lines, strings.TrimRight(l.raw[blockIndent:], " \t"))
+		p.pos++
+	}
+
+	// Trailing blank lines belong to chomping, not content
+	trailing := 0
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+		trailing++
+	}
+
+	var text string
+	if folded {
+		var b strings.Builder
+		for i, l := range lines {
+			if i > 0 {
+				if l == "" || lines[i-1] == "" {
+					b.WriteString("\n")
+				} else {
+					b.WriteString(" ")
+				}
+			}
+			b.WriteString(l)
+		}
+		text = b.String()
+	} else {
+		text = strings.Join(lines, "\n")
+	}
+
+	switch chomp {
+	case "strip":
+		return text
+	case "keep":
+		return text + "\n" + strings.Repeat("\n", trailing)
+	default:
+		if text == "" {
+			return ""
+		}
+		return text + "\n"
+	}
+}
+
+func isSequenceItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+func isFlowStart(s string) bool {
+	return strings.HasPrefix(s, "[") || strings.HasPrefix(s, "{")
+}
+
+func isQuoted(s string) bool {
+	return strings.HasPrefix(s, `"`) || strings.HasPrefix(s, "'")
+}
+
+// hasMappingKey reports whether s contains a "key:" separator outside quotes.
+func hasMappingKey(s string) bool {
+	_, _, ok := cutKey(s)
+	return ok
+}
+
+func splitKey(l line) (string, string, error) {
+	key, rest, ok := cutKey(l.text)
+	if !ok {
+		return "", "", fmt.Errorf("line %d: expected \"key: value\", got %q", l.num, l.text)
+	}
+	if isQuoted(key) {
+		unquoted, err := parseQuoted(key)
+		if err != nil {
+			return "", "", fmt.Errorf("line %d: %w", l.num, err)
+		}
+		key = unquoted
+	}
+	return key, rest, nil
+}
+
+// cutKey splits "key: rest" at the first colon followed by a space or
+// the end of the line.
+func cutKey(s string) (string, string, bool) {
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			if i == 0 {
+				quote = c
+			}
+		case c == '#' && i > 0 && s[i-1] == ' ':
+			return "", "", false
+		case c == ':' && (i+1 == len(s) || s[i+1] == ' ' || s[i+1] == '\t'):
+			key := strings.TrimSpace(s[:i])
+			if key == "" {
+				return "", "", false
+			}
+			return key, strings.TrimSpace(s[i+1:]), true
+		}
+	}
+	return "", "", false
+}
+
+// stripComment removes a trailing " # comment" outside quotes.
+func stripComment(s string) string {
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			if i == 0 || s[i-1] == ' ' || s[i-1] == '[' || s[i-1] == '{' || s[i-1] == ',' || s[i-1] == ':' {
+				quote = c
+			}
+		case c == '#' && (i == 0 || s[i-1] == ' ' || s[i-1] == '\t'):
+			return strings.TrimSpace(s[:i])
+		}
+	}
+	return strings.TrimSpace(s)
+}
+
+// balanced reports whether all flow brackets in s are closed.
+func balanced(s string) bool {
+	depth := 0
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '[' || c == '{':
+			depth++
+		case c == ']' || c == '}':
+			depth--
+		}
+	}
+	return depth <= 0
+}
+
+// parseFlow parses a flow sequence or mapping and returns the remainder.
+func parseFlow(s string) (any, string, error) {
+	s = strings.TrimLeft(s, " ")
+	if s == "" {
+		return nil, "", fmt.Errorf("unexpected end of flow value")
+	}
+
+	switch s[0] {
+	case '[':
+		items := []any{}
+		s = strings.TrimLeft(s[1:], " ")
+		for {
+			if strings.HasPrefix(s, "]") {
+				return items, s[1:], nil
+			}
+			item, rest, err := parseFlowItem(s, "],")
+			if err != nil {
+				return nil, "", err
+			}
+			items = append(items, item)
+			s = strings.TrimLeft(rest, " ")
+			if strings.HasPrefix(s, ",") {
+				s = strings.TrimLeft(s[1:], " ")
+			} else if !strings.HasPrefix(s, "]") {
+				return nil, "", fmt.Errorf("expected , or ] in flow sequence")
+			}
+		}
+	case '{':
+		m := map[string]any{}
+		s = strings.TrimLeft(s[1:], " ")
+		for {
+			if strings.HasPrefix(s, "}") {
+				return m, s[1:], nil
+			}
+			keyValue, rest, err := parseFlowItem(s, ":,}")
+			if err != nil {
+				return nil, "", err
+			}
+			key := fmt.Sprint(keyValue)
+			s = strings.TrimLeft(rest, " ")
+			if !strings.HasPrefix(s, ":") {
+				return nil, "", fmt.Errorf("expected : after key %q in flow mapping", key)
+			}
+			value, rest, err := parseFlowItem(strings.TrimLeft(s[1:], " "), ",}")
+			if err != nil {
+				return nil, "", err
+			}
+			m[key] = value
+			s = strings.TrimLeft(rest, " ")
+			if strings.HasPrefix(s, ",") {
+				s = strings.TrimLeft(s[1:], " ")
+			} else if !strings.HasPrefix(s, "}") {
+				return nil, "", fmt.Errorf("expected , or } in flow mapping")
+			}
+		}
+	}
+
+	return nil, "", fmt.Errorf("expected [ or {")
+}
+
+// parseFlowItem parses one flow value ending at any of the stop bytes.
+func parseFlowItem(s, stops string) (any, string, error) {
+	if s == "" {
+		return nil, "", fmt.Errorf("unexpected end of flow value")
+	}
+	if isFlowStart(s) {
+		return parseFlow(s)
+	}
+	if isQuoted(s) {
+		end := closingQuote(s)
+		if end == -1 {
+			return nil, "", fmt.Errorf("unterminated string %s", s)
+		}
+		value, err := parseQuoted(s[:end+1])
+		return value, s[end+1:], err
+	}
+
+	end := strings.IndexAny(s, stops)
+	if end == -1 {
+		return nil, "", fmt.Errorf("unterminated flow value %q", s)
+	}
+	value, err := parseScalar(strings.TrimSpace(s[:end]))
+	return value, s[end:], err
+}
+
+func closingQuote(s string) int {
+	quote := s[0]
+	for i := 1; i < len(s); i++ {
+		switch {
+		case quote == '"' && s[i] == '\\':
+			i++
+		case s[i] == quote:
+			if quote == '\'' && i+1 < len(s) && s[i+1] == '\'' {
+				i++
+				continue
+			}
+			return i
+		}
+	}
+	return -1
+}
+
+func parseQuoted(s string) (string, error) {
+	if s[0] == '\'' {
+		return strings.ReplaceAll(s[1:len(s)-1], "''", "'"), nil
+	}
+	value, err := strconv.Unquote(s)
+	if err != nil {
+		return "", fmt.Errorf("invalid quoted string %s", s)
+	}
+	return value, nil
+}
+
+// parseScalar resolves a plain or quoted scalar to a Go value.
+func parseScalar(s string) (any, error) {
+	if s == "" {
+		return nil, nil
+	}
+	if isQuoted(s) {
+		end := closingQuote(s)
+		if end != len(s)-1 {
+			return nil, fmt.Errorf("invalid quoted string %s", s)
+		}
+		return parseQuoted(s)
+	}
+
+	switch s {
+	case "~", "null", "Null", "NULL":
+		return nil, nil
+	case "true", "True", "TRUE":
+		return true, nil
+	case "false", "False", "FALSE":
+		return false, nil
+	}
+
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return i, nil
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil && !strings.ContainsAny(s, "xXpP_") {
+		return f, nil
+	}
+	return s, nil
+}
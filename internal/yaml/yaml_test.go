@@ -0,0 +1,104 @@
+package yaml
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  map[string]any
+	}{
+		{
+			name:  "scalars",
+			input: "name: My Site\ncount: 3\nratio: 1.5\nenabled: true\nempty: ~\nquoted: \"a: b # c\"\nsingle: 'it''s'\n",
+			want: map[string]any{
+				"name": "My Site", "count": int64(3), "ratio": 1.5, "enabled": true,
+				"empty": nil, "quoted": "a: b # c", "single": "it's",
+			},
+		},
+		{
+			name:  "comments",
+			input: "# leading\nname: Site # trailing\n\nurl: https://example.com/#top\n",
+			want:  map[string]any{"name": "Site", "url": "https://example.com/#top"},
+		},
+		{
+			name:  "nested mapping",
+			input: "search:\n  enabled: true\n  options:\n    limit: 10\n",
+			want: map[string]any{
+				"search": map[string]any{"enabled": true, "options": map[string]any{"limit": int64(10)}},
+			},
+		},
+		{
+			name:  "block sequences",
+			input: "tags:\n  - go\n  - web\nsame:\n- a\n- b\n",
+			want:  map[string]any{"tags": []any{"go", "web"}, "same": []any{"a", "b"}},
+		},
+		{
+			name:  "sequence of mappings",
+			input: "nav:\n  - title: Home\n    url: /\n  - title: Blog\n    url: /blog/\n    children:\n      - title: Archive\n",
+			want: map[string]any{
+				"nav": []any{
+					map[string]any{"title": "Home", "url": "/"},
+					map[string]any{"title": "Blog", "url": "/blog/", "children": []any{
+						map[string]any{"title": "Archive"},
+					}},
+				},
+			},
+		},
+		{
+			name:  "flow collections",
+			input: "tags: [go, \"web, dev\", 3]\nmeta: {a: 1, b: [x, y]}\nlong: [\n  one,\n  two\n]\n",
+			want: map[string]any{
+				"tags": []any{"go", "web, dev", int64(3)},
+				"meta": map[string]any{"a": int64(1), "b": []any{"x", "y"}},
+				"long": []any{"one", "two"},
+			},
+		},
+		{
+			name:  "block scalars",
+			input: "literal: |\n  line one\n  line two\nfolded: >-\n  folded\n  text\nafter: x\n",
+			want:  map[string]any{"literal": "line one\nline two\n", "folded": "folded text", "after": "x"},
+		},
+		{
+			name:  "document marker",
+			input: "---\ntitle: Hello\n",
+			want:  map[string]any{"title": "Hello"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse([]byte(tt.input))
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"root sequence", "- a\n- b\n"},
+		{"bad indentation", "a: 1\n    b: 2\n"},
+		{"duplicate key", "a: 1\na: 2\n"},
+		{"missing colon", "just text\n"},
+		{"unterminated flow", "a: [1, 2\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Parse([]byte(tt.input)); err == nil {
+				t.Errorf("expected error for %q", tt.input)
+			}
+		})
+	}
+}